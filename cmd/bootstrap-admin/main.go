@@ -0,0 +1,92 @@
+// Command bootstrap-admin creates the first administrator, or promotes an
+// existing user, so the admin routes can be reached on a fresh database:
+//
+//	ADMIN_PASSWORD=... go run ./cmd/bootstrap-admin -email admin@example.com -name Admin -address HQ
+//
+// Run the server once beforehand so the schema exists. Running it again for
+// the same email only makes sure the user holds the admin role.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"rent-video-game/config"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/usecase"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the administrator")
+	name := flag.String("name", "Administrator", "name for a new administrator")
+	address := flag.String("address", "-", "address for a new administrator")
+	flag.Parse()
+
+	if err := run(*email, *name, *address, os.Getenv("ADMIN_PASSWORD")); err != nil {
+		fmt.Fprintln(os.Stderr, "bootstrap-admin:", err)
+		os.Exit(1)
+	}
+}
+
+func run(email, name, address, password string) error {
+	if email == "" {
+		return errors.New("-email is required")
+	}
+
+	db, err := gorm.Open(postgres.Open(config.InitDB()), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := db.AutoMigrate(&model.Roles{}, &model.Permissions{}, &model.UserRoles{}); err != nil {
+		return err
+	}
+
+	roleUsecase := usecase.NewRoleUsecase(repository.NewRoleRepository(db))
+	if err := roleUsecase.SeedRoles(); err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
+
+	userUsecase := usecase.NewUserUsecase(repository.NewUserRepository(db))
+	user, err := userUsecase.GetUserByEmail(email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if password == "" {
+			return errors.New("ADMIN_PASSWORD is required to create a new user")
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		// the operator vouches for the address, so no verification link is sent
+		verifiedAt := time.Now()
+		user, err = userUsecase.RegisterUser(&model.Users{
+			Name:            name,
+			Email:           email,
+			Password:        string(hashedPassword),
+			Address:         address,
+			EmailVerifiedAt: &verifiedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		fmt.Println("created user", user.UserID)
+	} else if err != nil {
+		return err
+	}
+
+	if err := roleUsecase.AssignRole(user.UserID, model.RoleAdmin); err != nil {
+		return fmt.Errorf("failed to assign admin role: %w", err)
+	}
+
+	fmt.Printf("%s (%s) is an administrator\n", user.Email, user.UserID)
+	return nil
+}
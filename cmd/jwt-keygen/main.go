@@ -0,0 +1,96 @@
+// Command jwt-keygen adds a token signing key to the key directory:
+//
+//	go run ./cmd/jwt-keygen -dir keys -alg EdDSA
+//
+// The key ID is the creation time, so the newest key signs new tokens unless
+// JWT_SIGNING_KEY_ID pins another one. To rotate without rejecting tokens:
+//
+//  1. Generate a key and deploy it with JWT_SIGNING_KEY_ID set to the current
+//     key. The new key is now listed in /.well-known/jwks.json but signs
+//     nothing, giving verifiers time to fetch it.
+//  2. After the JWKS cache time has passed, unset JWT_SIGNING_KEY_ID and
+//     restart. New tokens are signed with the new key.
+//  3. Retire the old key with -retire <kid>. Its private key is replaced by
+//     the public key, which keeps verifying tokens already issued.
+//  4. Once the longest token lifetime (EMAIL_VERIFICATION_TTL) has passed,
+//     delete <kid>.pub.pem.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rent-video-game/utils"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", "keys", "key directory")
+	alg := flag.String("alg", utils.AlgEdDSA, "signing algorithm, RS256 or EdDSA")
+	retire := flag.String("retire", "", "key ID to stop signing with, keeping its public key")
+	flag.Parse()
+
+	var err error
+	if *retire != "" {
+		err = retireKey(*dir, *retire)
+	} else {
+		err = generateKey(*dir, *alg)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jwt-keygen:", err)
+		os.Exit(1)
+	}
+}
+
+func generateKey(dir, alg string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	key, err := utils.GenerateSigningKey(time.Now().UTC().Format("20060102T150405Z"), alg)
+	if err != nil {
+		return err
+	}
+
+	data, err := key.MarshalPrivateKey()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, key.ID+".pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s key %s to %s\n", alg, key.ID, path)
+	return nil
+}
+
+func retireKey(dir, id string) error {
+	privatePath := filepath.Join(dir, id+".pem")
+	data, err := os.ReadFile(privatePath)
+	if err != nil {
+		return err
+	}
+
+	key, err := utils.ParsePrivateKey(id, data)
+	if err != nil {
+		return err
+	}
+
+	public, err := key.MarshalPublicKey()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, id+".pub.pem"), public, 0o644); err != nil {
+		return err
+	}
+	if err := os.Remove(privatePath); err != nil {
+		return err
+	}
+
+	fmt.Printf("retired key %s; it now only verifies tokens\n", id)
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,3 +16,20 @@ func InitDB() string {
 
 	return os.Getenv("DATABASE_URL")
 }
+
+// Duration reads a duration such as "24h" or "15m" from the environment.
+func Duration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// String reads a value from the environment, falling back when it is unset.
+func String(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,58 @@
+package currency
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Code is an ISO 4217 currency code. Amounts in every currency are Money,
+// exact to the minor unit, e.g. cents for USD.
+type Code string
+
+const (
+	USD Code = "USD"
+	IDR Code = "IDR"
+	SGD Code = "SGD"
+)
+
+// Default is the currency of wallets and products that never chose one.
+const Default = USD
+
+var ErrUnsupported = errors.New("unsupported currency")
+
+// supported lists the currencies wallets and products may hold.
+var supported = map[Code]bool{
+	USD: true,
+	IDR: true,
+	SGD: true,
+}
+
+// Parse normalizes a user-supplied code and checks that it is supported.
+func Parse(code string) (Code, error) {
+	c := Code(strings.ToUpper(strings.TrimSpace(code)))
+	if !c.Valid() {
+		return "", fmt.Errorf("%w: %q", ErrUnsupported, code)
+	}
+	return c, nil
+}
+
+// Supported returns the supported currencies in a fixed order.
+func Supported() []Code {
+	codes := make([]Code, 0, len(supported))
+	for code := range supported {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+func (c Code) Valid() bool {
+	return supported[c]
+}
+
+// Format renders an amount for people, e.g. "SGD 12.50".
+func Format(amount Money, c Code) string {
+	return fmt.Sprintf("%s %s", c, amount)
+}
@@ -0,0 +1,159 @@
+package currency
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimals a Money amount carries. Every supported
+// currency has two minor-unit digits, so one scale fits them all.
+const Scale = 2
+
+// maxIntegerDigits keeps parsed amounts well inside int64 cents and inside the
+// NUMERIC(14, 2) columns they are stored in.
+const maxIntegerDigits = 12
+
+var (
+	ErrInvalidAmount  = errors.New("amount must be a decimal number with at most 2 decimal places")
+	ErrNegativeAmount = errors.New("amount must not be negative")
+)
+
+// Money is an exact amount counted in hundredths of its currency's major unit.
+// It is stored as NUMERIC, written to JSON as a decimal number such as 12.50
+// and never passes through a float on the way in or out.
+type Money int64
+
+// ParseMoney reads a decimal string such as "12.5" or "-3.00". Digits past the
+// second decimal place must be zero.
+func ParseMoney(value string) (Money, error) {
+	s := strings.TrimSpace(value)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction, point := strings.Cut(s, ".")
+	if whole == "" || (point && fraction == "") || len(whole) > maxIntegerDigits || !digits(whole) || !digits(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	if len(fraction) > Scale {
+		if strings.Trim(fraction[Scale:], "0") != "" {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+		}
+		fraction = fraction[:Scale]
+	}
+	fraction += strings.Repeat("0", Scale-len(fraction))
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money(minor), nil
+}
+
+// Minor returns the amount in minor units, the form payment providers take.
+func (m Money) Minor() int64 {
+	return int64(m)
+}
+
+// Mul scales the amount by an exact factor and rounds half away from zero to
+// the nearest minor unit.
+func (m Money) Mul(factor *big.Rat) Money {
+	return Money(round(new(big.Rat).Mul(big.NewRat(int64(m), 1), factor)))
+}
+
+func (m Money) String() string {
+	sign := ""
+	minor := int64(m)
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/100, minor%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string. Request
+// amounts are never negative, so binding rejects them along with fractions of
+// a minor unit.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	value, err := ParseMoney(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+	if value < 0 {
+		return ErrNegativeAmount
+	}
+
+	*m = value
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	var (
+		value Money
+		err   error
+	)
+
+	switch v := src.(type) {
+	case nil:
+		value = 0
+	case []byte:
+		value, err = ParseMoney(string(v))
+	case string:
+		value, err = ParseMoney(v)
+	case int64:
+		value = Money(v * 100)
+	case float64:
+		value, err = ParseMoney(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		err = fmt.Errorf("cannot scan %T into Money", src)
+	}
+	if err != nil {
+		return err
+	}
+
+	*m = value
+	return nil
+}
+
+func round(value *big.Rat) int64 {
+	num := new(big.Int).Abs(value.Num())
+	den := value.Denom()
+
+	// floor((2|n| + d) / 2d) rounds half up on the magnitude
+	num.Mul(num, big.NewInt(2)).Add(num, den)
+	num.Quo(num, new(big.Int).Mul(den, big.NewInt(2)))
+
+	if value.Sign() < 0 {
+		num.Neg(num)
+	}
+	return num.Int64()
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
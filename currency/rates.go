@@ -0,0 +1,107 @@
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// RateScale is the number of decimals a rate keeps when it is recorded.
+const RateScale = 10
+
+var ErrNoRate = errors.New("no exchange rate")
+
+// Rates is an exchange-rate table quoted against a single base currency: one
+// unit of the base buys Rate units of each listed currency.
+type Rates struct {
+	base  Code
+	rates map[Code]*big.Rat
+}
+
+type ratesFile struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+// LoadRates reads a JSON rate table such as
+//
+//	{"base": "USD", "rates": {"IDR": "16250", "SGD": "1.35"}}
+//
+// Rates are strings so they are parsed exactly.
+func LoadRates(path string) (*Rates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ratesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rate file %s: %w", path, err)
+	}
+
+	base, err := Parse(file.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(map[Code]string, len(file.Rates))
+	for code, rate := range file.Rates {
+		c, err := Parse(code)
+		if err != nil {
+			return nil, err
+		}
+		rates[c] = rate
+	}
+
+	return NewRates(base, rates)
+}
+
+func NewRates(base Code, rates map[Code]string) (*Rates, error) {
+	table := &Rates{base: base, rates: map[Code]*big.Rat{base: big.NewRat(1, 1)}}
+	for code, value := range rates {
+		rate, err := ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate for %s: %w", code, err)
+		}
+		table.rates[code] = rate
+	}
+	return table, nil
+}
+
+// Rate returns how many units of to one unit of from buys.
+func (r *Rates) Rate(from, to Code) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	fromRate, ok := r.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRate, from)
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrNoRate, to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+func ParseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(value)
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q", value)
+	}
+	return rate, nil
+}
+
+// FormatRate renders a rate the way it is stored on bookings and transactions.
+func FormatRate(rate *big.Rat) string {
+	return rate.FloatString(RateScale)
+}
+
+// Invert returns the rate for the opposite direction.
+func Invert(rate *big.Rat) *big.Rat {
+	return new(big.Rat).Inv(rate)
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"rent-video-game/currency"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoneyIsExact(t *testing.T) {
+	cases := map[string]currency.Money{
+		"0":       0,
+		"12.5":    1250,
+		"12.50":   1250,
+		"0.1":     10,
+		"0.29":    29,
+		"19.990":  1999,
+		"-3.07":   -307,
+		"1000000": 100000000,
+	}
+	for input, want := range cases {
+		got, err := currency.ParseMoney(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "1.005", "1e2", "12.", ".5", "abc", "1,000"} {
+		_, err := currency.ParseMoney(input)
+		assert.ErrorIs(t, err, currency.ErrInvalidAmount, input)
+	}
+}
+
+func TestMoneyJSONRejectsSubCentAndNegative(t *testing.T) {
+	var request struct {
+		Amount currency.Money `json:"amount"`
+	}
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": 25.10}`), &request))
+	assert.Equal(t, currency.Money(2510), request.Amount)
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount": "0.29"}`), &request))
+	assert.Equal(t, currency.Money(29), request.Amount)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount": 0.001}`), &request), currency.ErrInvalidAmount)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount": -5}`), &request), currency.ErrNegativeAmount)
+
+	data, err := json.Marshal(map[string]currency.Money{"amount": -1250})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": -12.50}`, string(data))
+}
+
+func TestMoneyRoundTripsNumeric(t *testing.T) {
+	var amount currency.Money
+
+	assert.NoError(t, amount.Scan([]byte("1234.56")))
+	assert.Equal(t, currency.Money(123456), amount)
+
+	value, err := amount.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.56", value)
+
+	assert.NoError(t, amount.Scan(int64(7)))
+	assert.Equal(t, currency.Money(700), amount)
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"rent-video-game/currency"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertRoundsHalfAwayFromZero(t *testing.T) {
+	rates, err := currency.NewRates(currency.USD, map[currency.Code]string{currency.SGD: "1.35"})
+	assert.NoError(t, err)
+
+	rate, err := rates.Rate(currency.USD, currency.SGD)
+	assert.NoError(t, err)
+
+	assert.Equal(t, currency.Money(1350), currency.Money(1000).Mul(rate))
+	assert.Equal(t, currency.Money(1), currency.Money(1).Mul(rate))
+	assert.Equal(t, currency.Money(-1), currency.Money(-1).Mul(rate))
+	assert.Equal(t, currency.Money(2701), currency.Money(2001).Mul(rate))
+}
+
+func TestRateBetweenNonBaseCurrencies(t *testing.T) {
+	rates, err := currency.NewRates(currency.USD, map[currency.Code]string{
+		currency.IDR: "16250",
+		currency.SGD: "1.25",
+	})
+	assert.NoError(t, err)
+
+	rate, err := rates.Rate(currency.SGD, currency.IDR)
+	assert.NoError(t, err)
+	assert.Equal(t, "13000.0000000000", currency.FormatRate(rate))
+
+	same, err := rates.Rate(currency.IDR, currency.IDR)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0000000000", currency.FormatRate(same))
+}
+
+func TestParseRejectsUnknownCurrency(t *testing.T) {
+	code, err := currency.Parse("sgd")
+	assert.NoError(t, err)
+	assert.Equal(t, currency.SGD, code)
+
+	_, err = currency.Parse("EUR")
+	assert.ErrorIs(t, err, currency.ErrUnsupported)
+	assert.Equal(t, "SGD 12.50", currency.Format(1250, currency.SGD))
+}
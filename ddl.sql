@@ -4,7 +4,9 @@ CREATE TABLE users (
     email VARCHAR(255) NOT NULL UNIQUE,
     password VARCHAR(255) NOT NULL,
     address VARCHAR(255) NOT NULL,
-    amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    email_verified_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP
@@ -13,8 +15,10 @@ CREATE TABLE users (
 CREATE TABLE topup_histories (
     topup_history_id SERIAL PRIMARY KEY,
     user_id UUID NOT NULL,
-    payment_id VARCHAR(255) NOT NULL,
-    amount DECIMAL(10, 2) NOT NULL,
+    payment_id VARCHAR(255) NOT NULL UNIQUE,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount NUMERIC(14, 2) NOT NULL,
+    refunded_amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP,
@@ -46,7 +50,10 @@ CREATE TABLE products (
     console_id INT NOT NULL,
     name VARCHAR(255) NOT NULL,
     description TEXT NOT NULL,
-    rental_cost_per_month DECIMAL(10, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    rental_cost_per_month NUMERIC(14, 2) NOT NULL,
+    deposit_amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    late_fee_per_day NUMERIC(14, 2) NOT NULL DEFAULT 0,
     stock_availability INT NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -55,12 +62,26 @@ CREATE TABLE products (
     FOREIGN KEY (console_id) REFERENCES consoles(console_id) ON DELETE CASCADE ON UPDATE CASCADE
 );
 
+CREATE INDEX idx_products_console_id ON products(console_id);
+
+CREATE TABLE product_units (
+    unit_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    label VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
 CREATE TABLE ratings (
     rating_id SERIAL PRIMARY KEY,
     product_id INT NOT NULL,
     user_id UUID NOT NULL,
     review TEXT NOT NULL,
     stars DECIMAL(2, 1) NOT NULL,
+    reply TEXT,
+    replied_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP,
@@ -68,20 +89,52 @@ CREATE TABLE ratings (
     FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
 );
 
-CREATE TYPE booking_status AS ENUM ('PENDING', 'APPROVED', 'REJECTED');
+CREATE TABLE product_rating_stats (
+    product_id INT PRIMARY KEY,
+    rating_count INT NOT NULL DEFAULT 0,
+    average_stars NUMERIC(3, 2) NOT NULL DEFAULT 0,
+    stars_1 INT NOT NULL DEFAULT 0,
+    stars_2 INT NOT NULL DEFAULT 0,
+    stars_3 INT NOT NULL DEFAULT 0,
+    stars_4 INT NOT NULL DEFAULT 0,
+    stars_5 INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TYPE booking_status AS ENUM ('PENDING', 'APPROVED', 'REJECTED', 'PAID', 'ACTIVE', 'RETURNED', 'OVERDUE', 'CANCELLED');
 
 CREATE TABLE bookings (
     booking_id SERIAL PRIMARY KEY,
     product_id INT NOT NULL,
     user_id UUID NOT NULL,
+    unit_id INT,
     start_date DATE NOT NULL,
     end_date DATE NOT NULL,
     status booking_status NOT NULL DEFAULT 'PENDING',
+    status_reason TEXT,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    exchange_rate DECIMAL(20, 10) NOT NULL DEFAULT 1,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP,
     FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE ON UPDATE CASCADE,
-    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (unit_id) REFERENCES product_units(unit_id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX idx_bookings_unit_dates ON bookings (unit_id, start_date, end_date);
+
+CREATE TABLE booking_status_histories (
+    history_id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL,
+    from_status booking_status NOT NULL,
+    to_status booking_status NOT NULL,
+    changed_by UUID,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(booking_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(user_id) ON DELETE SET NULL ON UPDATE CASCADE
 );
 
 CREATE TABLE transactions (
@@ -89,11 +142,232 @@ CREATE TABLE transactions (
     booking_id INT NOT NULL,
     user_id UUID NOT NULL,
     lessor_id INT NOT NULL,
-    amount DECIMAL(10, 2) NOT NULL,
+    type VARCHAR(50) NOT NULL DEFAULT 'PAYMENT',
+    reversal_of_id INT,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount NUMERIC(14, 2) NOT NULL,
+    settlement_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    settlement_amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    exchange_rate DECIMAL(20, 10) NOT NULL DEFAULT 1,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP,
     FOREIGN KEY (booking_id) REFERENCES bookings(booking_id) ON DELETE CASCADE ON UPDATE CASCADE,
     FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (lessor_id) REFERENCES lessors(lessor_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (reversal_of_id) REFERENCES transactions(transaction_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX idx_transactions_reversal_of_id ON transactions(reversal_of_id);
+
+CREATE TABLE cancellation_policies (
+    policy_id SERIAL PRIMARY KEY,
+    lessor_id INT NOT NULL UNIQUE,
+    full_refund_days INT NOT NULL,
+    partial_refund_percent DECIMAL(5, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (lessor_id) REFERENCES lessors(lessor_id) ON DELETE CASCADE ON UPDATE CASCADE
-);
\ No newline at end of file
+);
+
+CREATE TABLE deposits (
+    deposit_id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    lessor_id INT NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount NUMERIC(14, 2) NOT NULL,
+    late_fees NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    late_fee_days INT NOT NULL DEFAULT 0,
+    captured NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    released NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    damage_note TEXT,
+    status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (booking_id) REFERENCES bookings(booking_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (lessor_id) REFERENCES lessors(lessor_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE ledger_accounts (
+    account_id SERIAL PRIMARY KEY,
+    user_id UUID UNIQUE,
+    type VARCHAR(50) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE journal_entries (
+    journal_entry_id SERIAL PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    reference VARCHAR(255),
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE postings (
+    posting_id SERIAL PRIMARY KEY,
+    journal_entry_id INT NOT NULL,
+    account_id INT NOT NULL,
+    amount NUMERIC(14, 2) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (journal_entry_id) REFERENCES journal_entries(journal_entry_id) ON DELETE RESTRICT,
+    FOREIGN KEY (account_id) REFERENCES ledger_accounts(account_id) ON DELETE RESTRICT
+);
+
+CREATE TABLE idempotency_keys (
+    idempotency_key_id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    content_type VARCHAR(255),
+    response_body BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, key, route)
+);
+
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+
+CREATE TABLE payment_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(255) NOT NULL,
+    payment_id VARCHAR(255),
+    status VARCHAR(50) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_payment_events_payment_id ON payment_events(payment_id);
+
+CREATE TABLE withdrawals (
+    withdrawal_id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    amount NUMERIC(14, 2) NOT NULL,
+    destination VARCHAR(255) NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    failure_reason TEXT,
+    processed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX idx_withdrawals_user_id ON withdrawals(user_id);
+CREATE INDEX idx_withdrawals_status ON withdrawals(status);
+
+CREATE TABLE roles (
+    role_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE permissions (
+    permission_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE role_permissions (
+    role_id INT NOT NULL,
+    permission_id INT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    FOREIGN KEY (role_id) REFERENCES roles(role_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (permission_id) REFERENCES permissions(permission_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL,
+    role_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (role_id) REFERENCES roles(role_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_consoles_name_active ON consoles (LOWER(name)) WHERE deleted_at IS NULL;
+
+CREATE TABLE audit_logs (
+    audit_log_id SERIAL PRIMARY KEY,
+    actor_id UUID NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX idx_audit_logs_entity ON audit_logs(entity_type, entity_id);
+
+CREATE TABLE refresh_tokens (
+    refresh_token_id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens(expires_at);
+
+CREATE TABLE account_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id UUID NOT NULL,
+    purpose VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX idx_account_tokens_user_id ON account_tokens(user_id);
+CREATE INDEX idx_account_tokens_expires_at ON account_tokens(expires_at);
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products ADD COLUMN search_vector tsvector;
+
+CREATE OR REPLACE FUNCTION products_search_vector() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector :=
+        setweight(to_tsvector('simple', COALESCE(NEW.name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE((SELECT name FROM consoles WHERE console_id = NEW.console_id), '')), 'B') ||
+        setweight(to_tsvector('simple', COALESCE(NEW.description, '')), 'C');
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_search_vector
+    BEFORE INSERT OR UPDATE OF name, description, console_id ON products
+    FOR EACH ROW EXECUTE FUNCTION products_search_vector();
+
+CREATE OR REPLACE FUNCTION consoles_search_vector() RETURNS trigger AS $$
+BEGIN
+    UPDATE products SET name = name WHERE console_id = NEW.console_id;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+CREATE TRIGGER consoles_search_vector
+    AFTER UPDATE OF name ON consoles
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name) EXECUTE FUNCTION consoles_search_vector();
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+CREATE INDEX idx_products_name_trgm ON products USING GIN (LOWER(name) gin_trgm_ops);
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"rent-video-game/currency"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
 	"rent-video-game/utils"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type BookingHandler struct {
@@ -16,6 +21,7 @@ type BookingHandler struct {
 	userUsecase    *usecase.UserUsecase
 	productUsecase *usecase.ProductUsecase
 	lessorUsecase  *usecase.LessorUsecase
+	pricingUsecase *usecase.PricingUsecase
 }
 
 func NewBookingHandler(
@@ -23,19 +29,30 @@ func NewBookingHandler(
 	userUsecase *usecase.UserUsecase,
 	productUsecase *usecase.ProductUsecase,
 	lessorUsecase *usecase.LessorUsecase,
+	pricingUsecase *usecase.PricingUsecase,
 ) *BookingHandler {
 	return &BookingHandler{
 		bookingUsecase: bookingUsecase,
 		userUsecase:    userUsecase,
 		productUsecase: productUsecase,
 		lessorUsecase:  lessorUsecase,
+		pricingUsecase: pricingUsecase,
 	}
 }
 
 func (u *BookingHandler) BookingRoutes(e *echo.Echo) {
-	e.POST("/user/booking", middleware.UserAuthMiddleware()(u.CreateBooking))
-	e.GET("/user/booking/:booking_id", middleware.UserAuthMiddleware()(u.GetBookingByID))
-	e.GET("/user/booking", middleware.UserAuthMiddleware()(u.GetAllBookingByUser))
+	e.POST("/user/booking", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(middleware.RequireVerifiedEmail(u.userUsecase)(u.CreateBooking))))
+	e.GET("/user/booking/:booking_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(u.GetBookingByID)))
+	e.GET("/user/booking", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(u.GetAllBookingByUser)))
+	e.GET("/user/booking/:booking_id/quote", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(u.GetBookingQuote)))
+
+	e.GET("/lessor/bookings", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(u.GetAllBookingByLessor)))
+	e.PUT("/lessor/booking/:booking_id/approve", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(u.ApproveBooking)))
+	e.PUT("/lessor/booking/:booking_id/reject", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(u.RejectBooking)))
+	e.PUT("/lessor/booking/:booking_id/handover", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(u.HandOverBooking)))
+	e.PUT("/lessor/booking/:booking_id/return", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(u.ReturnBooking)))
+	e.GET("/lessor/booking/:booking_id/history", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(u.GetLessorBookingHistory)))
+	e.GET("/user/booking/:booking_id/history", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(u.GetBookingHistory)))
 }
 
 func (u *BookingHandler) CreateBooking(c echo.Context) error {
@@ -58,6 +75,13 @@ func (u *BookingHandler) CreateBooking(c echo.Context) error {
 	booking.UserID = userID        // set user id from token
 	booking.Status = model.Pending // set status to pending
 
+	user, err := u.userUsecase.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	booking.Currency = user.Currency // renters pay in their wallet currency
+
 	isOwner, err := u.bookingUsecase.IsUserProductOwner(userID, booking.ProductID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
@@ -69,21 +93,17 @@ func (u *BookingHandler) CreateBooking(c echo.Context) error {
 
 	lessor, err := u.lessorUsecase.GetLessorByProductID(booking.ProductID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return createBookingError(err)
 	}
 
 	product, err := u.productUsecase.GetProductByID(booking.ProductID, lessor.LessorID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-
-	if product.StockAvailability <= 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "product is out of stock")
+		return createBookingError(err)
 	}
 
 	booking, err = u.bookingUsecase.CreateBooking(booking)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return createBookingError(err)
 	}
 
 	bookingData := model.BookingData{
@@ -94,19 +114,17 @@ func (u *BookingHandler) CreateBooking(c echo.Context) error {
 		Status:      string(booking.Status),
 	}
 
-	if err := u.productUsecase.DecrementStockAvailability(booking.ProductID); err != nil {
-		fmt.Printf("failed to decrement stock: %v\n", err)
+	totalPay := currency.Format(product.RentalCostPerMonth, product.Currency)
+	if quote, err := u.pricingUsecase.Quote(booking, product); err == nil {
+		totalPay = currency.Format(quote.Amount, quote.Currency)
 	}
 
-	user, err := u.userUsecase.GetUserByID(userID)
-	if err == nil {
-		go func() {
-			err := utils.SendBookingNotification(user.Email, user.Name, string(model.Pending), booking.BookingID, product.RentalCostPerMonth)
-			if err != nil {
-				fmt.Printf("failed to send topup notification: %v\n", err)
-			}
-		}()
-	}
+	go func() {
+		err := utils.SendBookingNotification(user.Email, user.Name, string(model.Pending), booking.BookingID, totalPay)
+		if err != nil {
+			fmt.Printf("failed to send topup notification: %v\n", err)
+		}
+	}()
 
 	response := model.BookingResponse{
 		Message: "success create booking",
@@ -131,11 +149,12 @@ func (u *BookingHandler) GetBookingByID(c echo.Context) error {
 	}
 
 	bookingData := model.BookingData{
-		BookingID:   booking.BookingID,
-		ProductName: booking.Products.Name,
-		StartDate:   booking.StartDate,
-		EndDate:     booking.EndDate,
-		Status:      string(booking.Status),
+		BookingID:    booking.BookingID,
+		ProductName:  booking.Products.Name,
+		StartDate:    booking.StartDate,
+		EndDate:      booking.EndDate,
+		Status:       string(booking.Status),
+		StatusReason: booking.StatusReason,
 	}
 
 	response := model.BookingResponse{
@@ -160,11 +179,12 @@ func (u *BookingHandler) GetAllBookingByUser(c echo.Context) error {
 	var bookingData []model.BookingData
 	for _, booking := range bookings {
 		bookingData = append(bookingData, model.BookingData{
-			BookingID:   booking.BookingID,
-			ProductName: booking.Products.Name,
-			StartDate:   booking.StartDate,
-			EndDate:     booking.EndDate,
-			Status:      string(booking.Status),
+			BookingID:    booking.BookingID,
+			ProductName:  booking.Products.Name,
+			StartDate:    booking.StartDate,
+			EndDate:      booking.EndDate,
+			Status:       string(booking.Status),
+			StatusReason: booking.StatusReason,
 		})
 	}
 
@@ -175,3 +195,229 @@ func (u *BookingHandler) GetAllBookingByUser(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, response)
 }
+
+func (u *BookingHandler) GetBookingQuote(c echo.Context) error {
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	quote, err := u.pricingUsecase.QuoteBooking(id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "booking not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.QuoteResponse{
+		Message: "success get booking quote",
+		Data:    *quote,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (u *BookingHandler) GetAllBookingByLessor(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := u.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "user is not a lessor")
+	}
+
+	bookings, err := u.bookingUsecase.GetAllBookingByLessor(lessor.LessorID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	var bookingData []model.BookingData
+	for _, booking := range bookings {
+		bookingData = append(bookingData, model.BookingData{
+			BookingID:    booking.BookingID,
+			ProductName:  booking.Products.Name,
+			RenterName:   booking.Users.Name,
+			StartDate:    booking.StartDate,
+			EndDate:      booking.EndDate,
+			Status:       string(booking.Status),
+			StatusReason: booking.StatusReason,
+		})
+	}
+
+	response := model.BookingResponse{
+		Message: "success get all lessor booking",
+		Data:    bookingData,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (u *BookingHandler) ApproveBooking(c echo.Context) error {
+	return u.lessorTransition(c, model.Approved)
+}
+
+func (u *BookingHandler) RejectBooking(c echo.Context) error {
+	return u.lessorTransition(c, model.Rejected)
+}
+
+func (u *BookingHandler) HandOverBooking(c echo.Context) error {
+	return u.lessorTransition(c, model.Active)
+}
+
+func (u *BookingHandler) ReturnBooking(c echo.Context) error {
+	return u.lessorTransition(c, model.Returned)
+}
+
+func (u *BookingHandler) lessorTransition(c echo.Context, status model.BookingStatus) error {
+	var decisionReq model.BookingReturnRequest
+	if err := c.Bind(&decisionReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := u.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "user is not a lessor")
+	}
+
+	var booking *model.Bookings
+	switch status {
+	case model.Approved:
+		booking, err = u.bookingUsecase.ApproveBooking(id, lessor.LessorID, userID, decisionReq.Reason)
+	case model.Rejected:
+		booking, err = u.bookingUsecase.RejectBooking(id, lessor.LessorID, userID, decisionReq.Reason)
+	case model.Active:
+		booking, err = u.bookingUsecase.HandOverBooking(id, lessor.LessorID, userID, decisionReq.Reason)
+	case model.Returned:
+		booking, err = u.bookingUsecase.ReturnBooking(id, lessor.LessorID, userID, decisionReq.Reason,
+			decisionReq.DepositCapture, decisionReq.DamageNote)
+	}
+	if err != nil {
+		return bookingError(err)
+	}
+
+	var totalPay string
+	if quote, err := u.pricingUsecase.Quote(booking, &booking.Products); err == nil {
+		totalPay = currency.Format(quote.Amount, quote.Currency)
+	}
+
+	renter := booking.Users
+	go func() {
+		err := utils.SendBookingNotification(renter.Email, renter.Name, string(booking.Status), booking.BookingID, totalPay)
+		if err != nil {
+			fmt.Printf("failed to send booking notification: %v\n", err)
+		}
+	}()
+
+	bookingData := model.BookingData{
+		BookingID:    booking.BookingID,
+		ProductName:  booking.Products.Name,
+		RenterName:   booking.Users.Name,
+		StartDate:    booking.StartDate,
+		EndDate:      booking.EndDate,
+		Status:       string(booking.Status),
+		StatusReason: booking.StatusReason,
+		Deposit:      booking.Deposits,
+	}
+
+	response := model.BookingResponse{
+		Message: "success " + strings.ToLower(string(status)) + " booking",
+		Data:    []model.BookingData{bookingData},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (u *BookingHandler) GetBookingHistory(c echo.Context) error {
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if _, err := u.bookingUsecase.GetBookingByID(id, userID); err != nil {
+		return bookingError(err)
+	}
+
+	return u.bookingHistory(c, id)
+}
+
+func (u *BookingHandler) GetLessorBookingHistory(c echo.Context) error {
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := u.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "user is not a lessor")
+	}
+
+	if _, err := u.bookingUsecase.GetBookingByLessor(id, lessor.LessorID); err != nil {
+		return bookingError(err)
+	}
+
+	return u.bookingHistory(c, id)
+}
+
+func (u *BookingHandler) bookingHistory(c echo.Context, bookingID int) error {
+	history, err := u.bookingUsecase.GetBookingHistory(bookingID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.BookingHistoryResponse{
+		Message: "success get booking history",
+		Data:    history,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func createBookingError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "product not found")
+	case errors.Is(err, usecase.ErrInvalidBooking):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, repository.ErrNoUnitAvailable):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, currency.ErrNoRate):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func bookingError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "booking not found")
+	case errors.Is(err, repository.ErrInvalidTransition):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, repository.ErrCaptureExceedsDeposit):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, usecase.ErrInvalidBooking):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/usecase"
+	"rent-video-game/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+type CancellationHandler struct {
+	cancellationUsecase *usecase.CancellationUsecase
+	lessorUsecase       *usecase.LessorUsecase
+	idempotencyUsecase  *usecase.IdempotencyUsecase
+}
+
+func NewCancellationHandler(cancellationUsecase *usecase.CancellationUsecase, lessorUsecase *usecase.LessorUsecase, idempotencyUsecase *usecase.IdempotencyUsecase) *CancellationHandler {
+	return &CancellationHandler{
+		cancellationUsecase: cancellationUsecase,
+		lessorUsecase:       lessorUsecase,
+		idempotencyUsecase:  idempotencyUsecase,
+	}
+}
+
+func (h *CancellationHandler) CancellationRoutes(e *echo.Echo) {
+	e.POST("/user/booking/:booking_id/cancel", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(middleware.IdempotencyMiddleware(h.idempotencyUsecase)(h.CancelBooking))))
+	e.GET("/lessor/cancellation-policy", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(h.GetPolicy)))
+	e.PUT("/lessor/cancellation-policy", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(h.UpdatePolicy)))
+}
+
+func (h *CancellationHandler) CancelBooking(c echo.Context) error {
+	var decisionReq model.BookingDecisionRequest
+	if err := c.Bind(&decisionReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	result, percent, err := h.cancellationUsecase.CancelBooking(id, userID, decisionReq.Reason)
+	if err != nil {
+		if errors.Is(err, repository.ErrInsufficientBalance) {
+			return echo.NewHTTPError(http.StatusConflict, "refund could not be collected from the lessor")
+		}
+		return bookingError(err)
+	}
+
+	booking := result.Booking
+	go func() {
+		for _, user := range []model.Users{booking.Users, booking.Products.Lessors.Users} {
+			if user.Email == "" {
+				continue
+			}
+
+			err := utils.SendBookingStatusNotification(user.Email, user.Name, booking.BookingID, booking.Products.Name, string(booking.Status), booking.StatusReason)
+			if err != nil {
+				fmt.Printf("failed to send booking notification: %v\n", err)
+			}
+		}
+	}()
+
+	cancellationData := model.CancellationData{
+		BookingID: booking.BookingID,
+		Status:    string(booking.Status),
+	}
+	if result.Reversal != nil {
+		cancellationData.RefundPercent = percent
+		cancellationData.Currency = result.Reversal.Currency
+		cancellationData.RefundAmount = result.Reversal.Amount
+		cancellationData.ReversalTransactionID = result.Reversal.TransactionID
+	}
+	if result.Deposit != nil {
+		cancellationData.Currency = result.Deposit.Currency
+		cancellationData.DepositReleased = result.Deposit.Released
+	}
+
+	response := model.CancellationResponse{
+		Message: "success cancel booking",
+		Data:    cancellationData,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *CancellationHandler) GetPolicy(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := h.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "user is not a lessor")
+	}
+
+	policy, err := h.cancellationUsecase.GetPolicyByLessor(lessor.LessorID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.CancellationPolicyResponse{
+		Message: "success get cancellation policy",
+		Data:    *policy,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *CancellationHandler) UpdatePolicy(c echo.Context) error {
+	var policyReq model.CancellationPolicyRequest
+	if err := c.Bind(&policyReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := h.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "user is not a lessor")
+	}
+
+	policy, err := h.cancellationUsecase.SavePolicy(&model.CancellationPolicies{
+		LessorID:             lessor.LessorID,
+		FullRefundDays:       policyReq.FullRefundDays,
+		PartialRefundPercent: policyReq.PartialRefundPercent,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	response := model.CancellationPolicyResponse{
+		Message: "success update cancellation policy",
+		Data:    *policy,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
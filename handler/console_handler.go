@@ -1,23 +1,35 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
+	"rent-video-game/utils"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type ConsoleHandler struct {
-	consoleUsecase *usecase.ConsoleUsecase
+	consoleUsecase  *usecase.ConsoleUsecase
+	auditLogUsecase *usecase.AuditLogUsecase
 }
 
-func NewConsoleHandler(consoleUsecase *usecase.ConsoleUsecase) *ConsoleHandler {
-	return &ConsoleHandler{consoleUsecase: consoleUsecase}
+func NewConsoleHandler(consoleUsecase *usecase.ConsoleUsecase, auditLogUsecase *usecase.AuditLogUsecase) *ConsoleHandler {
+	return &ConsoleHandler{consoleUsecase: consoleUsecase, auditLogUsecase: auditLogUsecase}
 }
 
 func (u *ConsoleHandler) ConsoleRoutes(e *echo.Echo) {
 	e.GET("/consoles", u.GetAllConsole)
+
+	e.POST("/admin/console", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermConsoleManage)(u.CreateConsole)))
+	e.PUT("/admin/console/:console_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermConsoleManage)(u.RenameConsole)))
+	e.POST("/admin/console/:console_id/merge", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermConsoleManage)(u.MergeConsole)))
+	e.DELETE("/admin/console/:console_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermConsoleManage)(u.DeleteConsole)))
+	e.GET("/admin/audit-logs", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermAuditLogRead)(u.GetAllAuditLog)))
 }
 
 func (u *ConsoleHandler) GetAllConsole(c echo.Context) error {
@@ -42,3 +54,117 @@ func (u *ConsoleHandler) GetAllConsole(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, consoleResponse)
 }
+
+func (u *ConsoleHandler) CreateConsole(c echo.Context) error {
+	var consoleReq model.ConsoleRequest
+	if err := c.Bind(&consoleReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
+
+	console, err := u.consoleUsecase.CreateConsole(consoleReq.Name, userID)
+	if err != nil {
+		return consoleError(err)
+	}
+
+	return c.JSON(http.StatusCreated, consoleResponse("success create console", console))
+}
+
+func (u *ConsoleHandler) RenameConsole(c echo.Context) error {
+	var consoleReq model.ConsoleRequest
+	if err := c.Bind(&consoleReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
+
+	console, err := u.consoleUsecase.RenameConsole(utils.StringToInt(c.Param("console_id")), consoleReq.Name, userID)
+	if err != nil {
+		return consoleError(err)
+	}
+
+	return c.JSON(http.StatusOK, consoleResponse("success rename console", console))
+}
+
+// MergeConsole moves the products of the console in the path onto the target
+// console and deletes it.
+func (u *ConsoleHandler) MergeConsole(c echo.Context) error {
+	var mergeReq model.ConsoleMergeRequest
+	if err := c.Bind(&mergeReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := u.consoleUsecase.MergeConsole(utils.StringToInt(c.Param("console_id")), mergeReq.TargetConsoleID, userID)
+	if err != nil {
+		return consoleError(err)
+	}
+
+	response := model.ConsoleMergeResponse{
+		Message: "success merge console",
+	}
+	response.Data.ConsoleID = result.Console.ConsoleID
+	response.Data.Name = result.Console.Name
+	response.Data.MergedConsoleID = result.MergedID
+	response.Data.MovedProducts = result.MovedProducts
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (u *ConsoleHandler) DeleteConsole(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
+
+	console, err := u.consoleUsecase.DeleteConsole(utils.StringToInt(c.Param("console_id")), userID)
+	if err != nil {
+		return consoleError(err)
+	}
+
+	return c.JSON(http.StatusOK, consoleResponse("success delete console", console))
+}
+
+func (u *ConsoleHandler) GetAllAuditLog(c echo.Context) error {
+	logs, err := u.auditLogUsecase.GetAllAuditLog(c.QueryParam("entity_type"), utils.StringToInt(c.QueryParam("entity_id")))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, model.AuditLogResponse{
+		Message: "success get audit logs",
+		Data:    logs,
+	})
+}
+
+func consoleResponse(message string, console *model.Consoles) model.ConsoleResponse {
+	return model.ConsoleResponse{
+		Message: message,
+		Data: []model.ConsoleData{{
+			ConsoleID: console.ConsoleID,
+			Name:      console.Name,
+		}},
+	}
+}
+
+func consoleError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "console not found")
+	case errors.Is(err, repository.ErrConsoleNameTaken), errors.Is(err, repository.ErrConsoleInUse):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"rent-video-game/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type DepositHandler struct {
+	depositUsecase *usecase.DepositUsecase
+	bookingUsecase *usecase.BookingUsecase
+	lessorUsecase  *usecase.LessorUsecase
+}
+
+func NewDepositHandler(depositUsecase *usecase.DepositUsecase, bookingUsecase *usecase.BookingUsecase, lessorUsecase *usecase.LessorUsecase) *DepositHandler {
+	return &DepositHandler{
+		depositUsecase: depositUsecase,
+		bookingUsecase: bookingUsecase,
+		lessorUsecase:  lessorUsecase,
+	}
+}
+
+func (h *DepositHandler) DepositRoutes(e *echo.Echo) {
+	e.GET("/user/booking/:booking_id/deposit", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(h.GetDeposit)))
+	e.GET("/lessor/booking/:booking_id/deposit", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingManage)(h.GetLessorDeposit)))
+}
+
+func (h *DepositHandler) GetDeposit(c echo.Context) error {
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	if _, err := h.bookingUsecase.GetBookingByID(id, userID); err != nil {
+		return bookingError(err)
+	}
+
+	return h.deposit(c, id)
+}
+
+func (h *DepositHandler) GetLessorDeposit(c echo.Context) error {
+	bookingID := c.Param("booking_id")
+	id := utils.StringToInt(bookingID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := h.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "user is not a lessor")
+	}
+
+	if _, err := h.bookingUsecase.GetBookingByLessor(id, lessor.LessorID); err != nil {
+		return bookingError(err)
+	}
+
+	return h.deposit(c, id)
+}
+
+func (h *DepositHandler) deposit(c echo.Context, bookingID int) error {
+	deposit, err := h.depositUsecase.GetDepositByBooking(bookingID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "booking has no deposit")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.DepositResponse{
+		Message: "success get deposit",
+		Data:    *deposit,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"rent-video-game/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+type JWKSHandler struct{}
+
+func NewJWKSHandler() *JWKSHandler {
+	return &JWKSHandler{}
+}
+
+func (h *JWKSHandler) JWKSRoutes(e *echo.Echo) {
+	e.GET("/.well-known/jwks.json", h.GetJWKS)
+}
+
+// GetJWKS publishes the token verification keys. Verifiers may cache them
+// briefly; a new key is published before it signs anything, see
+// cmd/jwt-keygen.
+func (h *JWKSHandler) GetJWKS(c echo.Context) error {
+	jwks, err := utils.CurrentJWKS()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, jwks)
+}
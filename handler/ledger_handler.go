@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+
+	"github.com/labstack/echo/v4"
+)
+
+type LedgerHandler struct {
+	ledgerUsecase *usecase.LedgerUsecase
+}
+
+func NewLedgerHandler(ledgerUsecase *usecase.LedgerUsecase) *LedgerHandler {
+	return &LedgerHandler{ledgerUsecase: ledgerUsecase}
+}
+
+func (h *LedgerHandler) LedgerRoutes(e *echo.Echo) {
+	e.GET("/user/ledger", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(h.GetStatementByUser)))
+	e.GET("/admin/ledger/reconciliation", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermLedgerAudit)(h.Reconcile)))
+}
+
+func (h *LedgerHandler) GetStatementByUser(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	statement, err := h.ledgerUsecase.GetStatementByUser(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.LedgerStatementResponse{
+		Message: "success get ledger statement",
+		Data:    statement,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *LedgerHandler) Reconcile(c echo.Context) error {
+	rows, err := h.ledgerUsecase.Reconcile()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	balanced := true
+	for _, row := range rows {
+		if !row.Balanced {
+			balanced = false
+		}
+	}
+
+	response := model.LedgerReconciliationResponse{
+		Message:  "success reconcile ledger",
+		Balanced: balanced,
+		Data:     rows,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
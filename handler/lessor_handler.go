@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
@@ -8,21 +9,23 @@ import (
 	"rent-video-game/utils"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type LessorHandler struct {
 	lessorUsecase *usecase.LessorUsecase
+	tokenUsecase  *usecase.TokenUsecase
 }
 
-func NewLessorHandler(lessorUsecase *usecase.LessorUsecase) *LessorHandler {
-	return &LessorHandler{lessorUsecase: lessorUsecase}
+func NewLessorHandler(lessorUsecase *usecase.LessorUsecase, tokenUsecase *usecase.TokenUsecase) *LessorHandler {
+	return &LessorHandler{lessorUsecase: lessorUsecase, tokenUsecase: tokenUsecase}
 }
 
 func (u *LessorHandler) LessorRoutes(e *echo.Echo) {
-	e.POST("/lessor/register", middleware.UserAuthMiddleware()(u.RegisterLessor))
-	e.GET("/lessor/:lessor_id", middleware.UserAuthMiddleware()(u.GetLessorByID))
-	e.PUT("/lessor/:lessor_id", middleware.UserAuthMiddleware()(u.UpdateLessor))
-	e.DELETE("/lessor/:lessor_id", middleware.UserAuthMiddleware()(u.DeleteLessor))
+	e.POST("/lessor/register", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermLessorRegister)(u.RegisterLessor)))
+	e.GET("/lessor/:lessor_id", middleware.UserAuthMiddleware()(middleware.RequireRole(model.RoleLessor)(u.GetLessorByID)))
+	e.PUT("/lessor/:lessor_id", middleware.UserAuthMiddleware()(middleware.RequireRole(model.RoleLessor)(u.UpdateLessor)))
+	e.DELETE("/lessor/:lessor_id", middleware.UserAuthMiddleware()(middleware.RequireRole(model.RoleLessor)(u.DeleteLessor)))
 }
 
 func (u *LessorHandler) RegisterLessor(c echo.Context) error {
@@ -48,6 +51,12 @@ func (u *LessorHandler) RegisterLessor(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	// the current token predates the lessor role
+	token, err := u.tokenUsecase.IssueAccessToken(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate token")
+	}
+
 	response := model.LessorResponse{
 		Message: "success register lessor",
 	}
@@ -55,6 +64,7 @@ func (u *LessorHandler) RegisterLessor(c echo.Context) error {
 	response.Data.LessorID = lessor.LessorID
 	response.Data.Name = lessor.Name
 	response.Data.Location = lessor.Location
+	response.Data.Token = token
 
 	return c.JSON(http.StatusOK, response)
 }
@@ -138,8 +148,11 @@ func (u *LessorHandler) DeleteLessor(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	lessor, err := u.lessorUsecase.DeleteLessor(id)
+	lessor, err := u.lessorUsecase.GetLessorByID(id)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "lessor not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -147,6 +160,11 @@ func (u *LessorHandler) DeleteLessor(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "forbidden access")
 	}
 
+	lessor, err = u.lessorUsecase.DeleteLessor(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
 	response := model.LessorResponse{
 		Message: "success delete lessor",
 	}
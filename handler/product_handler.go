@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"rent-video-game/currency"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
 	"rent-video-game/utils"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type ProductHandler struct {
@@ -29,13 +36,16 @@ func NewProductHandler(
 }
 
 func (u *ProductHandler) ProductRoutes(e *echo.Echo) {
-	e.POST("/lessor/product", middleware.UserAuthMiddleware()(u.RegisterProduct))
-	e.GET("/lessor/product/:product_id", middleware.UserAuthMiddleware()(u.GetProductByID))
-	e.GET("/lessor/products", middleware.UserAuthMiddleware()(u.GetAllProductsByLessor))
-	e.PUT("/lessor/product/:product_id", middleware.UserAuthMiddleware()(u.UpdateProduct))
-	e.DELETE("/lessor/product/:product_id", middleware.UserAuthMiddleware()(u.DeleteProduct))
+	e.POST("/lessor/product", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(u.RegisterProduct)))
+	e.GET("/lessor/product/:product_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(u.GetProductByID)))
+	e.GET("/lessor/products", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(u.GetAllProductsByLessor)))
+	e.PUT("/lessor/product/:product_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(u.UpdateProduct)))
+	e.DELETE("/lessor/product/:product_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(u.DeleteProduct)))
 
 	e.GET("/products", u.GetAllProducts)
+	e.GET("/products/search", u.SearchProducts)
+	e.GET("/products/:product_id", u.GetPublicProductByID)
+	e.GET("/products/:product_id/availability", u.GetProductAvailability)
 }
 
 func (u *ProductHandler) RegisterProduct(c echo.Context) error {
@@ -63,6 +73,8 @@ func (u *ProductHandler) RegisterProduct(c echo.Context) error {
 		Name:               productReq.Name,
 		Description:        productReq.Description,
 		RentalCostPerMonth: productReq.RentalCostPerMonth,
+		DepositAmount:      productReq.DepositAmount,
+		LateFeePerDay:      productReq.LateFeePerDay,
 		StockAvailability:  productReq.StockAvailability,
 	}
 
@@ -70,6 +82,9 @@ func (u *ProductHandler) RegisterProduct(c echo.Context) error {
 
 	product, err = u.productUsecase.RegisterProduct(product)
 	if err != nil {
+		if errors.Is(err, repository.ErrUnknownConsole) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -78,7 +93,10 @@ func (u *ProductHandler) RegisterProduct(c echo.Context) error {
 		ConsoleName:        product.Consoles.Name,
 		Name:               product.Name,
 		Description:        product.Description,
+		Currency:           product.Currency,
 		RentalCostPerMonth: product.RentalCostPerMonth,
+		DepositAmount:      product.DepositAmount,
+		LateFeePerDay:      product.LateFeePerDay,
 		StockAvailability:  product.StockAvailability,
 	}
 
@@ -113,7 +131,10 @@ func (u *ProductHandler) GetProductByID(c echo.Context) error {
 		ProductID:          product.ProductID,
 		Name:               product.Name,
 		Description:        product.Description,
+		Currency:           product.Currency,
 		RentalCostPerMonth: product.RentalCostPerMonth,
+		DepositAmount:      product.DepositAmount,
+		LateFeePerDay:      product.LateFeePerDay,
 		StockAvailability:  product.StockAvailability,
 	}
 
@@ -143,19 +164,16 @@ func (u *ProductHandler) GetAllProductsByLessor(c echo.Context) error {
 
 	var productData []model.ProductData
 	for _, value := range products {
-
-		stars, err := u.ratingUsecase.GetAverageRatingByProduct(value.ProductID)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-		}
-
 		productData = append(productData, model.ProductData{
 			ProductID:          value.ProductID,
 			ConsoleName:        value.Consoles.Name,
 			Name:               value.Name,
 			Description:        value.Description,
+			Currency:           value.Currency,
 			RentalCostPerMonth: value.RentalCostPerMonth,
-			Stars:              stars,
+			DepositAmount:      value.DepositAmount,
+			LateFeePerDay:      value.LateFeePerDay,
+			Stars:              value.RatingStats.AverageStars,
 			StockAvailability:  value.StockAvailability,
 		})
 	}
@@ -194,6 +212,12 @@ func (u *ProductHandler) UpdateProduct(c echo.Context) error {
 	product.LessorID = lessor.LessorID
 	product, err = u.productUsecase.UpdateProduct(id, product)
 	if err != nil {
+		if errors.Is(err, repository.ErrStockInUse) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		if errors.Is(err, repository.ErrUnknownConsole) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
@@ -202,7 +226,10 @@ func (u *ProductHandler) UpdateProduct(c echo.Context) error {
 		ConsoleName:        product.Consoles.Name,
 		Name:               product.Name,
 		Description:        product.Description,
+		Currency:           product.Currency,
 		RentalCostPerMonth: product.RentalCostPerMonth,
+		DepositAmount:      product.DepositAmount,
+		LateFeePerDay:      product.LateFeePerDay,
 		StockAvailability:  product.StockAvailability,
 	}
 
@@ -237,7 +264,10 @@ func (u *ProductHandler) DeleteProduct(c echo.Context) error {
 		ProductID:          product.ProductID,
 		Name:               product.Name,
 		Description:        product.Description,
+		Currency:           product.Currency,
 		RentalCostPerMonth: product.RentalCostPerMonth,
+		DepositAmount:      product.DepositAmount,
+		LateFeePerDay:      product.LateFeePerDay,
 		StockAvailability:  product.StockAvailability,
 	}
 
@@ -249,33 +279,232 @@ func (u *ProductHandler) DeleteProduct(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
+// GetAllProducts serves the public catalogue. Every query parameter is
+// optional: console_id, min_price, max_price, currency (of the price bounds),
+// min_stars, in_stock, location, sort (newest, price_asc, price_desc, rating),
+// limit and cursor, the next_cursor of the previous page.
 func (u *ProductHandler) GetAllProducts(c echo.Context) error {
-	products, err := u.productUsecase.GetAllProducts()
+	filter, err := productFilter(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	var productData []model.ProductPublicData
-	for _, value := range products {
-
-		stars, err := u.ratingUsecase.GetAverageRatingByProduct(value.ProductID)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	page, err := u.productUsecase.SearchProducts(filter, c.QueryParam("cursor"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidProductFilter) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
 
+	productData := []model.ProductPublicData{}
+	for _, value := range page.Products {
 		productData = append(productData, model.ProductPublicData{
 			ProductID:          value.ProductID,
+			ConsoleID:          value.ConsoleID,
 			Name:               value.Name,
+			Currency:           value.Currency,
 			RentalCostPerMonth: value.RentalCostPerMonth,
-			Stars:              stars,
+			DepositAmount:      value.DepositAmount,
+			LateFeePerDay:      value.LateFeePerDay,
+			Stars:              value.Stars,
+			RatingCount:        value.RatingCount,
 			StockAvailability:  value.StockAvailability,
-			Location:           value.Lessors.Location,
+			Location:           value.Location,
 		})
 	}
 
-	response := model.ProductPublicResponse{
-		Message: "success get all products",
-		Data:    productData,
+	response := model.ProductPageResponse{
+		Message:    "success get all products",
+		Data:       productData,
+		NextCursor: page.NextCursor,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetPublicProductByID serves the public product page, with the console, the
+// lessor's profile and the product's rating summary.
+func (u *ProductHandler) GetPublicProductByID(c echo.Context) error {
+	productID := c.Param("product_id")
+	id := utils.StringToInt(productID)
+
+	product, err := u.productUsecase.GetPublicProductByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "product not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessorRating, err := u.ratingUsecase.GetRatingSummaryByLessor(product.LessorID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	detail := model.ProductDetailData{
+		ProductData: model.ProductData{
+			ProductID:          product.ProductID,
+			ConsoleName:        product.Consoles.Name,
+			Name:               product.Name,
+			Description:        product.Description,
+			Currency:           product.Currency,
+			RentalCostPerMonth: product.RentalCostPerMonth,
+			DepositAmount:      product.DepositAmount,
+			LateFeePerDay:      product.LateFeePerDay,
+			Stars:              product.RatingStats.AverageStars,
+			StockAvailability:  product.StockAvailability,
+		},
+		Console: model.ConsoleData{
+			ConsoleID: product.Consoles.ConsoleID,
+			Name:      product.Consoles.Name,
+		},
+		Lessor: model.LessorPublicData{
+			LessorID:    product.Lessors.LessorID,
+			Name:        product.Lessors.Name,
+			Location:    product.Lessors.Location,
+			MemberSince: product.Lessors.CreatedAt,
+			Rating:      *lessorRating,
+		},
+		Rating: product.RatingStats.Summary(),
+	}
+
+	response := model.ProductDetailResponse{
+		Message: "success get product",
+		Data:    detail,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// SearchProducts runs a full-text search for q, falling back to a fuzzy
+// match on product names when nothing matches every word.
+func (u *ProductHandler) SearchProducts(c echo.Context) error {
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be a number")
+		}
+	}
+
+	results, match, err := u.productUsecase.SearchProductsByText(c.QueryParam("q"), limit)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidProductFilter) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	searchData := []model.ProductSearchData{}
+	for _, value := range results {
+		searchData = append(searchData, model.ProductSearchData{
+			ProductPublicData: model.ProductPublicData{
+				ProductID:          value.ProductID,
+				ConsoleID:          value.ConsoleID,
+				Name:               value.Name,
+				Currency:           value.Currency,
+				RentalCostPerMonth: value.RentalCostPerMonth,
+				DepositAmount:      value.DepositAmount,
+				LateFeePerDay:      value.LateFeePerDay,
+				Stars:              value.Stars,
+				StockAvailability:  value.StockAvailability,
+				Location:           value.Location,
+			},
+			ConsoleName:   value.ConsoleName,
+			Rank:          value.Rank,
+			Match:         match,
+			NameHighlight: value.NameHighlight,
+			Snippet:       value.Snippet,
+		})
+	}
+
+	response := model.ProductSearchResponse{
+		Message: "success search products",
+		Data:    searchData,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func productFilter(c echo.Context) (*model.ProductFilter, error) {
+	filter := &model.ProductFilter{
+		Location: strings.TrimSpace(c.QueryParam("location")),
+		Sort:     model.ProductSort(c.QueryParam("sort")),
+	}
+
+	var err error
+	if value := c.QueryParam("console_id"); value != "" {
+		if filter.ConsoleID, err = strconv.Atoi(value); err != nil {
+			return nil, errors.New("console_id must be a number")
+		}
+	}
+	if value := c.QueryParam("min_price"); value != "" {
+		price, err := currency.ParseMoney(value)
+		if err != nil {
+			return nil, fmt.Errorf("min_price: %w", err)
+		}
+		filter.MinPrice = &price
+	}
+	if value := c.QueryParam("max_price"); value != "" {
+		price, err := currency.ParseMoney(value)
+		if err != nil {
+			return nil, fmt.Errorf("max_price: %w", err)
+		}
+		filter.MaxPrice = &price
+	}
+	if value := c.QueryParam("currency"); value != "" {
+		if filter.Currency, err = currency.Parse(value); err != nil {
+			return nil, err
+		}
+	}
+	if value := c.QueryParam("min_stars"); value != "" {
+		if filter.MinStars, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, errors.New("min_stars must be a number")
+		}
+	}
+	if value := c.QueryParam("in_stock"); value != "" {
+		if filter.InStock, err = strconv.ParseBool(value); err != nil {
+			return nil, errors.New("in_stock must be true or false")
+		}
+	}
+	if value := c.QueryParam("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return nil, errors.New("limit must be a number")
+		}
+	}
+
+	return filter, nil
+}
+
+func (u *ProductHandler) GetProductAvailability(c echo.Context) error {
+	productID := c.Param("product_id")
+	id := utils.StringToInt(productID)
+
+	from := c.QueryParam("from")
+	to := c.QueryParam("to")
+	if from == "" || to == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "from and to are required")
+	}
+
+	if _, err := u.productUsecase.GetPublicProductByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "product not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	availability, err := u.productUsecase.GetAvailability(id, from, to)
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidDateRange) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.AvailabilityResponse{
+		Message: "success get product availability",
+		Data:    availability,
 	}
 
 	return c.JSON(http.StatusOK, response)
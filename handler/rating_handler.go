@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
 	"rent-video-game/utils"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -15,15 +17,19 @@ import (
 
 type RatingHandler struct {
 	ratingUsecase *usecase.RatingUsecase
+	lessorUsecase *usecase.LessorUsecase
 }
 
-func NewRatingHandler(ratingUsecase *usecase.RatingUsecase) *RatingHandler {
-	return &RatingHandler{ratingUsecase}
+func NewRatingHandler(ratingUsecase *usecase.RatingUsecase, lessorUsecase *usecase.LessorUsecase) *RatingHandler {
+	return &RatingHandler{ratingUsecase, lessorUsecase}
 }
 
 func (h *RatingHandler) RatingRoutes(e *echo.Echo) {
-	e.POST("/user/rating", middleware.UserAuthMiddleware()(h.CreateRating))
-	e.GET("/lessor/rating/product/:product_id", middleware.UserAuthMiddleware()(h.GetAllRatingByProduct))
+	e.POST("/user/rating", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermRatingCreate)(h.CreateRating)))
+	e.PUT("/user/rating/:rating_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermRatingCreate)(h.UpdateRating)))
+	e.DELETE("/user/rating/:rating_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermRatingCreate)(h.DeleteRating)))
+	e.POST("/lessor/rating/:rating_id/reply", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermProductManage)(h.ReplyToRating)))
+	e.GET("/products/:product_id/ratings", h.GetRatingsByProduct)
 }
 
 func (h *RatingHandler) CreateRating(c echo.Context) error {
@@ -58,48 +64,154 @@ func (h *RatingHandler) CreateRating(c echo.Context) error {
 
 	rating.UserID = userID
 	rating, err = h.ratingUsecase.CreateRating(rating)
+	if err != nil {
+		return ratingError(err)
+	}
+
+	response := model.RatingResponse{
+		Message: "success create rating",
+		Data:    []model.RatingData{ratingData(rating)},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *RatingHandler) UpdateRating(c echo.Context) error {
+	var ratingReq *model.RatingUpdateRequest
+	if err := c.Bind(&ratingReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	ratingData := model.RatingData{
-		RatingID:  rating.RatingID,
-		ProductID: rating.ProductID,
-		Review:    rating.Review,
-		Stars:     rating.Stars,
+	ratingID := utils.StringToInt(c.Param("rating_id"))
+	rating, err := h.ratingUsecase.UpdateRating(ratingID, userID, ratingReq.Review, ratingReq.Stars)
+	if err != nil {
+		return ratingError(err)
 	}
 
 	response := model.RatingResponse{
-		Message: "success create rating",
-		Data:    []model.RatingData{ratingData},
+		Message: "success update rating",
+		Data:    []model.RatingData{ratingData(rating)},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *RatingHandler) DeleteRating(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	ratingID := utils.StringToInt(c.Param("rating_id"))
+	if err := h.ratingUsecase.DeleteRating(ratingID, userID); err != nil {
+		return ratingError(err)
+	}
+
+	response := model.RatingResponse{
+		Message: "success delete rating",
+		Data:    []model.RatingData{},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// ReplyToRating posts the lessor's public reply to a rating of one of their
+// products. Each rating takes one reply.
+func (h *RatingHandler) ReplyToRating(c echo.Context) error {
+	var replyReq *model.RatingReplyRequest
+	if err := c.Bind(&replyReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	lessor, err := h.lessorUsecase.GetLessorByUserID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	ratingID := utils.StringToInt(c.Param("rating_id"))
+	rating, err := h.ratingUsecase.ReplyToRating(ratingID, lessor.LessorID, replyReq.Reply)
+	if err != nil {
+		return ratingError(err)
+	}
+
+	response := model.RatingResponse{
+		Message: "success reply to rating",
+		Data:    []model.RatingData{ratingData(rating)},
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
 
-func (h *RatingHandler) GetAllRatingByProduct(c echo.Context) error {
+// GetRatingsByProduct lists a product's ratings, newest first. Pages hold
+// limit ratings; cursor is the next_cursor of the previous page.
+func (h *RatingHandler) GetRatingsByProduct(c echo.Context) error {
 	productID := c.Param("product_id")
 	id := utils.StringToInt(productID)
 
-	ratings, err := h.ratingUsecase.GetAllRatingByProduct(id)
+	limit := 0
+	if value := c.QueryParam("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "limit must be a number")
+		}
+	}
+
+	page, err := h.ratingUsecase.GetRatingsByProduct(id, limit, c.QueryParam("cursor"))
 	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidRatingPage) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	var ratingData []model.RatingData
-	for _, rating := range ratings {
-		ratingData = append(ratingData, model.RatingData{
-			RatingID:  rating.RatingID,
-			ProductID: rating.ProductID,
-			Review:    rating.Review,
-			Stars:     rating.Stars,
-		})
+	data := []model.RatingData{}
+	for i := range page.Ratings {
+		data = append(data, ratingData(&page.Ratings[i]))
 	}
 
-	response := model.RatingResponse{
-		Message: "success get all rating by product",
-		Data:    ratingData,
+	response := model.RatingPageResponse{
+		Message:    "success get all rating by product",
+		Data:       data,
+		NextCursor: page.NextCursor,
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
+
+func ratingData(rating *model.Ratings) model.RatingData {
+	return model.RatingData{
+		RatingID:  rating.RatingID,
+		ProductID: rating.ProductID,
+		Review:    rating.Review,
+		Stars:     rating.Stars,
+		Reply:     rating.Reply,
+		RepliedAt: rating.RepliedAt,
+		CreatedAt: rating.CreatedAt,
+	}
+}
+
+func ratingError(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidRating):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, usecase.ErrRatingNotRented), errors.Is(err, usecase.ErrRatingNotAuthor),
+		errors.Is(err, usecase.ErrRatingEditWindowClosed), errors.Is(err, repository.ErrRatingNotOwned):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, repository.ErrRatingAlreadyReplied):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "rating not found")
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
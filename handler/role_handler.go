@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/usecase"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type RoleHandler struct {
+	roleUsecase *usecase.RoleUsecase
+	userUsecase *usecase.UserUsecase
+}
+
+func NewRoleHandler(roleUsecase *usecase.RoleUsecase, userUsecase *usecase.UserUsecase) *RoleHandler {
+	return &RoleHandler{roleUsecase: roleUsecase, userUsecase: userUsecase}
+}
+
+func (h *RoleHandler) RoleRoutes(e *echo.Echo) {
+	e.GET("/admin/roles", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermUserManage)(h.GetAllRoles)))
+	e.GET("/admin/user/:user_id/roles", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermUserManage)(h.GetUserRoles)))
+	e.PUT("/admin/user/:user_id/role", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermUserManage)(h.AssignRole)))
+	e.DELETE("/admin/user/:user_id/role/:role", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermUserManage)(h.RevokeRole)))
+}
+
+func (h *RoleHandler) GetAllRoles(c echo.Context) error {
+	roles, err := h.roleUsecase.GetAllRoles()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, model.RoleResponse{
+		Message: "success get roles",
+		Data:    roles,
+	})
+}
+
+func (h *RoleHandler) GetUserRoles(c echo.Context) error {
+	userID, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	return h.userRoleResponse(c, "success get user roles", userID)
+}
+
+// AssignRole grants a role. The user's current token keeps its old roles until
+// they log in again.
+func (h *RoleHandler) AssignRole(c echo.Context) error {
+	var roleReq model.UserRoleRequest
+	if err := c.Bind(&roleReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleUsecase.AssignRole(userID, roleReq.Role); err != nil {
+		return roleError(err)
+	}
+
+	return h.userRoleResponse(c, "success assign role", userID)
+}
+
+func (h *RoleHandler) RevokeRole(c echo.Context) error {
+	userID, err := h.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.roleUsecase.RevokeRole(userID, model.RoleName(c.Param("role"))); err != nil {
+		return roleError(err)
+	}
+
+	return h.userRoleResponse(c, "success revoke role", userID)
+}
+
+func (h *RoleHandler) targetUser(c echo.Context) (uuid.UUID, error) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return uuid.Nil, echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+	}
+
+	if _, err := h.userUsecase.GetUserByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return uuid.Nil, echo.NewHTTPError(http.StatusNotFound, "user not found")
+		}
+		return uuid.Nil, echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return userID, nil
+}
+
+func (h *RoleHandler) userRoleResponse(c echo.Context, message string, userID uuid.UUID) error {
+	access, err := h.roleUsecase.GetAccess(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.UserRoleResponse{
+		Message: message,
+	}
+	response.Data.UserID = userID
+	response.Data.Access = *access
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func roleError(err error) error {
+	if errors.Is(err, repository.ErrRoleNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+}
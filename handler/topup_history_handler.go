@@ -1,41 +1,64 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
 	"rent-video-game/utils"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type TopupHistoryHandler struct {
 	TopupHistoryUsecase *usecase.TopupHistoryUsecase
+	TopupUsecase        *usecase.TopupUsecase
+	IdempotencyUsecase  *usecase.IdempotencyUsecase
 }
 
-func NewTopupHistoryHandler(topupHistoryUsecase *usecase.TopupHistoryUsecase) *TopupHistoryHandler {
-	return &TopupHistoryHandler{TopupHistoryUsecase: topupHistoryUsecase}
+func NewTopupHistoryHandler(topupHistoryUsecase *usecase.TopupHistoryUsecase, topupUsecase *usecase.TopupUsecase, idempotencyUsecase *usecase.IdempotencyUsecase) *TopupHistoryHandler {
+	return &TopupHistoryHandler{
+		TopupHistoryUsecase: topupHistoryUsecase,
+		TopupUsecase:        topupUsecase,
+		IdempotencyUsecase:  idempotencyUsecase,
+	}
 }
 
 func (u *TopupHistoryHandler) TopupHistoryRoutes(e *echo.Echo) {
-	e.GET("user/topup-history/:topup_history_id", middleware.UserAuthMiddleware()(u.GetTopupHistoryByID))
-	e.GET("user/topup-histories", middleware.UserAuthMiddleware()(u.GetAllTopupHistory))
+	e.GET("user/topup-history/:topup_history_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(u.GetTopupHistoryByID)))
+	e.GET("user/topup-histories", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(u.GetAllTopupHistory)))
+	e.POST("user/topup-history/:topup_history_id/refund", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(middleware.IdempotencyMiddleware(u.IdempotencyUsecase)(u.RefundTopup))))
 }
 
 func (u *TopupHistoryHandler) GetTopupHistoryByID(c echo.Context) error {
 	topupHistoryID := c.Param("topup_history_id")
 	id := utils.StringToInt(topupHistoryID)
 
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
 	topupHistory, err := u.TopupHistoryUsecase.GetTopupHistoryByID(id)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "topup history not found")
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
+	if topupHistory.UserID != userID {
+		return echo.NewHTTPError(http.StatusNotFound, "topup history not found")
+	}
 
 	topupHistoryData := model.TopupHistoryData{
 		TopupHistoryID: topupHistory.TopupHistoryID,
 		PaymentID:      topupHistory.PaymentID,
+		Currency:       topupHistory.Currency,
 		Amount:         topupHistory.Amount,
+		RefundedAmount: topupHistory.RefundedAmount,
 		CreatedAt:      topupHistory.CreatedAt,
 	}
 
@@ -63,7 +86,9 @@ func (u *TopupHistoryHandler) GetAllTopupHistory(c echo.Context) error {
 		topupHistoryData = append(topupHistoryData, model.TopupHistoryData{
 			TopupHistoryID: value.TopupHistoryID,
 			PaymentID:      value.PaymentID,
+			Currency:       value.Currency,
 			Amount:         value.Amount,
+			RefundedAmount: value.RefundedAmount,
 			CreatedAt:      value.CreatedAt,
 		})
 	}
@@ -75,3 +100,45 @@ func (u *TopupHistoryHandler) GetAllTopupHistory(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, response)
 }
+
+// RefundTopup sends part or all of a top-up back to the card it was paid with.
+func (u *TopupHistoryHandler) RefundTopup(c echo.Context) error {
+	var refundReq model.TopupRefundRequest
+	if err := c.Bind(&refundReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	topupHistoryID := c.Param("topup_history_id")
+	id := utils.StringToInt(topupHistoryID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	topupHistory, _, err := u.TopupUsecase.RefundTopup(userID, id, refundReq.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "topup history not found")
+		case errors.Is(err, repository.ErrRefundExceedsTopup), errors.Is(err, repository.ErrInsufficientBalance):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	response := model.TopupHistoryResponse{
+		Message: "success refund topup",
+		Data: []model.TopupHistoryData{{
+			TopupHistoryID: topupHistory.TopupHistoryID,
+			PaymentID:      topupHistory.PaymentID,
+			Currency:       topupHistory.Currency,
+			Amount:         topupHistory.Amount,
+			RefundedAmount: topupHistory.RefundedAmount,
+			CreatedAt:      topupHistory.CreatedAt,
+		}},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
@@ -1,46 +1,47 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"rent-video-game/currency"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
 	"rent-video-game/utils"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 type TransactionHandler struct {
 	transactionUsecase *usecase.TransactionUsecase
-	bookingUsecase     *usecase.BookingUsecase
-	userUsecase        *usecase.UserUsecase
-	lessorUsecase      *usecase.LessorUsecase
+	paymentUsecase     *usecase.PaymentUsecase
+	idempotencyUsecase *usecase.IdempotencyUsecase
 }
 
 func NewTransactionHandler(
 	transactionUsecase *usecase.TransactionUsecase,
-	bookingUsecase *usecase.BookingUsecase,
-	userUsecase *usecase.UserUsecase,
-	lessorUsecase *usecase.LessorUsecase,
+	paymentUsecase *usecase.PaymentUsecase,
+	idempotencyUsecase *usecase.IdempotencyUsecase,
 ) *TransactionHandler {
 	return &TransactionHandler{
 		transactionUsecase: transactionUsecase,
-		bookingUsecase:     bookingUsecase,
-		userUsecase:        userUsecase,
-		lessorUsecase:      lessorUsecase,
+		paymentUsecase:     paymentUsecase,
+		idempotencyUsecase: idempotencyUsecase,
 	}
 }
 
 func (u *TransactionHandler) TransactionRoutes(e *echo.Echo) {
-	e.POST("/user/transaction", middleware.UserAuthMiddleware()(u.CreateTransaction))
-	e.GET("/user/transaction/:transaction_id", middleware.UserAuthMiddleware()(u.GetTransactionByID))
-	e.GET("/user/transactions", middleware.UserAuthMiddleware()(u.GetAllTransactionByUser))
+	e.POST("/user/transaction", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(middleware.IdempotencyMiddleware(u.idempotencyUsecase)(u.CreateTransaction))))
+	e.GET("/user/transaction/:transaction_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(u.GetTransactionByID)))
+	e.GET("/user/transactions", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermBookingRent)(u.GetAllTransactionByUser)))
 }
 
 func (u *TransactionHandler) CreateTransaction(c echo.Context) error {
-	var transaction *model.Transactions
-	if err := c.Bind(&transaction); err != nil {
+	var transactionReq *model.TransactionRequest
+	if err := c.Bind(&transactionReq); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
@@ -49,103 +50,58 @@ func (u *TransactionHandler) CreateTransaction(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	renter, err := u.userUsecase.GetUserByID(userID)
+	result, err := u.paymentUsecase.PayBooking(transactionReq.BookingID, userID, transactionReq.Amount)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user data: "+err.Error())
-	}
-
-	if renter.Amount < transaction.Amount {
-		return echo.NewHTTPError(http.StatusBadRequest, "insufficient balance")
-	}
-
-	booking, err := u.bookingUsecase.GetBookingByID(transaction.BookingID, userID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get booking data: "+err.Error())
-	}
-
-	product, err := u.bookingUsecase.GetProductByID(booking.ProductID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get product data: "+err.Error())
-	}
-
-	lessor, err := u.lessorUsecase.GetLessorByID(product.LessorID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get lessor data: "+err.Error())
+		switch {
+		case errors.Is(err, repository.ErrInsufficientBalance), errors.Is(err, repository.ErrBookingNotPayable),
+			errors.Is(err, usecase.ErrAmountMismatch), errors.Is(err, repository.ErrCurrencyMismatch),
+			errors.Is(err, usecase.ErrInvalidPayment):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, currency.ErrNoRate):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "booking not found")
+		default:
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
 	}
 
-	transaction.LessorID = lessor.LessorID
-
-	lessorUser, err := u.userUsecase.GetUserByID(lessor.UserID)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get lessor's user data: "+err.Error())
-	}
+	transaction := result.Transaction
+	renter := result.Renter
+	lessorUser := result.LessorUser
 
-	transaction.UserID = userID
+	go func() {
+		paid := currency.Format(transaction.Amount, transaction.Currency)
+		received := currency.Format(transaction.SettlementAmount, transaction.SettlementCurrency)
 
-	transaction, err = u.transactionUsecase.CreateTransaction(transaction)
-	if err != nil {
-		renter.Amount += transaction.Amount
-		_, revertErr := u.userUsecase.TransactionUser(userID, renter)
-		if revertErr != nil {
-			fmt.Printf("failed to revert user balance: %v\n", revertErr)
+		err := utils.SendBookingNotification(renter.Email, renter.Name, string(result.Booking.Status), transaction.BookingID, paid)
+		if err != nil {
+			fmt.Printf("failed to send booking notification: %v\n", err)
 		}
 
-		lessorUser.Amount -= transaction.Amount
-		_, revertErr = u.userUsecase.TransactionUser(lessor.UserID, lessorUser)
-		if revertErr != nil {
-			fmt.Printf("failed to revert lessor balance: %v\n", revertErr)
+		err = utils.SendTransactionNotification(lessorUser.Email, lessorUser.Name, transaction.TransactionID, received, lessorUser.UserID, currency.Format(lessorUser.Amount, lessorUser.Currency))
+		if err != nil {
+			fmt.Printf("failed to send transaction notification: %v\n", err)
 		}
 
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
-	}
-
-	renter.Amount -= transaction.Amount
-	_, err = u.userUsecase.TransactionUser(userID, renter)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update user balance: "+err.Error())
-	}
-
-	lessorUser.Amount += transaction.Amount
-	_, err = u.userUsecase.TransactionUser(lessor.UserID, lessorUser)
-	if err != nil {
-		renter.Amount += transaction.Amount
-		_, revertErr := u.userUsecase.TransactionUser(userID, renter)
-		if revertErr != nil {
-			fmt.Printf("failed to revert user balance: %v\n", revertErr)
+		err = utils.SendTransactionNotification(renter.Email, renter.Name, transaction.TransactionID, paid, renter.UserID, currency.Format(renter.Amount, renter.Currency))
+		if err != nil {
+			fmt.Printf("failed to send transaction notification: %v\n", err)
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update lessor balance: "+err.Error())
-	}
-
-	_, err = u.bookingUsecase.UpdateBooking(transaction.BookingID, model.Approved, booking)
-	if err != nil {
-		fmt.Printf("failed to update booking status: %v\n", err)
-	}
-
-	user, err := u.userUsecase.GetUserByID(userID)
-	if err == nil {
-		go func() {
-			err := utils.SendBookingNotification(user.Email, user.Name, string(model.Approved), booking.BookingID, transaction.Amount)
-			if err != nil {
-				fmt.Printf("failed to send topup notification: %v\n", err)
-			}
-
-			err = utils.SendTransactionNotification(lessorUser.Email, lessorUser.Name, transaction.TransactionID, transaction.Amount, lessor.UserID, lessorUser.Amount)
-			if err != nil {
-				fmt.Printf("failed to send transaction notification: %v\n", err)
-			}
-
-			err = utils.SendTransactionNotification(user.Email, user.Name, transaction.TransactionID, transaction.Amount, userID, user.Amount)
-			if err != nil {
-				fmt.Printf("failed to send transaction notification: %v\n", err)
-			}
-		}()
-	}
+	}()
 
 	transactionData := model.TransactionData{
-		TransactionID: transaction.TransactionID,
-		BookingID:     transaction.BookingID,
-		ReceiveID:     transaction.LessorID,
-		Amount:        transaction.Amount,
+		TransactionID:      transaction.TransactionID,
+		BookingID:          transaction.BookingID,
+		ReceiveID:          transaction.LessorID,
+		Currency:           transaction.Currency,
+		Amount:             transaction.Amount,
+		SettlementCurrency: transaction.SettlementCurrency,
+		SettlementAmount:   transaction.SettlementAmount,
+		ExchangeRate:       transaction.ExchangeRate,
+	}
+	if result.Deposit != nil {
+		transactionData.Deposit = result.Deposit.Amount
 	}
 
 	response := model.TransactionResponse{
@@ -1,19 +1,21 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"rent-video-game/currency"
 	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/payment"
+	"rent-video-game/repository"
 	"rent-video-game/usecase"
 	"rent-video-game/utils"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
-	"github.com/stripe/stripe-go/v72"
-	"github.com/stripe/stripe-go/v72/paymentintent"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -30,8 +32,11 @@ type IUserHandler interface {
 }
 
 type UserHandler struct {
-	userUsecase         *usecase.UserUsecase
-	topupHistoryUsecase *usecase.TopupHistoryUsecase
+	userUsecase        *usecase.UserUsecase
+	topupUsecase       *usecase.TopupUsecase
+	idempotencyUsecase *usecase.IdempotencyUsecase
+	tokenUsecase       *usecase.TokenUsecase
+	accountUsecase     *usecase.AccountUsecase
 }
 
 type UserHandlerInterface struct {
@@ -39,10 +44,13 @@ type UserHandlerInterface struct {
 	topupHistoryUsecase usecase.ITopupHistoryUsecase
 }
 
-func NewUserHandler(userUsecase *usecase.UserUsecase, topupHistoryUsecase *usecase.TopupHistoryUsecase) *UserHandler {
+func NewUserHandler(userUsecase *usecase.UserUsecase, topupUsecase *usecase.TopupUsecase, idempotencyUsecase *usecase.IdempotencyUsecase, tokenUsecase *usecase.TokenUsecase, accountUsecase *usecase.AccountUsecase) *UserHandler {
 	return &UserHandler{
-		userUsecase:         userUsecase,
-		topupHistoryUsecase: topupHistoryUsecase,
+		userUsecase:        userUsecase,
+		topupUsecase:       topupUsecase,
+		idempotencyUsecase: idempotencyUsecase,
+		tokenUsecase:       tokenUsecase,
+		accountUsecase:     accountUsecase,
 	}
 }
 
@@ -56,7 +64,14 @@ func NewUserHandlerWithInterface(userUsecase usecase.IUserUsecase, topupHistoryU
 func (u *UserHandler) UserRoutes(e *echo.Echo) {
 	e.POST("/user/register", u.RegisterUser)
 	e.POST("/user/login", u.LoginUser)
-	e.POST("/user/topup", middleware.UserAuthMiddleware()(u.TopupUser))
+	e.POST("/user/token/refresh", u.RefreshToken)
+	e.POST("/user/logout", middleware.UserAuthMiddleware()(u.LogoutUser))
+	e.GET("/user/email/verify", u.VerifyEmail)
+	e.POST("/user/email/verification", middleware.UserAuthMiddleware()(u.ResendVerification))
+	e.POST("/user/password/forgot", u.ForgotPassword)
+	e.POST("/user/password/reset", u.ResetPassword)
+	e.POST("/user/topup", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(middleware.RequireVerifiedEmail(u.userUsecase)(middleware.IdempotencyMiddleware(u.idempotencyUsecase)(u.TopupUser)))))
+	e.POST("/user/topup/:payment_id/confirm", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(u.ConfirmTopup)))
 }
 
 func (u *UserHandler) RegisterUser(c echo.Context) error {
@@ -76,6 +91,7 @@ func (u *UserHandler) RegisterUser(c echo.Context) error {
 		Email:    userRegister.Email,
 		Password: userRegister.Password,
 		Address:  userRegister.Address,
+		Currency: currency.Code(userRegister.Currency),
 	}
 
 	_, err = u.userUsecase.GetUserByEmail(user.Email)
@@ -88,8 +104,14 @@ func (u *UserHandler) RegisterUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	go func() {
+		if err := u.accountUsecase.SendVerification(user); err != nil {
+			fmt.Printf("failed to send verification email: %v\n", err)
+		}
+	}()
+
 	response := model.RegisterResponse{
-		Message: "success register user",
+		Message: "success register user, please check your email to verify it",
 	}
 	response.Data.UserID = user.UserID
 	response.Data.Email = user.Email
@@ -115,6 +137,7 @@ func (ui *UserHandlerInterface) RegisterUserInterface(c echo.Context) error {
 		Email:    userRegister.Email,
 		Password: userRegister.Password,
 		Address:  userRegister.Address,
+		Currency: currency.Code(userRegister.Currency),
 	}
 
 	_, err = ui.userUsecase.GetUserByEmail(user.Email)
@@ -154,19 +177,147 @@ func (u *UserHandler) LoginUser(c echo.Context) error {
 		}
 	}
 
-	token, err := utils.GenerateUserToken(user.UserID)
+	tokens, err := u.tokenUsecase.Login(user.UserID)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate token")
 	}
 
 	response := model.LoginResponse{
 		Message: "success login user",
+		Data:    *tokens,
 	}
-	response.Data.Token = token
 
 	return c.JSON(http.StatusOK, response)
 }
 
+func (u *UserHandler) RefreshToken(c echo.Context) error {
+	var refreshReq model.RefreshTokenRequest
+	if err := c.Bind(&refreshReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if refreshReq.RefreshToken == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "refresh_token is required")
+	}
+
+	tokens, err := u.tokenUsecase.Refresh(refreshReq.RefreshToken)
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenInvalid) || errors.Is(err, repository.ErrRefreshTokenReused) {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.TokenResponse{
+		Message: "success refresh token",
+		Data:    *tokens,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// LogoutUser revokes the access token used for the request. Sending the
+// refresh token as well ends the login it belongs to.
+func (u *UserHandler) LogoutUser(c echo.Context) error {
+	var logoutReq model.RefreshTokenRequest
+	if err := c.Bind(&logoutReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
+
+	jti, _ := c.Get("jti").(string)
+	expiresAt, _ := c.Get("token_exp").(time.Time)
+
+	if err := u.tokenUsecase.Logout(userID, jti, expiresAt, logoutReq.RefreshToken); err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenInvalid) {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "success logout user",
+	})
+}
+
+func (u *UserHandler) VerifyEmail(c echo.Context) error {
+	if err := u.accountUsecase.VerifyEmail(c.QueryParam("token")); err != nil {
+		if errors.Is(err, repository.ErrAccountTokenInvalid) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "success verify email",
+	})
+}
+
+func (u *UserHandler) ResendVerification(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.userUsecase.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "user not found")
+	}
+
+	if err := u.accountUsecase.SendVerification(user); err != nil {
+		if errors.Is(err, usecase.ErrEmailAlreadyVerified) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "success send verification email",
+	})
+}
+
+func (u *UserHandler) ForgotPassword(c echo.Context) error {
+	var forgotReq model.ForgotPasswordRequest
+	if err := c.Bind(&forgotReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if forgotReq.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "email is required")
+	}
+
+	if err := u.accountUsecase.ForgotPassword(forgotReq.Email); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "if the email belongs to an account, a password reset token has been sent",
+	})
+}
+
+func (u *UserHandler) ResetPassword(c echo.Context) error {
+	var resetReq model.ResetPasswordRequest
+	if err := c.Bind(&resetReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if resetReq.Token == "" || resetReq.Password == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "token and password are required")
+	}
+
+	if err := u.accountUsecase.ResetPassword(resetReq.Token, resetReq.Password); err != nil {
+		if errors.Is(err, repository.ErrAccountTokenInvalid) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "success reset password, please login again",
+	})
+}
+
 func (u *UserHandler) TopupUser(c echo.Context) error {
 	var topupReq model.TopupRequest
 
@@ -187,78 +338,64 @@ func (u *UserHandler) TopupUser(c echo.Context) error {
 		})
 	}
 
-	// stripe payment
-	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
-
-	// create payment intent
-	params := &stripe.PaymentIntentParams{
-		Amount:             stripe.Int64(int64(topupReq.Amount * 100)), // convert to cents
-		Currency:           stripe.String("usd"),
-		PaymentMethod:      stripe.String("pm_card_visa"),
-		PaymentMethodTypes: []*string{stripe.String("card")},
-		ConfirmationMethod: stripe.String("automatic"),
-		Confirm:            stripe.Bool(true),
+	user, err := u.userUsecase.GetUserByID(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "failed to get user: " + err.Error(),
+		})
 	}
 
-	pi, err := paymentintent.New(params)
+	// the client confirms the payment intent, including any 3-D Secure step;
+	// the wallet is credited by the webhook once the gateway reports success
+	intent, err := u.topupUsecase.CreateTopup(userID, user.Currency, topupReq.Amount)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "payment processing failed: " + err.Error(),
 		})
 	}
 
-	paymentID := pi.ID // save payment id
-
-	if pi.Status == "succeeded" {
-		user := &model.Users{
-			Amount: topupReq.Amount,
-		}
-
-		updatedUser, err := u.userUsecase.TopupUser(userID, user)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "failed to update user balance: " + err.Error(),
-			})
-		}
-
-		user, err = u.userUsecase.GetUserByID(userID)
-		if err == nil {
-			go func() {
-				err := utils.SendTopupNotification(user.Email, user.Name, topupReq.Amount, updatedUser.Amount, pi.ID)
-				if err != nil {
-					fmt.Printf("failed to send topup notification: %v\n", err)
-				}
-			}()
-		}
+	return c.JSON(http.StatusAccepted, topupResponse("topup pending payment confirmation", user, topupReq.Amount, intent))
+}
 
-		topupHistory := &model.TopupHistory{
-			UserID:    userID,
-			PaymentID: paymentID,
-			Amount:    topupReq.Amount,
-		}
+func (u *UserHandler) ConfirmTopup(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return err
+	}
 
-		_, err = u.topupHistoryUsecase.CreateTopupHistory(topupHistory)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "failed to create topup history: " + err.Error(),
-			})
+	intent, err := u.topupUsecase.ConfirmTopup(userID, c.Param("payment_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, payment.ErrIntentNotFound), errors.Is(err, usecase.ErrPaymentNotOwned):
+			return echo.NewHTTPError(http.StatusNotFound, "payment not found")
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+	}
 
-		response := model.TopupResponse{
-			Message: "topup successful",
-		}
+	// a gateway that settles at once has credited the wallet by now
+	user, err := u.userUsecase.GetUserByID(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
 
-		response.Data.UserID = updatedUser.UserID
-		response.Data.TopupAmount = topupReq.Amount
-		response.Data.NewBalance = updatedUser.Amount
-		response.Data.PaymentID = paymentID
+	return c.JSON(http.StatusOK, topupResponse("topup confirmed", user, currency.Money(intent.Amount), intent))
+}
 
-		return c.JSON(http.StatusOK, response)
-	} else {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "payment failed: " + string(pi.Status),
-		})
+func topupResponse(message string, user *model.Users, amount currency.Money, intent *payment.Intent) model.TopupResponse {
+	response := model.TopupResponse{
+		Message: message,
 	}
+
+	response.Data.UserID = user.UserID
+	response.Data.Currency = user.Currency
+	response.Data.TopupAmount = amount
+	response.Data.Balance = user.Amount
+	response.Data.PaymentID = intent.ID
+	response.Data.ClientSecret = intent.ClientSecret
+	response.Data.Status = string(intent.Status)
+
+	return response
 }
 
 func UserToken(c echo.Context) (uuid.UUID, error) {
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"rent-video-game/currency"
+	"rent-video-game/payment"
+	"rent-video-game/usecase"
+	"rent-video-game/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MaxWebhookBodyBytes matches the largest event payload Stripe sends.
+const MaxWebhookBodyBytes = 65536
+
+type WebhookHandler struct {
+	topupUsecase *usecase.TopupUsecase
+	userUsecase  *usecase.UserUsecase
+}
+
+func NewWebhookHandler(topupUsecase *usecase.TopupUsecase, userUsecase *usecase.UserUsecase) *WebhookHandler {
+	return &WebhookHandler{
+		topupUsecase: topupUsecase,
+		userUsecase:  userUsecase,
+	}
+}
+
+func (h *WebhookHandler) WebhookRoutes(e *echo.Echo) {
+	e.POST("/webhooks/stripe", h.StripeWebhook)
+}
+
+// HandleEvent applies a gateway event and notifies the user of a completed
+// top-up. Gateways that deliver events in process subscribe it directly.
+func (h *WebhookHandler) HandleEvent(event *payment.Event) error {
+	topupHistory, err := h.topupUsecase.HandleEvent(event)
+	if err != nil {
+		return err
+	}
+
+	if topupHistory != nil && event.Type == payment.EventIntentSucceeded {
+		user, err := h.userUsecase.GetUserByID(topupHistory.UserID)
+		if err == nil {
+			go func() {
+				err := utils.SendTopupNotification(user.Email, user.Name,
+					currency.Format(topupHistory.Amount, topupHistory.Currency), currency.Format(user.Amount, user.Currency), topupHistory.PaymentID)
+				if err != nil {
+					fmt.Printf("failed to send topup notification: %v\n", err)
+				}
+			}()
+		}
+	}
+	return nil
+}
+
+func (h *WebhookHandler) StripeWebhook(c echo.Context) error {
+	payload, err := io.ReadAll(io.LimitReader(c.Request().Body, MaxWebhookBodyBytes))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	event, err := h.topupUsecase.ParseEvent(payload, c.Request().Header.Get(payment.SignatureHeader))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook signature")
+	}
+
+	// an error makes the gateway redeliver the event later
+	if err := h.HandleEvent(event); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"received": true,
+	})
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/usecase"
+	"rent-video-game/utils"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type WithdrawalHandler struct {
+	withdrawalUsecase  *usecase.WithdrawalUsecase
+	idempotencyUsecase *usecase.IdempotencyUsecase
+}
+
+func NewWithdrawalHandler(withdrawalUsecase *usecase.WithdrawalUsecase, idempotencyUsecase *usecase.IdempotencyUsecase) *WithdrawalHandler {
+	return &WithdrawalHandler{withdrawalUsecase: withdrawalUsecase, idempotencyUsecase: idempotencyUsecase}
+}
+
+func (h *WithdrawalHandler) WithdrawalRoutes(e *echo.Echo) {
+	e.POST("/user/withdrawal", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(middleware.IdempotencyMiddleware(h.idempotencyUsecase)(h.CreateWithdrawal))))
+	e.GET("/user/withdrawal/:withdrawal_id", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(h.GetWithdrawalByID)))
+	e.GET("/user/withdrawals", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWalletManage)(h.GetAllWithdrawal)))
+
+	e.GET("/admin/withdrawals", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWithdrawalManage)(h.GetAllWithdrawalByStatus)))
+	e.PUT("/admin/withdrawal/:withdrawal_id/approve", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWithdrawalManage)(h.ApproveWithdrawal)))
+	e.PUT("/admin/withdrawal/:withdrawal_id/paid", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWithdrawalManage)(h.PayWithdrawal)))
+	e.PUT("/admin/withdrawal/:withdrawal_id/fail", middleware.UserAuthMiddleware()(middleware.RequirePermission(model.PermWithdrawalManage)(h.FailWithdrawal)))
+}
+
+func (h *WithdrawalHandler) CreateWithdrawal(c echo.Context) error {
+	var withdrawalReq model.WithdrawalRequest
+	if err := c.Bind(&withdrawalReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	withdrawal, err := h.withdrawalUsecase.CreateWithdrawal(&model.Withdrawals{
+		UserID:      userID,
+		Amount:      withdrawalReq.Amount,
+		Destination: withdrawalReq.Destination,
+	})
+	if err != nil {
+		return withdrawalError(err)
+	}
+
+	response := model.WithdrawalResponse{
+		Message: "success request withdrawal",
+		Data:    []model.Withdrawals{*withdrawal},
+	}
+
+	return c.JSON(http.StatusCreated, response)
+}
+
+func (h *WithdrawalHandler) GetWithdrawalByID(c echo.Context) error {
+	withdrawalID := c.Param("withdrawal_id")
+	id := utils.StringToInt(withdrawalID)
+
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	withdrawal, err := h.withdrawalUsecase.GetWithdrawalByID(id)
+	if err != nil {
+		return withdrawalError(err)
+	}
+	if withdrawal.UserID != userID {
+		return echo.NewHTTPError(http.StatusNotFound, "withdrawal not found")
+	}
+
+	response := model.WithdrawalResponse{
+		Message: "success get withdrawal by id",
+		Data:    []model.Withdrawals{*withdrawal},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WithdrawalHandler) GetAllWithdrawal(c echo.Context) error {
+	userID, err := UserToken(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	withdrawals, err := h.withdrawalUsecase.GetAllWithdrawalByUser(userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.WithdrawalResponse{
+		Message: "success get all withdrawal",
+		Data:    withdrawals,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WithdrawalHandler) GetAllWithdrawalByStatus(c echo.Context) error {
+	status := model.WithdrawalStatus(c.QueryParam("status"))
+	if status == "" {
+		status = model.WithdrawalPending
+	}
+
+	withdrawals, err := h.withdrawalUsecase.GetAllWithdrawalByStatus(status)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	response := model.WithdrawalResponse{
+		Message: "success get all withdrawal by status",
+		Data:    withdrawals,
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *WithdrawalHandler) ApproveWithdrawal(c echo.Context) error {
+	id := utils.StringToInt(c.Param("withdrawal_id"))
+
+	withdrawal, err := h.withdrawalUsecase.ApproveWithdrawal(id)
+	if err != nil {
+		return withdrawalError(err)
+	}
+
+	return h.withdrawalDecision(c, "success approve withdrawal", withdrawal)
+}
+
+func (h *WithdrawalHandler) PayWithdrawal(c echo.Context) error {
+	id := utils.StringToInt(c.Param("withdrawal_id"))
+
+	withdrawal, err := h.withdrawalUsecase.PayWithdrawal(id)
+	if err != nil {
+		return withdrawalError(err)
+	}
+
+	return h.withdrawalDecision(c, "success mark withdrawal as paid", withdrawal)
+}
+
+func (h *WithdrawalHandler) FailWithdrawal(c echo.Context) error {
+	var decisionReq model.WithdrawalDecisionRequest
+	if err := c.Bind(&decisionReq); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	id := utils.StringToInt(c.Param("withdrawal_id"))
+
+	withdrawal, err := h.withdrawalUsecase.FailWithdrawal(id, decisionReq.Reason)
+	if err != nil {
+		return withdrawalError(err)
+	}
+
+	return h.withdrawalDecision(c, "success mark withdrawal as failed", withdrawal)
+}
+
+func (h *WithdrawalHandler) withdrawalDecision(c echo.Context, message string, withdrawal *model.Withdrawals) error {
+	response := model.WithdrawalResponse{
+		Message: message,
+		Data:    []model.Withdrawals{*withdrawal},
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func withdrawalError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "withdrawal not found")
+	case errors.Is(err, repository.ErrInvalidWithdrawalTransition):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+}
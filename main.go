@@ -7,10 +7,15 @@ import (
 	"os"
 	"os/signal"
 	"rent-video-game/config"
+	"rent-video-game/currency"
 	"rent-video-game/handler"
+	"rent-video-game/middleware"
 	"rent-video-game/model"
+	"rent-video-game/payment"
 	"rent-video-game/repository"
+	"rent-video-game/scheduler"
 	"rent-video-game/usecase"
+	"rent-video-game/utils"
 	"syscall"
 	"time"
 
@@ -29,24 +34,136 @@ func main() {
 		panic("failed to connect to database!")
 	}
 
+	// tokens are signed with the newest key in the key directory
+	keys, err := utils.LoadKeySet(config.String("JWT_KEY_DIR", "keys"), os.Getenv("JWT_SIGNING_KEY_ID"))
+	if err != nil {
+		panic("failed to load JWT signing keys: " + err.Error())
+	}
+	utils.UseKeySet(keys)
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		panic("Failed to connect to database!")
 	}
 
 	// sceheme migration
+	// bookings write every status, so a missing enum value must stop the boot
+	if err := db.Exec(`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'booking_status') THEN
+			CREATE TYPE booking_status AS ENUM ('PENDING');
+		END IF;
+	END $$`).Error; err != nil {
+		panic("failed to create booking_status type: " + err.Error())
+	}
+	for _, status := range model.BookingStatuses {
+		if err := db.Exec(fmt.Sprintf("ALTER TYPE booking_status ADD VALUE IF NOT EXISTS '%s'", status)).Error; err != nil {
+			panic("failed to add booking status " + string(status) + ": " + err.Error())
+		}
+	}
+	// money stored as cents is converted in one transaction: AutoMigrate would
+	// otherwise retype a column it missed without the division by 100
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		for _, column := range model.MoneyColumns {
+			if err := tx.Exec(fmt.Sprintf(`DO $$ BEGIN
+				IF EXISTS (SELECT 1 FROM information_schema.columns
+					WHERE table_name = '%[1]s' AND column_name = '%[2]s' AND data_type = 'bigint') THEN
+					ALTER TABLE %[1]s ALTER COLUMN %[2]s TYPE NUMERIC(14, 2) USING %[2]s / 100.0;
+				END IF;
+			END $$`, column.Table, column.Column)).Error; err != nil {
+				return fmt.Errorf("%s.%s: %w", column.Table, column.Column, err)
+			}
+		}
+		// payments made before multi-currency settled 1:1 in USD; backfilled
+		// once, when the settlement columns are added
+		if err := tx.Exec(`DO $$ BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'transactions')
+				AND NOT EXISTS (SELECT 1 FROM information_schema.columns
+					WHERE table_name = 'transactions' AND column_name = 'settlement_amount') THEN
+				ALTER TABLE transactions
+					ADD COLUMN IF NOT EXISTS settlement_currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+					ADD COLUMN settlement_amount NUMERIC(14, 2) NOT NULL DEFAULT 0;
+				UPDATE transactions SET settlement_amount = amount;
+			END IF;
+		END $$`).Error; err != nil {
+			return fmt.Errorf("transactions.settlement_amount: %w", err)
+		}
+		return nil
+	}); err != nil {
+		panic("failed to convert money columns: " + err.Error())
+	}
+
+	// accounts created before email verification keep working
+	db.Exec(`DO $$ BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'users')
+			AND NOT EXISTS (SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'users' AND column_name = 'email_verified_at') THEN
+			ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+			UPDATE users SET email_verified_at = created_at;
+		END IF;
+	END $$`)
+
 	db.AutoMigrate(
 		&model.Users{},
 		&model.Lessors{},
 		&model.Consoles{},
 		&model.TopupHistory{},
 		&model.Products{},
+		&model.ProductUnits{},
 		&model.Bookings{},
+		&model.BookingStatusHistories{},
 		&model.Transactions{},
+		&model.Deposits{},
+		&model.CancellationPolicies{},
+		&model.IdempotencyKeys{},
+		&model.PaymentEvents{},
+		&model.Withdrawals{},
 		&model.Ratings{},
+		&model.LedgerAccounts{},
+		&model.JournalEntries{},
+		&model.Postings{},
+		&model.Roles{},
+		&model.Permissions{},
+		&model.UserRoles{},
+		&model.AuditLogs{},
+		&model.RefreshTokens{},
+		&model.RevokedTokens{},
+		&model.AccountTokens{},
+		&model.ProductRatingStats{},
 	)
+	// full-text and fuzzy product search
+	for _, statement := range repository.ProductSearchSchema {
+		db.Exec(statement)
+	}
+	// console names are unique among live consoles regardless of case
+	db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_consoles_name_active ON consoles (LOWER(name)) WHERE deleted_at IS NULL")
 	fmt.Println("database migrated")
 
+	rates, err := currency.LoadRates(config.String("EXCHANGE_RATES_FILE", "exchange_rates.json"))
+	if err != nil {
+		panic("failed to load exchange rates: " + err.Error())
+	}
+
+	// ledger accounts must exist before any money moves
+	ledgerRepo := repository.NewLedgerRepository(db)
+	ledgerUsecase := usecase.NewLedgerUsecase(ledgerRepo)
+	if err := ledgerUsecase.OpenAccounts(); err != nil {
+		panic("failed to open ledger accounts: " + err.Error())
+	}
+
+	// every route checks the roles and permissions seeded here
+	roleRepo := repository.NewRoleRepository(db)
+	roleUsecase := usecase.NewRoleUsecase(roleRepo)
+	if err := roleUsecase.SeedRoles(); err != nil {
+		panic("failed to seed roles: " + err.Error())
+	}
+
+	// access tokens are short-lived; refresh tokens rotate and can be revoked
+	tokenRepo := repository.NewTokenRepository(db)
+	tokenUsecase := usecase.NewTokenUsecase(tokenRepo, roleUsecase,
+		config.Duration("ACCESS_TOKEN_TTL", usecase.DefaultAccessTokenTTL),
+		config.Duration("REFRESH_TOKEN_TTL", usecase.DefaultRefreshTokenTTL))
+	middleware.UseTokenDenylist(tokenUsecase)
+
 	// init echo
 	e := echo.New()
 
@@ -54,54 +171,137 @@ func main() {
 		return c.String(http.StatusOK, "Hello, World!")
 	})
 
+	// public keys for services verifying our tokens
+	jwksHandler := handler.NewJWKSHandler()
+	jwksHandler.JWKSRoutes(e)
+
+	// retried requests to money-moving routes replay the first response
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(idempotencyRepo,
+		config.Duration("IDEMPOTENCY_KEY_LEASE", usecase.DefaultIdempotencyLease))
+
+	// ledger handler
+	ledgerHandler := handler.NewLedgerHandler(ledgerUsecase)
+	ledgerHandler.LedgerRoutes(e)
+
+	// top-ups go through the configured payment gateway
+	gateway, err := payment.NewGatewayFromEnv()
+	if err != nil {
+		panic("failed to init payment gateway: " + err.Error())
+	}
+	topupRepo := repository.NewTopupRepository(db)
+	topupUsecase := usecase.NewTopupUsecase(topupRepo, gateway)
+
 	// topup history handler
 	topupHistoryRepo := repository.NewTopupHistoryRepository(db)
 	topupHistoryUsecase := usecase.NewTopupHistoryUsecase(topupHistoryRepo)
-	topupHistoryHandler := handler.NewTopupHistoryHandler(topupHistoryUsecase)
+	topupHistoryHandler := handler.NewTopupHistoryHandler(topupHistoryUsecase, topupUsecase, idempotencyUsecase)
 	topupHistoryHandler.TopupHistoryRoutes(e)
 
+	// withdrawal handler
+	withdrawalRepo := repository.NewWithdrawalRepository(db)
+	withdrawalUsecase := usecase.NewWithdrawalUsecase(withdrawalRepo)
+	withdrawalHandler := handler.NewWithdrawalHandler(withdrawalUsecase, idempotencyUsecase)
+	withdrawalHandler.WithdrawalRoutes(e)
+
 	// user handler
 	userRepo := repository.NewUserRepository(db)
 	userUsecase := usecase.NewUserUsecase(userRepo)
-	userHandler := handler.NewUserHandler(userUsecase, topupHistoryUsecase)
+	accountTokenRepo := repository.NewAccountTokenRepository(db)
+	accountUsecase := usecase.NewAccountUsecase(accountTokenRepo, userRepo,
+		config.Duration("EMAIL_VERIFICATION_TTL", usecase.DefaultEmailVerificationTTL),
+		config.Duration("PASSWORD_RESET_TTL", usecase.DefaultPasswordResetTTL),
+		config.String("APP_URL", "http://localhost:8080"))
+	userHandler := handler.NewUserHandler(userUsecase, topupUsecase, idempotencyUsecase, tokenUsecase, accountUsecase)
 	userHandler.UserRoutes(e)
 
+	// role handler
+	roleHandler := handler.NewRoleHandler(roleUsecase, userUsecase)
+	roleHandler.RoleRoutes(e)
+
 	// lessor handler
 	lessorRepo := repository.NewLessorRepository(db)
 	lessorUsecase := usecase.NewLessorUsecase(lessorRepo)
-	lessorHandler := handler.NewLessorHandler(lessorUsecase)
+	lessorHandler := handler.NewLessorHandler(lessorUsecase, tokenUsecase)
 	lessorHandler.LessorRoutes(e)
 
 	// console handler
 	consoleRepo := repository.NewConsoleRepository(db)
 	consoleUsecase := usecase.NewConsoleUsecase(consoleRepo)
-	consoleHandler := handler.NewConsoleHandler(consoleUsecase)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	auditLogUsecase := usecase.NewAuditLogUsecase(auditLogRepo)
+	consoleHandler := handler.NewConsoleHandler(consoleUsecase, auditLogUsecase)
 	consoleHandler.ConsoleRoutes(e)
 
 	// rating handler
 	ratingRepo := repository.NewRatingRepository(db)
-	ratingUsecase := usecase.NewRatingUsecase(ratingRepo)
-	ratingHandler := handler.NewRatingHandler(ratingUsecase)
+	ratingUsecase := usecase.NewRatingUsecase(ratingRepo,
+		config.Duration("RATING_EDIT_WINDOW", usecase.DefaultRatingEditWindow))
+	if err := ratingUsecase.BackfillRatingStats(); err != nil {
+		panic("failed to backfill rating stats: " + err.Error())
+	}
+	ratingHandler := handler.NewRatingHandler(ratingUsecase, lessorUsecase)
 	ratingHandler.RatingRoutes(e)
 
 	// product handler
 	productRepo := repository.NewProductRepository(db)
-	productUsecase := usecase.NewProductUsecase(productRepo)
+	productUsecase := usecase.NewProductUsecase(productRepo, rates)
+	if err := productUsecase.BackfillUnits(); err != nil {
+		panic("failed to backfill product units: " + err.Error())
+	}
 	productHandler := handler.NewProductHandler(productUsecase, lessorUsecase, ratingUsecase)
 	productHandler.ProductRoutes(e)
 
 	// booking handler
 	bookingRepo := repository.NewBookingRepository(db)
-	bookingUsecase := usecase.NewBookingUsecase(bookingRepo)
-	bookingHandler := handler.NewBookingHandler(bookingUsecase, userUsecase, productUsecase, lessorUsecase)
+	bookingUsecase := usecase.NewBookingUsecase(bookingRepo, rates)
+	pricingUsecase := usecase.NewPricingUsecase(bookingRepo, utils.StringToInt(os.Getenv("MIN_RENTAL_DAYS")))
+	bookingHandler := handler.NewBookingHandler(bookingUsecase, userUsecase, productUsecase, lessorUsecase, pricingUsecase)
 	bookingHandler.BookingRoutes(e)
 
 	// transaction handler
 	transactionRepo := repository.NewTransactionRepository(db)
 	transactionUsecase := usecase.NewTransactionUsecase(transactionRepo)
-	transactionHandler := handler.NewTransactionHandler(transactionUsecase, bookingUsecase, userUsecase, lessorUsecase)
+	paymentRepo := repository.NewPaymentRepository(db)
+	paymentUsecase := usecase.NewPaymentUsecase(paymentRepo, pricingUsecase)
+	transactionHandler := handler.NewTransactionHandler(transactionUsecase, paymentUsecase, idempotencyUsecase)
 	transactionHandler.TransactionRoutes(e)
 
+	// deposit handler
+	depositRepo := repository.NewDepositRepository(db)
+	depositUsecase := usecase.NewDepositUsecase(depositRepo)
+	depositHandler := handler.NewDepositHandler(depositUsecase, bookingUsecase, lessorUsecase)
+	depositHandler.DepositRoutes(e)
+
+	// cancellation handler
+	cancellationRepo := repository.NewCancellationRepository(db)
+	cancellationUsecase := usecase.NewCancellationUsecase(cancellationRepo, bookingRepo)
+	cancellationHandler := handler.NewCancellationHandler(cancellationUsecase, lessorUsecase, idempotencyUsecase)
+	cancellationHandler.CancellationRoutes(e)
+
+	// background jobs
+	schedulerRepo := repository.NewSchedulerRepository(db)
+	jobScheduler := scheduler.NewScheduler(schedulerRepo)
+	jobInterval := config.Duration("SCHEDULER_INTERVAL", 5*time.Minute)
+	jobScheduler.Register("expire-unpaid-bookings", jobInterval,
+		scheduler.ExpireUnpaidBookings(bookingUsecase, config.Duration("BOOKING_PAYMENT_TTL", 24*time.Hour)))
+	jobScheduler.Register("mark-overdue-bookings", jobInterval,
+		scheduler.MarkOverdueBookings(bookingUsecase))
+	jobScheduler.Register("accrue-late-fees", jobInterval,
+		scheduler.AccrueLateFees(depositUsecase))
+	jobScheduler.Register("purge-idempotency-keys", jobInterval,
+		scheduler.PurgeIdempotencyKeys(idempotencyUsecase, config.Duration("IDEMPOTENCY_KEY_TTL", 24*time.Hour)))
+	jobScheduler.Register("purge-expired-tokens", jobInterval,
+		scheduler.PurgeExpiredTokens(tokenUsecase))
+	jobScheduler.Start()
+
+	// payment webhook
+	webhookHandler := handler.NewWebhookHandler(topupUsecase, userUsecase)
+	webhookHandler.WebhookRoutes(e)
+	if source, ok := gateway.(payment.EventSource); ok {
+		source.Subscribe(webhookHandler.HandleEvent)
+	}
+
 	// start server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -118,6 +318,8 @@ func main() {
 	<-quit
 	fmt.Println("shutting down server...")
 
+	jobScheduler.Stop()
+
 	// give server 10 seconds to finish processing requests
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
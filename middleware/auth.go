@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"rent-video-game/utils"
+	"time"
 
 	"strings"
 
@@ -14,6 +15,20 @@ const (
 	Bearer        = "Bearer"
 )
 
+// TokenDenylist reports whether an access token was revoked before it
+// expired, such as on logout.
+type TokenDenylist interface {
+	IsRevoked(jti string) (bool, error)
+}
+
+var denylist TokenDenylist
+
+// UseTokenDenylist makes UserAuthMiddleware reject revoked tokens. It is set
+// once at startup, before routes serve requests.
+func UseTokenDenylist(d TokenDenylist) {
+	denylist = d
+}
+
 func UserAuthMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -38,8 +53,42 @@ func UserAuthMiddleware() echo.MiddlewareFunc {
 				})
 			}
 
+			jti, _ := claims["jti"].(string)
+			if denylist != nil && jti != "" {
+				revoked, err := denylist.IsRevoked(jti)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+						"message": "failed to check token!",
+					})
+				}
+				if revoked {
+					return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+						"message": "token has been revoked!",
+					})
+				}
+			}
+
 			c.Set("user_id", claims["user_id"])
+			c.Set("jti", jti)
+			if exp, ok := claims["exp"].(float64); ok {
+				c.Set("token_exp", time.Unix(int64(exp), 0))
+			}
+			c.Set("roles", stringClaims(claims["roles"]))
+			c.Set("permissions", stringClaims(claims["permissions"]))
 			return next(c)
 		}
 	}
 }
+
+// stringClaims reads a JSON array claim; tokens issued before roles existed
+// carry none.
+func stringClaims(claim interface{}) []string {
+	values, _ := claim.([]interface{})
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"rent-video-game/usecase"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	IdempotencyKey      = "Idempotency-Key"
+	IdempotencyReplayed = "Idempotent-Replayed"
+)
+
+// IdempotencyMiddleware makes a route safe to retry. The first response to a
+// request carrying an Idempotency-Key header is stored per user, key and
+// request path and replayed for every retry with the same body. The path is
+// used rather than the route template so a key reused on another booking or
+// withdrawal is never answered with the first one's response. Server errors are not
+// stored so the client can retry them. It must run after UserAuthMiddleware.
+func IdempotencyMiddleware(idempotencyUsecase *usecase.IdempotencyUsecase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(IdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+
+			userIDString, _ := c.Get("user_id").(string)
+			userID, err := uuid.Parse(userIDString)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"message": "invalid user id!",
+				})
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"message": "failed to read request body!",
+				})
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.Sum256(body)
+			route := c.Request().Method + " " + c.Request().URL.Path
+
+			record, replay, err := idempotencyUsecase.Begin(userID, key, route, hex.EncodeToString(hash[:]))
+			switch {
+			case errors.Is(err, usecase.ErrIdempotencyKeyReused):
+				return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+					"message": err.Error(),
+				})
+			case errors.Is(err, usecase.ErrIdempotencyKeyInProgress):
+				return c.JSON(http.StatusConflict, map[string]interface{}{
+					"message": err.Error(),
+				})
+			case err != nil:
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{
+					"message": err.Error(),
+				})
+			}
+
+			if replay {
+				c.Response().Header().Set(IdempotencyReplayed, "true")
+				return c.Blob(record.StatusCode, record.ContentType, record.ResponseBody)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// let echo render handler errors now so the error response is stored too
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			if status >= http.StatusInternalServerError {
+				err = idempotencyUsecase.Release(record)
+			} else {
+				err = idempotencyUsecase.Complete(record, status, c.Response().Header().Get(echo.HeaderContentType), recorder.body.Bytes())
+			}
+			if err != nil {
+				fmt.Printf("failed to store idempotency key: %v\n", err)
+			}
+			return nil
+		}
+	}
+}
+
+// responseRecorder copies everything written to the client.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
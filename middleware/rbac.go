@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"rent-video-game/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireRole lets the request through when the token carries any of the
+// roles. It must run after UserAuthMiddleware.
+func RequireRole(roles ...model.RoleName) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			held, _ := c.Get("roles").([]string)
+			for _, role := range roles {
+				if contains(held, string(role)) {
+					return next(c)
+				}
+			}
+
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"message": "forbidden! role required",
+			})
+		}
+	}
+}
+
+// RequirePermission lets the request through only when the token carries
+// every one of the permissions. It must run after UserAuthMiddleware.
+func RequirePermission(permissions ...model.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			held, _ := c.Get("permissions").([]string)
+			for _, permission := range permissions {
+				if !contains(held, string(permission)) {
+					return c.JSON(http.StatusForbidden, map[string]interface{}{
+						"message": "forbidden! missing permission " + string(permission),
+					})
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"rent-video-game/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type denylist map[string]bool
+
+func (d denylist) IsRevoked(jti string) (bool, error) {
+	return d[jti], nil
+}
+
+func useTestKeys(t *testing.T) {
+	key, err := utils.GenerateSigningKey("test", utils.AlgEdDSA)
+	assert.NoError(t, err)
+
+	keys, err := utils.NewKeySet("", key)
+	assert.NoError(t, err)
+	utils.UseKeySet(keys)
+}
+
+func TestUserAuthMiddlewareRejectsRevokedToken(t *testing.T) {
+	useTestKeys(t)
+
+	token, err := utils.GenerateUserToken(uuid.New(), &model.Access{}, time.Minute)
+	assert.NoError(t, err)
+
+	claims, err := utils.VerifyUserToken(token)
+	assert.NoError(t, err)
+	jti := claims["jti"].(string)
+
+	authed := func(revoked denylist) int {
+		middleware.UseTokenDenylist(revoked)
+		defer middleware.UseTokenDenylist(nil)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(middleware.Authorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		err := middleware.UserAuthMiddleware()(func(c echo.Context) error {
+			assert.Equal(t, jti, c.Get("jti"))
+			return c.NoContent(http.StatusOK)
+		})(e.NewContext(req, rec))
+		assert.NoError(t, err)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, authed(denylist{}))
+	assert.Equal(t, http.StatusUnauthorized, authed(denylist{jti: true}))
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rent-video-game/middleware"
+	"rent-video-game/mocks"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKeyIsScopedToRequestPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	guard := middleware.IdempotencyMiddleware(usecase.NewIdempotencyUsecase(mockRepo, time.Minute))
+
+	var routes []string
+	mockRepo.EXPECT().Reserve(gomock.Any()).Times(2).DoAndReturn(func(record *model.IdempotencyKeys) (*model.IdempotencyKeys, bool, error) {
+		routes = append(routes, record.Route)
+		return record, true, nil
+	})
+	mockRepo.EXPECT().Complete(gomock.Any(), http.StatusOK, gomock.Any(), gomock.Any()).Times(2).Return(nil)
+
+	e := echo.New()
+	userID := uuid.New().String()
+	for _, path := range []string{"/user/booking/1/pay", "/user/booking/2/pay"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set(middleware.IdempotencyKey, "key-1")
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/user/booking/:booking_id/pay")
+		c.Set("user_id", userID)
+
+		err := guard(func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		})(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, []string{"POST /user/booking/1/pay", "POST /user/booking/2/pay"}, routes)
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rent-video-game/middleware"
+	"rent-video-game/model"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, guard echo.MiddlewareFunc, roles, permissions []string) int {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("roles", roles)
+	c.Set("permissions", permissions)
+
+	err := guard(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c)
+	assert.NoError(t, err)
+
+	return rec.Code
+}
+
+func TestRequirePermissionNeedsEveryPermission(t *testing.T) {
+	guard := middleware.RequirePermission(model.PermBookingRent, model.PermWalletManage)
+
+	assert.Equal(t, http.StatusOK, serve(t, guard, nil, []string{"booking:rent", "wallet:manage"}))
+	assert.Equal(t, http.StatusForbidden, serve(t, guard, nil, []string{"booking:rent"}))
+	assert.Equal(t, http.StatusForbidden, serve(t, guard, nil, nil))
+}
+
+func TestRequireRoleNeedsAnyRole(t *testing.T) {
+	guard := middleware.RequireRole(model.RoleLessor, model.RoleAdmin)
+
+	assert.Equal(t, http.StatusOK, serve(t, guard, []string{"renter", "admin"}, nil))
+	assert.Equal(t, http.StatusForbidden, serve(t, guard, []string{"renter"}, nil))
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"rent-video-game/usecase"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// RequireVerifiedEmail lets the request through only once the user has
+// verified their email. It reads the user on every request, so verifying takes
+// effect without a new token. It must run after UserAuthMiddleware.
+func RequireVerifiedEmail(userUsecase *usecase.UserUsecase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userIDString, _ := c.Get("user_id").(string)
+			userID, err := uuid.Parse(userIDString)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"message": "invalid user id!",
+				})
+			}
+
+			user, err := userUsecase.GetUserByID(userID)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]interface{}{
+					"message": "user not found!",
+				})
+			}
+
+			if user.EmailVerifiedAt == nil {
+				return c.JSON(http.StatusForbidden, map[string]interface{}{
+					"message": "forbidden! please verify your email first",
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccountTokenPurpose string
+
+const (
+	PurposeVerifyEmail   AccountTokenPurpose = "verify_email"
+	PurposeResetPassword AccountTokenPurpose = "reset_password"
+)
+
+// AccountTokens records the signed links mailed for email verification and
+// password reset. The signed token carries the JTI; the row makes it single
+// use. Asking for a new link spends the ones sent before.
+type AccountTokens struct {
+	JTI       string              `json:"jti" gorm:"type:varchar(64); primaryKey"`
+	UserID    uuid.UUID           `json:"user_id" gorm:"type:uuid; not null; index"`
+	Purpose   AccountTokenPurpose `json:"purpose" gorm:"type:varchar(20); not null"`
+	ExpiresAt time.Time           `json:"expires_at" gorm:"type:timestamp; not null; index"`
+	UsedAt    *time.Time          `json:"used_at" gorm:"type:timestamp"`
+	CreatedAt time.Time           `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Users     Users               `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction string
+
+const (
+	AuditConsoleCreate AuditAction = "CONSOLE_CREATE"
+	AuditConsoleRename AuditAction = "CONSOLE_RENAME"
+	AuditConsoleMerge  AuditAction = "CONSOLE_MERGE"
+	AuditConsoleDelete AuditAction = "CONSOLE_DELETE"
+)
+
+// AuditLogs record who changed shared reference data and how. Changes holds
+// a JSON document describing the change; rows are never updated.
+type AuditLogs struct {
+	AuditLogID int         `json:"audit_log_id" gorm:"type:serial;primaryKey"`
+	ActorID    uuid.UUID   `json:"actor_id" gorm:"type:uuid; not null; index"`
+	Action     AuditAction `json:"action" gorm:"type:varchar(50); not null"`
+	EntityType string      `json:"entity_type" gorm:"type:varchar(50); not null; index:idx_audit_logs_entity"`
+	EntityID   int         `json:"entity_id" gorm:"type:int; not null; index:idx_audit_logs_entity"`
+	Changes    string      `json:"changes" gorm:"type:jsonb; not null; default:'{}'"`
+	CreatedAt  time.Time   `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+}
+
+type AuditLogResponse struct {
+	Message string      `json:"message"`
+	Data    []AuditLogs `json:"data"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"rent-video-game/currency"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,23 +11,75 @@ import (
 type BookingStatus string
 
 const (
-	Pending  BookingStatus = "PENDING"
-	Approved BookingStatus = "APPROVED"
-	Rejected BookingStatus = "REJECTED"
+	Pending   BookingStatus = "PENDING"
+	Approved  BookingStatus = "APPROVED"
+	Rejected  BookingStatus = "REJECTED"
+	Paid      BookingStatus = "PAID"
+	Active    BookingStatus = "ACTIVE"
+	Returned  BookingStatus = "RETURNED"
+	Overdue   BookingStatus = "OVERDUE"
+	Cancelled BookingStatus = "CANCELLED"
 )
 
+var BookingStatuses = []BookingStatus{Pending, Approved, Rejected, Paid, Active, Returned, Overdue, Cancelled}
+
+// BlockingBookingStatuses hold a product unit for the booking's date range.
+// Rejected, returned and cancelled bookings drop out of this set, which frees
+// their unit again.
+var BlockingBookingStatuses = []BookingStatus{Pending, Approved, Paid, Active, Overdue}
+
+// RatingBookingStatuses are the statuses of a booking that lets the renter
+// rate the product: it was approved, and was not cancelled or rejected since.
+var RatingBookingStatuses = []BookingStatus{Approved, Paid, Active, Overdue, Returned}
+
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	Pending:  {Approved, Rejected, Cancelled},
+	Approved: {Paid, Cancelled},
+	Paid:     {Active, Cancelled},
+	Active:   {Returned, Overdue},
+	Overdue:  {Returned},
+}
+
+func (s BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, status := range bookingTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Bookings are priced in the renter's wallet currency. ExchangeRate converts
+// the product currency into it and is fixed when the booking is created.
 type Bookings struct {
-	BookingID int            `json:"booking_id" gorm:"type:serial;primaryKey"`
-	UserID    uuid.UUID      `json:"user_id" gorm:"type:uuid; not null"`
-	ProductID int            `json:"product_id" gorm:"type:int; not null"`
-	StartDate string         `json:"start_date" gorm:"type:date; not null"`
-	EndDate   string         `json:"end_date" gorm:"type:date; not null"`
-	Status    BookingStatus  `json:"status" gorm:"type:booking_status; not null"`
-	CreatedAt time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
-	UpdatedAt time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
-	Users     Users          `json:"-" gorm:"foreignKey:UserID;references:UserID"`
-	Products  Products       `json:"-" gorm:"foreignKey:ProductID;references:ProductID"`
+	BookingID    int            `json:"booking_id" gorm:"type:serial;primaryKey"`
+	UserID       uuid.UUID      `json:"user_id" gorm:"type:uuid; not null"`
+	ProductID    int            `json:"product_id" gorm:"type:int; not null"`
+	UnitID       *int           `json:"unit_id" gorm:"type:int"`
+	StartDate    string         `json:"start_date" gorm:"type:date; not null"`
+	EndDate      string         `json:"end_date" gorm:"type:date; not null"`
+	Status       BookingStatus  `json:"status" gorm:"type:booking_status; not null"`
+	StatusReason string         `json:"status_reason" gorm:"type:text"`
+	Currency     currency.Code  `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	ExchangeRate string         `json:"exchange_rate" gorm:"type:decimal(20,10); not null; default:1"`
+	CreatedAt    time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt    time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
+	Users        Users          `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+	Products     Products       `json:"-" gorm:"foreignKey:ProductID;references:ProductID"`
+	Units        *ProductUnits  `json:"-" gorm:"foreignKey:UnitID;references:UnitID"`
+	Deposits     *Deposits      `json:"-" gorm:"foreignKey:BookingID;references:BookingID"`
+}
+
+type BookingStatusHistories struct {
+	HistoryID  int           `json:"history_id" gorm:"type:serial;primaryKey"`
+	BookingID  int           `json:"booking_id" gorm:"type:int; not null; index"`
+	FromStatus BookingStatus `json:"from_status" gorm:"type:booking_status; not null"`
+	ToStatus   BookingStatus `json:"to_status" gorm:"type:booking_status; not null"`
+	ChangedBy  *uuid.UUID    `json:"changed_by" gorm:"type:uuid"`
+	Reason     string        `json:"reason" gorm:"type:text"`
+	CreatedAt  time.Time     `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Bookings   Bookings      `json:"-" gorm:"foreignKey:BookingID;references:BookingID"`
 }
 
 type BookingRequest struct {
@@ -35,15 +88,35 @@ type BookingRequest struct {
 	EndDate   string `json:"end_date" validate:"required"`
 }
 
+type BookingDecisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// BookingReturnRequest lets the lessor keep part or all of the deposit when
+// the unit comes back damaged. The rest is released to the renter.
+type BookingReturnRequest struct {
+	BookingDecisionRequest
+	DepositCapture currency.Money `json:"deposit_capture"`
+	DamageNote     string         `json:"damage_note"`
+}
+
 type BookingData struct {
-	BookingID   int    `json:"booking_id"`
-	ProductName string `json:"product_name"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
-	Status      string `json:"status"`
+	BookingID    int       `json:"booking_id"`
+	ProductName  string    `json:"product_name"`
+	RenterName   string    `json:"renter_name,omitempty"`
+	StartDate    string    `json:"start_date"`
+	EndDate      string    `json:"end_date"`
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	Deposit      *Deposits `json:"deposit,omitempty"`
 }
 
 type BookingResponse struct {
 	Message string        `json:"message"`
 	Data    []BookingData `json:"data"`
 }
+
+type BookingHistoryResponse struct {
+	Message string                   `json:"message"`
+	Data    []BookingStatusHistories `json:"data"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"rent-video-game/currency"
+	"time"
+)
+
+// CancellationPolicies decide how much of the rent a renter gets back when
+// cancelling a paid booking. Cancelling at least FullRefundDays before the
+// start date refunds everything, later cancellations refund
+// PartialRefundPercent, and nothing is refunded once the unit was picked up.
+type CancellationPolicies struct {
+	PolicyID             int       `json:"policy_id" gorm:"type:serial;primaryKey"`
+	LessorID             int       `json:"lessor_id" gorm:"type:int; not null; uniqueIndex"`
+	FullRefundDays       int       `json:"full_refund_days" gorm:"type:int; not null"`
+	PartialRefundPercent float64   `json:"partial_refund_percent" gorm:"type:decimal(5,2); not null"`
+	CreatedAt            time.Time `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt            time.Time `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	Lessors              Lessors   `json:"-" gorm:"foreignKey:LessorID;references:LessorID"`
+}
+
+type CancellationPolicyRequest struct {
+	FullRefundDays       int     `json:"full_refund_days"`
+	PartialRefundPercent float64 `json:"partial_refund_percent"`
+}
+
+type CancellationPolicyResponse struct {
+	Message string               `json:"message"`
+	Data    CancellationPolicies `json:"data"`
+}
+
+type CancellationResult struct {
+	Booking  Bookings
+	Reversal *Transactions
+	Deposit  *Deposits
+}
+
+type CancellationData struct {
+	BookingID             int            `json:"booking_id"`
+	Status                string         `json:"status"`
+	RefundPercent         float64        `json:"refund_percent"`
+	Currency              currency.Code  `json:"currency,omitempty"`
+	RefundAmount          currency.Money `json:"refund_amount"`
+	DepositReleased       currency.Money `json:"deposit_released,omitempty"`
+	ReversalTransactionID int            `json:"reversal_transaction_id,omitempty"`
+}
+
+type CancellationResponse struct {
+	Message string           `json:"message"`
+	Data    CancellationData `json:"data"`
+}
@@ -14,6 +14,14 @@ type Consoles struct {
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
 }
 
+type ConsoleRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type ConsoleMergeRequest struct {
+	TargetConsoleID int `json:"target_console_id" validate:"required"`
+}
+
 type ConsoleData struct {
 	ConsoleID int    `json:"console_id"`
 	Name      string `json:"name"`
@@ -23,3 +31,20 @@ type ConsoleResponse struct {
 	Message string        `json:"message"`
 	Data    []ConsoleData `json:"data"`
 }
+
+// ConsoleMergeResult is the console that survived a merge and how many
+// products were moved onto it.
+type ConsoleMergeResult struct {
+	Console       Consoles
+	MergedID      int
+	MovedProducts int64
+}
+
+type ConsoleMergeResponse struct {
+	Message string `json:"message"`
+	Data    struct {
+		ConsoleData
+		MergedConsoleID int   `json:"merged_console_id"`
+		MovedProducts   int64 `json:"moved_products"`
+	} `json:"data"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"rent-video-game/currency"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DepositStatus string
+
+const (
+	DepositHeld              DepositStatus = "HELD"
+	DepositReleased          DepositStatus = "RELEASED"
+	DepositPartiallyCaptured DepositStatus = "PARTIALLY_CAPTURED"
+	DepositCaptured          DepositStatus = "CAPTURED"
+)
+
+// Deposits track the security deposit held in escrow for a paid booking.
+// Late fees and lessor captures are paid out of the held amount; whatever is
+// left goes back to the renter when the booking is settled. Amounts are in the
+// booking currency the renter paid in.
+type Deposits struct {
+	DepositID   int            `json:"deposit_id" gorm:"type:serial;primaryKey"`
+	BookingID   int            `json:"booking_id" gorm:"type:int; not null; uniqueIndex"`
+	UserID      uuid.UUID      `json:"user_id" gorm:"type:uuid; not null"`
+	LessorID    int            `json:"lessor_id" gorm:"type:int; not null"`
+	Currency    currency.Code  `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	Amount      currency.Money `json:"amount" gorm:"type:numeric(14,2); not null"`
+	LateFees    currency.Money `json:"late_fees" gorm:"type:numeric(14,2); not null; default:0"`
+	LateFeeDays int            `json:"late_fee_days" gorm:"type:int; not null; default:0"`
+	Captured    currency.Money `json:"captured" gorm:"type:numeric(14,2); not null; default:0"`
+	Released    currency.Money `json:"released" gorm:"type:numeric(14,2); not null; default:0"`
+	DamageNote  string         `json:"damage_note" gorm:"type:text"`
+	Status      DepositStatus  `json:"status" gorm:"type:varchar(50); not null"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	Lessors     Lessors        `json:"-" gorm:"foreignKey:LessorID;references:LessorID"`
+}
+
+func (d *Deposits) Remaining() currency.Money {
+	return d.Amount - d.LateFees - d.Captured - d.Released
+}
+
+type DepositResponse struct {
+	Message string   `json:"message"`
+	Data    Deposits `json:"data"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeys remember the first response to a request sent with an
+// Idempotency-Key header so a retry of the same request gets the same answer
+// instead of running twice. A row without a status code is still in flight,
+// until its lease runs out and a retry takes it over.
+type IdempotencyKeys struct {
+	IdempotencyKeyID int       `json:"idempotency_key_id" gorm:"type:serial;primaryKey"`
+	UserID           uuid.UUID `json:"user_id" gorm:"type:uuid; not null; uniqueIndex:idx_idempotency_keys_scope"`
+	Key              string    `json:"key" gorm:"type:varchar(255); not null; uniqueIndex:idx_idempotency_keys_scope"`
+	Route            string    `json:"route" gorm:"type:varchar(255); not null; uniqueIndex:idx_idempotency_keys_scope"`
+	RequestHash      string    `json:"request_hash" gorm:"type:varchar(64); not null"`
+	StatusCode       int       `json:"status_code" gorm:"type:int; not null; default:0"`
+	ContentType      string    `json:"content_type" gorm:"type:varchar(255)"`
+	ResponseBody     []byte    `json:"-" gorm:"type:bytea"`
+	CreatedAt        time.Time `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime; index"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+}
+
+func (k *IdempotencyKeys) Completed() bool {
+	return k.StatusCode != 0
+}
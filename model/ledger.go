@@ -0,0 +1,94 @@
+package model
+
+import (
+	"rent-video-game/currency"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LedgerAccountType string
+
+const (
+	UserWalletAccount      LedgerAccountType = "USER_WALLET"
+	PaymentClearingAccount LedgerAccountType = "PAYMENT_CLEARING"
+	EscrowAccount          LedgerAccountType = "ESCROW"
+	WithdrawalHoldAccount  LedgerAccountType = "WITHDRAWAL_HOLD"
+	ExchangeAccount        LedgerAccountType = "EXCHANGE"
+)
+
+type JournalEntryType string
+
+const (
+	OpeningBalanceEntry    JournalEntryType = "OPENING_BALANCE"
+	TopupEntry             JournalEntryType = "TOPUP"
+	RentalPaymentEntry     JournalEntryType = "RENTAL_PAYMENT"
+	RefundEntry            JournalEntryType = "REFUND"
+	PayoutEntry            JournalEntryType = "PAYOUT"
+	DepositHoldEntry       JournalEntryType = "DEPOSIT_HOLD"
+	DepositReleaseEntry    JournalEntryType = "DEPOSIT_RELEASE"
+	DepositCaptureEntry    JournalEntryType = "DEPOSIT_CAPTURE"
+	LateFeeEntry           JournalEntryType = "LATE_FEE"
+	WithdrawalHoldEntry    JournalEntryType = "WITHDRAWAL_HOLD"
+	WithdrawalReleaseEntry JournalEntryType = "WITHDRAWAL_RELEASE"
+)
+
+// LedgerAccounts holds one wallet account per user plus the system accounts
+// money flows through when it enters or leaves the platform. Every account
+// holds a single currency; system accounts exist once per currency and the
+// EXCHANGE accounts take the other side of a conversion.
+type LedgerAccounts struct {
+	AccountID int               `json:"account_id" gorm:"type:serial;primaryKey"`
+	UserID    *uuid.UUID        `json:"user_id" gorm:"type:uuid; uniqueIndex"`
+	Type      LedgerAccountType `json:"type" gorm:"type:varchar(50); not null; index"`
+	Currency  currency.Code     `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	CreatedAt time.Time         `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Users     *Users            `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+}
+
+// JournalEntries and Postings are append-only: a correction is a new entry,
+// never an update. The postings of one entry sum to zero in every currency.
+type JournalEntries struct {
+	JournalEntryID int              `json:"journal_entry_id" gorm:"type:serial;primaryKey"`
+	Type           JournalEntryType `json:"type" gorm:"type:varchar(50); not null"`
+	Reference      string           `json:"reference" gorm:"type:varchar(255); index"`
+	Description    string           `json:"description" gorm:"type:text"`
+	CreatedAt      time.Time        `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Postings       []Postings       `json:"postings" gorm:"foreignKey:JournalEntryID;references:JournalEntryID"`
+}
+
+type Postings struct {
+	PostingID      int            `json:"posting_id" gorm:"type:serial;primaryKey"`
+	JournalEntryID int            `json:"journal_entry_id" gorm:"type:int; not null; index"`
+	AccountID      int            `json:"account_id" gorm:"type:int; not null; index"`
+	Amount         currency.Money `json:"amount" gorm:"type:numeric(14,2); not null"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+}
+
+type LedgerStatementData struct {
+	JournalEntryID int              `json:"journal_entry_id"`
+	Type           JournalEntryType `json:"type"`
+	Reference      string           `json:"reference"`
+	Description    string           `json:"description"`
+	Amount         currency.Money   `json:"amount"`
+	Balance        currency.Money   `json:"balance"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type LedgerStatementResponse struct {
+	Message string                `json:"message"`
+	Data    []LedgerStatementData `json:"data"`
+}
+
+type LedgerReconciliationData struct {
+	UserID        uuid.UUID      `json:"user_id"`
+	CachedBalance currency.Money `json:"cached_balance"`
+	LedgerBalance currency.Money `json:"ledger_balance"`
+	Balanced      bool           `json:"balanced"`
+}
+
+type LedgerReconciliationResponse struct {
+	Message  string                     `json:"message"`
+	Balanced bool                       `json:"balanced"`
+	Data     []LedgerReconciliationData `json:"data"`
+}
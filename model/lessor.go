@@ -29,5 +29,16 @@ type LessorResponse struct {
 		LessorID int    `json:"lessor_id"`
 		Name     string `json:"name"`
 		Location string `json:"location"`
+		Token    string `json:"token,omitempty"`
 	} `json:"data"`
 }
+
+// LessorPublicData is the part of a lessor's profile shown to renters. Rating
+// sums up the ratings of all their products.
+type LessorPublicData struct {
+	LessorID    int               `json:"lessor_id"`
+	Name        string            `json:"name"`
+	Location    string            `json:"location"`
+	MemberSince time.Time         `json:"member_since"`
+	Rating      RatingSummaryData `json:"rating"`
+}
@@ -0,0 +1,26 @@
+package model
+
+// MoneyColumn names a column that holds a currency.Money amount.
+type MoneyColumn struct {
+	Table  string
+	Column string
+}
+
+// MoneyColumns are NUMERIC(14, 2). Databases that stored them as BIGINT minor
+// units are converted in place by the startup migration.
+var MoneyColumns = []MoneyColumn{
+	{"users", "amount"},
+	{"topup_histories", "amount"},
+	{"topup_histories", "refunded_amount"},
+	{"products", "rental_cost_per_month"},
+	{"products", "deposit_amount"},
+	{"products", "late_fee_per_day"},
+	{"transactions", "amount"},
+	{"transactions", "settlement_amount"},
+	{"deposits", "amount"},
+	{"deposits", "late_fees"},
+	{"deposits", "captured"},
+	{"deposits", "released"},
+	{"postings", "amount"},
+	{"withdrawals", "amount"},
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type PaymentEventStatus string
+
+const (
+	PaymentEventProcessed PaymentEventStatus = "PROCESSED"
+	PaymentEventFailed    PaymentEventStatus = "FAILED"
+	PaymentEventIgnored   PaymentEventStatus = "IGNORED"
+)
+
+// PaymentEvents records every gateway event that was acted on. Gateways deliver
+// events at least once, so the event ID is the primary key and a redelivered
+// event is recognised and skipped.
+type PaymentEvents struct {
+	EventID   string             `json:"event_id" gorm:"type:varchar(255);primaryKey"`
+	Type      string             `json:"type" gorm:"type:varchar(255); not null"`
+	PaymentID string             `json:"payment_id" gorm:"type:varchar(255); index"`
+	Status    PaymentEventStatus `json:"status" gorm:"type:varchar(50); not null"`
+	Error     string             `json:"error" gorm:"type:text"`
+	CreatedAt time.Time          `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+}
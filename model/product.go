@@ -1,51 +1,66 @@
 package model
 
 import (
+	"rent-video-game/currency"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Products struct {
-	ProductID          int            `json:"product_id" gorm:"type:serial;primaryKey"`
-	LessorID           int            `json:"lessor_id" gorm:"type:int; not null"`
-	ConsoleID          int            `json:"console_id" gorm:"type:int; not null"`
-	Name               string         `json:"name" gorm:"type:varchar(255); not null"`
-	Description        string         `json:"description" gorm:"type:text; not null"`
-	RentalCostPerMonth float64        `json:"rental_cost_per_month" gorm:"type:decimal(10,2); not null"`
-	StockAvailability  int            `json:"stock_availability" gorm:"type:int; not null"`
-	CreatedAt          time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
-	UpdatedAt          time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
-	DeletedAt          gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
-	Lessors            Lessors        `json:"-" gorm:"foreignKey:LessorID;references:LessorID"`
-	Consoles           Consoles       `json:"-" gorm:"foreignKey:ConsoleID;references:ConsoleID"`
+	ProductID          int                `json:"product_id" gorm:"type:serial;primaryKey"`
+	LessorID           int                `json:"lessor_id" gorm:"type:int; not null"`
+	ConsoleID          int                `json:"console_id" gorm:"type:int; not null; index"`
+	Name               string             `json:"name" gorm:"type:varchar(255); not null"`
+	Description        string             `json:"description" gorm:"type:text; not null"`
+	Currency           currency.Code      `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	RentalCostPerMonth currency.Money     `json:"rental_cost_per_month" gorm:"type:numeric(14,2); not null"`
+	DepositAmount      currency.Money     `json:"deposit_amount" gorm:"type:numeric(14,2); not null; default:0"`
+	LateFeePerDay      currency.Money     `json:"late_fee_per_day" gorm:"type:numeric(14,2); not null; default:0"`
+	StockAvailability  int                `json:"stock_availability" gorm:"type:int; not null"`
+	CreatedAt          time.Time          `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt          time.Time          `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	DeletedAt          gorm.DeletedAt     `json:"deleted_at" gorm:"type:timestamp"`
+	Lessors            Lessors            `json:"-" gorm:"foreignKey:LessorID;references:LessorID"`
+	Consoles           Consoles           `json:"-" gorm:"foreignKey:ConsoleID;references:ConsoleID"`
+	RatingStats        ProductRatingStats `json:"-" gorm:"foreignKey:ProductID;references:ProductID"`
 }
 
 type ProductRequest struct {
-	ConsoleID          int     `json:"console_id" validate:"required"`
-	Name               string  `json:"name" validate:"required"`
-	Description        string  `json:"description" validate:"required"`
-	RentalCostPerMonth float64 `json:"rental_cost_per_month" validate:"required"`
-	StockAvailability  int     `json:"stock_availability" validate:"required"`
+	ConsoleID          int            `json:"console_id" validate:"required"`
+	Name               string         `json:"name" validate:"required"`
+	Description        string         `json:"description" validate:"required"`
+	RentalCostPerMonth currency.Money `json:"rental_cost_per_month" validate:"required"`
+	DepositAmount      currency.Money `json:"deposit_amount"`
+	LateFeePerDay      currency.Money `json:"late_fee_per_day"`
+	StockAvailability  int            `json:"stock_availability" validate:"required"`
 }
 
 type ProductData struct {
-	ProductID          int     `json:"product_id"`
-	ConsoleName        string  `json:"console_name"`
-	Name               string  `json:"name"`
-	Description        string  `json:"description"`
-	RentalCostPerMonth float64 `json:"rental_cost_per_month"`
-	Stars              float64 `json:"stars"`
-	StockAvailability  int     `json:"stock_availability"`
+	ProductID          int            `json:"product_id"`
+	ConsoleName        string         `json:"console_name"`
+	Name               string         `json:"name"`
+	Description        string         `json:"description"`
+	Currency           currency.Code  `json:"currency"`
+	RentalCostPerMonth currency.Money `json:"rental_cost_per_month"`
+	DepositAmount      currency.Money `json:"deposit_amount"`
+	LateFeePerDay      currency.Money `json:"late_fee_per_day"`
+	Stars              float64        `json:"stars"`
+	StockAvailability  int            `json:"stock_availability"`
 }
 
 type ProductPublicData struct {
-	ProductID          int     `json:"product_id"`
-	Name               string  `json:"name"`
-	RentalCostPerMonth float64 `json:"rental_cost_per_month"`
-	Stars              float64 `json:"stars"`
-	StockAvailability  int     `json:"stock_availability"`
-	Location           string  `json:"location"`
+	ProductID          int            `json:"product_id"`
+	ConsoleID          int            `json:"console_id"`
+	Name               string         `json:"name"`
+	Currency           currency.Code  `json:"currency"`
+	RentalCostPerMonth currency.Money `json:"rental_cost_per_month"`
+	DepositAmount      currency.Money `json:"deposit_amount"`
+	LateFeePerDay      currency.Money `json:"late_fee_per_day"`
+	Stars              float64        `json:"stars"`
+	RatingCount        int            `json:"rating_count"`
+	StockAvailability  int            `json:"stock_availability"`
+	Location           string         `json:"location"`
 }
 
 type ProductResponse struct {
@@ -57,3 +72,113 @@ type ProductPublicResponse struct {
 	Message string              `json:"message"`
 	Data    []ProductPublicData `json:"data"`
 }
+
+type ProductSort string
+
+const (
+	SortNewest    ProductSort = "newest"
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortRating    ProductSort = "rating"
+)
+
+var ProductSorts = []ProductSort{SortNewest, SortPriceAsc, SortPriceDesc, SortRating}
+
+// ProductFilter narrows and orders the public catalogue. Prices are compared
+// in Currency; PriceRates holds, for each product currency, the rate into
+// Currency as a decimal string.
+type ProductFilter struct {
+	ConsoleID  int
+	MinPrice   *currency.Money
+	MaxPrice   *currency.Money
+	Currency   currency.Code
+	PriceRates map[currency.Code]string
+	MinStars   float64
+	InStock    bool
+	Location   string
+	Sort       ProductSort
+	After      *ProductCursor
+	Limit      int
+}
+
+// ProductCursor points just past the last product of a page: its sort key
+// rendered as text, with the product ID breaking ties.
+type ProductCursor struct {
+	Sort      ProductSort   `json:"sort"`
+	Currency  currency.Code `json:"currency,omitempty"`
+	Key       string        `json:"key"`
+	ProductID int           `json:"product_id"`
+}
+
+// ProductListing is one catalogue row, with the lessor's location and the
+// average rating joined in.
+type ProductListing struct {
+	ProductID          int
+	ConsoleID          int
+	Name               string
+	Currency           currency.Code
+	RentalCostPerMonth currency.Money
+	DepositAmount      currency.Money
+	LateFeePerDay      currency.Money
+	StockAvailability  int
+	Location           string
+	Stars              float64
+	RatingCount        int
+	SortKey            string
+}
+
+type ProductPage struct {
+	Products   []ProductListing
+	NextCursor string
+}
+
+type ProductPageResponse struct {
+	Message    string              `json:"message"`
+	Data       []ProductPublicData `json:"data"`
+	NextCursor string              `json:"next_cursor"`
+}
+
+type ProductMatch string
+
+const (
+	MatchFullText ProductMatch = "fulltext"
+	MatchFuzzy    ProductMatch = "fuzzy"
+)
+
+// ProductSearchResult is one hit of a text search. NameHighlight and Snippet
+// wrap the matched words in <mark> tags.
+type ProductSearchResult struct {
+	ProductListing
+	ConsoleName   string
+	Rank          float64
+	NameHighlight string
+	Snippet       string
+}
+
+type ProductSearchData struct {
+	ProductPublicData
+	ConsoleName   string       `json:"console_name"`
+	Rank          float64      `json:"rank"`
+	Match         ProductMatch `json:"match"`
+	NameHighlight string       `json:"name_highlight"`
+	Snippet       string       `json:"snippet"`
+}
+
+type ProductSearchResponse struct {
+	Message string              `json:"message"`
+	Data    []ProductSearchData `json:"data"`
+}
+
+// ProductDetailData is the public product page: the product, its console,
+// the lessor renting it out and a summary of its ratings.
+type ProductDetailData struct {
+	ProductData
+	Console ConsoleData       `json:"console"`
+	Lessor  LessorPublicData  `json:"lessor"`
+	Rating  RatingSummaryData `json:"rating"`
+}
+
+type ProductDetailResponse struct {
+	Message string            `json:"message"`
+	Data    ProductDetailData `json:"data"`
+}
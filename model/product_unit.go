@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProductUnits are the physical copies of a product. Products.StockAvailability
+// is kept equal to the number of active units.
+type ProductUnits struct {
+	UnitID    int            `json:"unit_id" gorm:"type:serial;primaryKey"`
+	ProductID int            `json:"product_id" gorm:"type:int; not null; index"`
+	Label     string         `json:"label" gorm:"type:varchar(255); not null"`
+	CreatedAt time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
+	Products  Products       `json:"-" gorm:"foreignKey:ProductID;references:ProductID"`
+}
+
+type AvailabilityData struct {
+	Date       string `json:"date"`
+	TotalUnits int    `json:"total_units"`
+	FreeUnits  int    `json:"free_units"`
+}
+
+type AvailabilityResponse struct {
+	Message string             `json:"message"`
+	Data    []AvailabilityData `json:"data"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import "rent-video-game/currency"
+
+// QuoteData prices a booking in its currency. RentalCostPerMonth and
+// ProductAmount stay in the product currency; ProductAmount is what the lessor
+// is paid.
+type QuoteData struct {
+	BookingID          int            `json:"booking_id"`
+	ProductID          int            `json:"product_id"`
+	StartDate          string         `json:"start_date"`
+	EndDate            string         `json:"end_date"`
+	RentalDays         int            `json:"rental_days"`
+	BilledDays         int            `json:"billed_days"`
+	BilledMonths       int            `json:"billed_months"`
+	ProductCurrency    currency.Code  `json:"product_currency"`
+	RentalCostPerMonth currency.Money `json:"rental_cost_per_month"`
+	ProductAmount      currency.Money `json:"product_amount"`
+	ExchangeRate       string         `json:"exchange_rate"`
+	Currency           currency.Code  `json:"currency"`
+	Amount             currency.Money `json:"amount"`
+	DepositAmount      currency.Money `json:"deposit_amount"`
+	TotalDue           currency.Money `json:"total_due"`
+}
+
+type QuoteResponse struct {
+	Message string    `json:"message"`
+	Data    QuoteData `json:"data"`
+}
@@ -13,25 +13,88 @@ type Ratings struct {
 	ProductID int            `json:"product_id" gorm:"type:int; not null"`
 	Review    string         `json:"review" gorm:"type:text"`
 	Stars     float64        `json:"stars" gorm:"type:decimal(2,1); not null"`
+	Reply     string         `json:"reply" gorm:"type:text"`
+	RepliedAt *time.Time     `json:"replied_at" gorm:"type:timestamp"`
 	CreatedAt time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
 	UpdatedAt time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
 }
 
+// ProductRatingStats aggregates a product's ratings so listings can join them
+// in instead of averaging ratings per product. It is recomputed from the
+// ratings table whenever a rating of the product is written. The histogram
+// counts ratings rounded to the nearest whole star.
+type ProductRatingStats struct {
+	ProductID    int       `json:"product_id" gorm:"type:int; primaryKey; autoIncrement:false"`
+	RatingCount  int       `json:"rating_count" gorm:"type:int; not null; default:0"`
+	AverageStars float64   `json:"average_stars" gorm:"type:numeric(3,2); not null; default:0"`
+	Stars1       int       `json:"stars_1" gorm:"column:stars_1; type:int; not null; default:0"`
+	Stars2       int       `json:"stars_2" gorm:"column:stars_2; type:int; not null; default:0"`
+	Stars3       int       `json:"stars_3" gorm:"column:stars_3; type:int; not null; default:0"`
+	Stars4       int       `json:"stars_4" gorm:"column:stars_4; type:int; not null; default:0"`
+	Stars5       int       `json:"stars_5" gorm:"column:stars_5; type:int; not null; default:0"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+}
+
+func (s *ProductRatingStats) Summary() RatingSummaryData {
+	return RatingSummaryData{
+		AverageStars: s.AverageStars,
+		RatingCount:  s.RatingCount,
+		Histogram:    map[int]int{1: s.Stars1, 2: s.Stars2, 3: s.Stars3, 4: s.Stars4, 5: s.Stars5},
+	}
+}
+
 type RatingRequest struct {
 	ProductID int     `json:"product_id" validate:"required"`
 	Review    string  `json:"review" validate:"required"`
 	Stars     float64 `json:"stars" validate:"required"`
 }
 
+type RatingUpdateRequest struct {
+	Review string  `json:"review" validate:"required"`
+	Stars  float64 `json:"stars" validate:"required"`
+}
+
+type RatingReplyRequest struct {
+	Reply string `json:"reply" validate:"required"`
+}
+
 type RatingData struct {
-	RatingID  int     `json:"rating_id"`
-	ProductID int     `json:"product_id"`
-	Review    string  `json:"review"`
-	Stars     float64 `json:"stars"`
+	RatingID  int        `json:"rating_id"`
+	ProductID int        `json:"product_id"`
+	Review    string     `json:"review"`
+	Stars     float64    `json:"stars"`
+	Reply     string     `json:"reply,omitempty"`
+	RepliedAt *time.Time `json:"replied_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 type RatingResponse struct {
 	Message string       `json:"message"`
 	Data    []RatingData `json:"data"`
 }
+
+// RatingSummaryData is an average rating. Histogram counts ratings by whole
+// stars, from 1 to 5, and is left out where it is not known.
+type RatingSummaryData struct {
+	AverageStars float64     `json:"average_stars"`
+	RatingCount  int         `json:"rating_count"`
+	Histogram    map[int]int `json:"histogram,omitempty"`
+}
+
+// RatingCursor is where the previous page of a product's ratings ended.
+// Ratings are listed newest first, so the next page starts below RatingID.
+type RatingCursor struct {
+	RatingID int `json:"rating_id"`
+}
+
+type RatingPage struct {
+	Ratings    []Ratings
+	NextCursor string
+}
+
+type RatingPageResponse struct {
+	Message    string       `json:"message"`
+	Data       []RatingData `json:"data"`
+	NextCursor string       `json:"next_cursor"`
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RoleName string
+
+const (
+	RoleAdmin  RoleName = "admin"
+	RoleLessor RoleName = "lessor"
+	RoleRenter RoleName = "renter"
+)
+
+type Permission string
+
+const (
+	PermBookingRent      Permission = "booking:rent"
+	PermWalletManage     Permission = "wallet:manage"
+	PermRatingCreate     Permission = "rating:create"
+	PermLessorRegister   Permission = "lessor:register"
+	PermProductManage    Permission = "product:manage"
+	PermBookingManage    Permission = "booking:manage"
+	PermConsoleManage    Permission = "console:manage"
+	PermUserManage       Permission = "user:manage"
+	PermWithdrawalManage Permission = "withdrawal:manage"
+	PermLedgerAudit      Permission = "ledger:audit"
+	PermAuditLogRead     Permission = "audit:read"
+)
+
+// DefaultRolePermissions is seeded into the roles tables at startup. Every
+// user holds the renter role; registering as a lessor adds the lessor role.
+var DefaultRolePermissions = map[RoleName][]Permission{
+	RoleRenter: {PermBookingRent, PermWalletManage, PermRatingCreate, PermLessorRegister},
+	RoleLessor: {PermProductManage, PermBookingManage},
+	RoleAdmin:  {PermConsoleManage, PermUserManage, PermWithdrawalManage, PermLedgerAudit, PermAuditLogRead},
+}
+
+type Roles struct {
+	RoleID      int           `json:"role_id" gorm:"type:serial;primaryKey"`
+	Name        RoleName      `json:"name" gorm:"type:varchar(50); not null; uniqueIndex"`
+	CreatedAt   time.Time     `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Permissions []Permissions `json:"permissions" gorm:"many2many:role_permissions;joinForeignKey:RoleID;joinReferences:PermissionID"`
+}
+
+type Permissions struct {
+	PermissionID int        `json:"permission_id" gorm:"type:serial;primaryKey"`
+	Name         Permission `json:"name" gorm:"type:varchar(100); not null; uniqueIndex"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+}
+
+type UserRoles struct {
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid; primaryKey"`
+	RoleID    int       `json:"role_id" gorm:"type:int; primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Users     Users     `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+	Roles     Roles     `json:"-" gorm:"foreignKey:RoleID;references:RoleID"`
+}
+
+// Access is what a user may do, as carried in their token.
+type Access struct {
+	Roles       []RoleName   `json:"roles"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type RoleResponse struct {
+	Message string  `json:"message"`
+	Data    []Roles `json:"data"`
+}
+
+type UserRoleRequest struct {
+	Role RoleName `json:"role" validate:"required"`
+}
+
+type UserRoleResponse struct {
+	Message string `json:"message"`
+	Data    struct {
+		UserID uuid.UUID `json:"user_id"`
+		Access
+	} `json:"data"`
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"rent-video-game/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookingCanTransitionTo(t *testing.T) {
+	legal := map[model.BookingStatus][]model.BookingStatus{
+		model.Pending:  {model.Approved, model.Rejected, model.Cancelled},
+		model.Approved: {model.Paid, model.Cancelled},
+		model.Paid:     {model.Active, model.Cancelled},
+		model.Active:   {model.Returned, model.Overdue},
+		model.Overdue:  {model.Returned},
+	}
+
+	for _, from := range model.BookingStatuses {
+		for _, to := range model.BookingStatuses {
+			want := false
+			for _, next := range legal[from] {
+				if next == to {
+					want = true
+				}
+			}
+
+			assert.Equal(t, want, from.CanTransitionTo(to), "%s to %s", from, to)
+		}
+	}
+}
+
+func TestRejectedBookingFreesUnit(t *testing.T) {
+	assert.Contains(t, model.BlockingBookingStatuses, model.Pending)
+	assert.NotContains(t, model.BlockingBookingStatuses, model.Rejected)
+}
+
+func TestReturnedAndCancelledBookingsFreeUnit(t *testing.T) {
+	assert.NotContains(t, model.BlockingBookingStatuses, model.Returned)
+	assert.NotContains(t, model.BlockingBookingStatuses, model.Cancelled)
+	assert.Contains(t, model.BlockingBookingStatuses, model.Overdue)
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokens rotate: each refresh marks the presented token used and
+// issues a new one in the same family. Only a SHA-256 hash of the token is
+// stored. Presenting a used or revoked token again means it leaked, and the
+// whole family is revoked.
+type RefreshTokens struct {
+	RefreshTokenID int        `json:"refresh_token_id" gorm:"type:serial;primaryKey"`
+	UserID         uuid.UUID  `json:"user_id" gorm:"type:uuid; not null; index"`
+	FamilyID       uuid.UUID  `json:"family_id" gorm:"type:uuid; not null; index"`
+	TokenHash      string     `json:"-" gorm:"type:varchar(64); not null; uniqueIndex"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"type:timestamp; not null"`
+	UsedAt         *time.Time `json:"used_at" gorm:"type:timestamp"`
+	RevokedAt      *time.Time `json:"revoked_at" gorm:"type:timestamp"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	Users          Users      `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+}
+
+// RevokedTokens is the denylist of access tokens, by jti, that were logged out
+// before they expired. Rows can be purged once ExpiresAt has passed.
+type RevokedTokens struct {
+	JTI       string    `json:"jti" gorm:"type:varchar(64); primaryKey"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid; not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"type:timestamp; not null; index"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+}
+
+// TokenPair is what a login or refresh hands out.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	Message string    `json:"message"`
+	Data    TokenPair `json:"data"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"rent-video-game/currency"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,8 +11,10 @@ import (
 type TopupHistory struct {
 	TopupHistoryID int            `json:"topup_history_id" gorm:"type:serial;primaryKey"`
 	UserID         uuid.UUID      `json:"user_id" gorm:"type:uuid; not null"`
-	PaymentID      string         `json:"payment_id" gorm:"type:varchar(255); not null"`
-	Amount         float64        `json:"amount" gorm:"type:decimal(10,2); not null"`
+	PaymentID      string         `json:"payment_id" gorm:"type:varchar(255); not null; uniqueIndex"`
+	Currency       currency.Code  `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	Amount         currency.Money `json:"amount" gorm:"type:numeric(14,2); not null"`
+	RefundedAmount currency.Money `json:"refunded_amount" gorm:"type:numeric(14,2); not null; default:0"`
 	CreatedAt      time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
 	UpdatedAt      time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
 	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
@@ -19,14 +22,16 @@ type TopupHistory struct {
 }
 
 type TopupHistoryRequest struct {
-	Amount float64 `json:"amount" validate:"required"`
+	Amount currency.Money `json:"amount" validate:"required"`
 }
 
 type TopupHistoryData struct {
-	TopupHistoryID int       `json:"topup_history_id"`
-	PaymentID      string    `json:"payment_id"`
-	Amount         float64   `json:"amount"`
-	CreatedAt      time.Time `json:"created_at"`
+	TopupHistoryID int            `json:"topup_history_id"`
+	PaymentID      string         `json:"payment_id"`
+	Currency       currency.Code  `json:"currency"`
+	Amount         currency.Money `json:"amount"`
+	RefundedAmount currency.Money `json:"refunded_amount"`
+	CreatedAt      time.Time      `json:"created_at"`
 }
 
 type TopupHistoryResponse struct {
@@ -1,41 +1,72 @@
 package model
 
 import (
+	"rent-video-game/currency"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+type TransactionType string
+
+const (
+	PaymentTransaction  TransactionType = "PAYMENT"
+	ReversalTransaction TransactionType = "REVERSAL"
+)
+
+// Transactions are never edited once written. A refund is recorded as a
+// REVERSAL row pointing at the payment it gives money back for. Amount is
+// what the renter paid in the booking currency; SettlementAmount is what the
+// lessor received in the product currency at ExchangeRate.
 type Transactions struct {
-	TransactionID int            `json:"transaction_id" gorm:"type:serial;primaryKey"`
-	BookingID     int            `json:"booking_id" gorm:"type:int; not null"`
-	UserID        uuid.UUID      `json:"user_id" gorm:"type:uuid; not null"`
-	LessorID      int            `json:"lessor_id" gorm:"type:int; not null"`
-	Amount        float64        `json:"amount" gorm:"type:decimal(10,2); not null"`
-	CreatedAt     time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
-	UpdatedAt     time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
-	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
-	Bookings      Bookings       `json:"-" gorm:"foreignKey:BookingID;references:BookingID"`
-	Users         Users          `json:"-" gorm:"foreignKey:UserID;references:UserID"`
-	Lessors       Lessors        `json:"-" gorm:"foreignKey:LessorID;references:LessorID"`
+	TransactionID      int             `json:"transaction_id" gorm:"type:serial;primaryKey"`
+	BookingID          int             `json:"booking_id" gorm:"type:int; not null"`
+	UserID             uuid.UUID       `json:"user_id" gorm:"type:uuid; not null"`
+	LessorID           int             `json:"lessor_id" gorm:"type:int; not null"`
+	Type               TransactionType `json:"type" gorm:"type:varchar(50); not null; default:PAYMENT"`
+	ReversalOfID       *int            `json:"reversal_of_id,omitempty" gorm:"type:int; index"`
+	Currency           currency.Code   `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	Amount             currency.Money  `json:"amount" gorm:"type:numeric(14,2); not null"`
+	SettlementCurrency currency.Code   `json:"settlement_currency" gorm:"type:varchar(3); not null; default:USD"`
+	SettlementAmount   currency.Money  `json:"settlement_amount" gorm:"type:numeric(14,2); not null; default:0"`
+	ExchangeRate       string          `json:"exchange_rate" gorm:"type:decimal(20,10); not null; default:1"`
+	CreatedAt          time.Time       `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt          time.Time       `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	DeletedAt          gorm.DeletedAt  `json:"deleted_at" gorm:"type:timestamp"`
+	Bookings           Bookings        `json:"-" gorm:"foreignKey:BookingID;references:BookingID"`
+	Users              Users           `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+	Lessors            Lessors         `json:"-" gorm:"foreignKey:LessorID;references:LessorID"`
 }
 
 type TransactionRequest struct {
-	BookingID int       `json:"booking_id" validate:"required"`
-	UserID    uuid.UUID `json:"user_id" validate:"required"`
-	LessorID  int       `json:"lessor_id" validate:"required"`
-	Amount    float64   `json:"amount" validate:"required"`
+	BookingID int            `json:"booking_id" validate:"required"`
+	Amount    currency.Money `json:"amount"`
 }
 
 type TransactionData struct {
-	TransactionID int     `json:"transaction_id"`
-	BookingID     int     `json:"booking_id"`
-	ReceiveID     int     `json:"receive_id"`
-	Amount        float64 `json:"amount"`
+	TransactionID      int            `json:"transaction_id"`
+	BookingID          int            `json:"booking_id"`
+	ReceiveID          int            `json:"receive_id"`
+	Currency           currency.Code  `json:"currency"`
+	Amount             currency.Money `json:"amount"`
+	Deposit            currency.Money `json:"deposit,omitempty"`
+	SettlementCurrency currency.Code  `json:"settlement_currency"`
+	SettlementAmount   currency.Money `json:"settlement_amount"`
+	ExchangeRate       string         `json:"exchange_rate"`
 }
 
 type TransactionResponse struct {
 	Message string            `json:"message"`
 	Data    []TransactionData `json:"data"`
 }
+
+type PaymentResult struct {
+	Transaction Transactions
+	Booking     Bookings
+	Product     Products
+	Lessor      Lessors
+	Renter      Users
+	LessorUser  Users
+	Deposit     *Deposits
+}
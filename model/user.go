@@ -1,6 +1,7 @@
 package model
 
 import (
+	"rent-video-game/currency"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,15 +10,17 @@ import (
 )
 
 type Users struct {
-	UserID    uuid.UUID      `json:"user_id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	Name      string         `json:"name" gorm:"type:varchar(255); not null"`
-	Email     string         `json:"email" gorm:"type:varchar(255); not null; unique"`
-	Password  string         `json:"password" gorm:"type:varchar(255); not null"`
-	Address   string         `json:"address" gorm:"type:varchar(255); not null"`
-	Amount    float64        `json:"amount" gorm:"type:decimal(10,2); not null; default: 0"`
-	CreatedAt time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
-	UpdatedAt time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
+	UserID          uuid.UUID      `json:"user_id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	Name            string         `json:"name" gorm:"type:varchar(255); not null"`
+	Email           string         `json:"email" gorm:"type:varchar(255); not null; unique"`
+	Password        string         `json:"password" gorm:"type:varchar(255); not null"`
+	Address         string         `json:"address" gorm:"type:varchar(255); not null"`
+	Amount          currency.Money `json:"amount" gorm:"type:numeric(14,2); not null; default:0"`
+	Currency        currency.Code  `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at" gorm:"type:timestamp"`
+	CreatedAt       time.Time      `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt       time.Time      `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at" gorm:"type:timestamp"`
 }
 
 func (u *Users) CompareHashAndPassword(password string) error {
@@ -29,6 +32,7 @@ type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 	Address  string `json:"address" validate:"required"`
+	Currency string `json:"currency"`
 }
 
 type RegisterResponse struct {
@@ -46,22 +50,23 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Message string `json:"message"`
-	Data    struct {
-		Token string `json:"token"`
-	} `json:"data"`
+	Message string    `json:"message"`
+	Data    TokenPair `json:"data"`
 }
 
 type TopupRequest struct {
-	Amount float64 `json:"amount" validate:"required"`
+	Amount currency.Money `json:"amount" validate:"required"`
 }
 
 type TopupResponse struct {
 	Message string `json:"message"`
 	Data    struct {
-		UserID      uuid.UUID `json:"user_id"`
-		TopupAmount float64   `json:"topup_amount"`
-		NewBalance  float64   `json:"new_balance"`
-		PaymentID   string    `json:"payment_id"`
+		UserID       uuid.UUID      `json:"user_id"`
+		Currency     currency.Code  `json:"currency"`
+		TopupAmount  currency.Money `json:"topup_amount"`
+		Balance      currency.Money `json:"balance"`
+		PaymentID    string         `json:"payment_id"`
+		ClientSecret string         `json:"client_secret"`
+		Status       string         `json:"status"`
 	} `json:"data"`
 }
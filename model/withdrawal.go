@@ -0,0 +1,66 @@
+package model
+
+import (
+	"rent-video-game/currency"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WithdrawalStatus string
+
+const (
+	WithdrawalPending  WithdrawalStatus = "PENDING"
+	WithdrawalApproved WithdrawalStatus = "APPROVED"
+	WithdrawalPaid     WithdrawalStatus = "PAID"
+	WithdrawalFailed   WithdrawalStatus = "FAILED"
+)
+
+var withdrawalTransitions = map[WithdrawalStatus][]WithdrawalStatus{
+	WithdrawalPending:  {WithdrawalApproved, WithdrawalFailed},
+	WithdrawalApproved: {WithdrawalPaid, WithdrawalFailed},
+}
+
+func (s WithdrawalStatus) CanTransitionTo(next WithdrawalStatus) bool {
+	for _, allowed := range withdrawalTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Withdrawals move money out of a wallet. The amount is held out of the
+// wallet when requested, paid out once an admin marks the withdrawal paid, and
+// returned to the wallet if it fails.
+type Withdrawals struct {
+	WithdrawalID  int              `json:"withdrawal_id" gorm:"type:serial;primaryKey"`
+	UserID        uuid.UUID        `json:"user_id" gorm:"type:uuid; not null; index"`
+	Currency      currency.Code    `json:"currency" gorm:"type:varchar(3); not null; default:USD"`
+	Amount        currency.Money   `json:"amount" gorm:"type:numeric(14,2); not null"`
+	Destination   string           `json:"destination" gorm:"type:varchar(255); not null"`
+	Status        WithdrawalStatus `json:"status" gorm:"type:varchar(50); not null"`
+	FailureReason string           `json:"failure_reason,omitempty" gorm:"type:text"`
+	ProcessedAt   *time.Time       `json:"processed_at,omitempty" gorm:"type:timestamp"`
+	CreatedAt     time.Time        `json:"created_at" gorm:"type:timestamp; not null; autoCreateTime"`
+	UpdatedAt     time.Time        `json:"updated_at" gorm:"type:timestamp; not null; autoUpdateTime"`
+	Users         Users            `json:"-" gorm:"foreignKey:UserID;references:UserID"`
+}
+
+type WithdrawalRequest struct {
+	Amount      currency.Money `json:"amount" validate:"required"`
+	Destination string         `json:"destination" validate:"required"`
+}
+
+type WithdrawalDecisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type WithdrawalResponse struct {
+	Message string        `json:"message"`
+	Data    []Withdrawals `json:"data"`
+}
+
+type TopupRefundRequest struct {
+	Amount currency.Money `json:"amount"`
+}
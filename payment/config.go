@@ -0,0 +1,33 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// NewGatewayFromEnv picks the gateway named by PAYMENT_GATEWAY. Stripe is the
+// default; "fake" runs without network for tests and local development. An
+// empty key or webhook secret is an error, since webhooks signed with an
+// empty secret can be forged by anyone.
+func NewGatewayFromEnv() (Gateway, error) {
+	switch name := os.Getenv("PAYMENT_GATEWAY"); name {
+	case "", "stripe":
+		secretKey, webhookSecret := os.Getenv("STRIPE_SECRET_KEY"), os.Getenv("STRIPE_WEBHOOK_SECRET")
+		if secretKey == "" {
+			return nil, errors.New("STRIPE_SECRET_KEY is required")
+		}
+		if webhookSecret == "" {
+			return nil, errors.New("STRIPE_WEBHOOK_SECRET is required")
+		}
+		return NewStripeGateway(secretKey, webhookSecret), nil
+	case "fake":
+		webhookSecret := os.Getenv("FAKE_WEBHOOK_SECRET")
+		if webhookSecret == "" {
+			return nil, errors.New("FAKE_WEBHOOK_SECRET is required")
+		}
+		return NewFake(webhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", name)
+	}
+}
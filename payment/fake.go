@@ -0,0 +1,197 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Fake is an in-memory Gateway. Confirming an intent settles it at once and
+// refunds always succeed; the resulting events are handed to subscribers in
+// process, before the call returns. ParseEvent accepts events signed with
+// Sign, so the webhook route can be exercised too.
+type Fake struct {
+	mu            sync.Mutex
+	webhookSecret string
+	sequence      int
+	intents       map[string]*Intent
+	refunded      map[string]int64
+	handlers      []EventHandler
+}
+
+func NewFake(webhookSecret string) *Fake {
+	return &Fake{
+		webhookSecret: webhookSecret,
+		intents:       map[string]*Intent{},
+		refunded:      map[string]int64{},
+	}
+}
+
+func (f *Fake) Subscribe(handler EventHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = append(f.handlers, handler)
+}
+
+func (f *Fake) CreateIntent(amount int64, currency string, metadata map[string]string) (*Intent, error) {
+	if amount <= 0 {
+		return nil, errors.New("amount must be greater than 0")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.nextID("pi_fake")
+	intent := &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret",
+		Amount:       amount,
+		Currency:     currency,
+		Status:       IntentRequiresConfirmation,
+		Metadata:     copyMetadata(metadata),
+	}
+	f.intents[id] = intent
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (f *Fake) ConfirmIntent(paymentID string) (*Intent, error) {
+	f.mu.Lock()
+	intent, ok := f.intents[paymentID]
+	if !ok {
+		f.mu.Unlock()
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status == IntentSucceeded {
+		copied := *intent
+		f.mu.Unlock()
+		return &copied, nil
+	}
+
+	intent.Status = IntentSucceeded
+	copied := *intent
+	event := &Event{
+		ID:        f.nextID("evt_fake"),
+		Type:      EventIntentSucceeded,
+		PaymentID: intent.ID,
+		Amount:    intent.Amount,
+		Currency:  intent.Currency,
+		Metadata:  copyMetadata(intent.Metadata),
+	}
+	f.mu.Unlock()
+
+	return &copied, f.emit(event)
+}
+
+// FailIntent declines the intent the way a card decline would.
+func (f *Fake) FailIntent(paymentID, message string) error {
+	f.mu.Lock()
+	intent, ok := f.intents[paymentID]
+	if !ok {
+		f.mu.Unlock()
+		return ErrIntentNotFound
+	}
+
+	intent.Status = IntentRequiresPaymentMethod
+	event := &Event{
+		ID:             f.nextID("evt_fake"),
+		Type:           EventIntentFailed,
+		PaymentID:      intent.ID,
+		Currency:       intent.Currency,
+		FailureMessage: message,
+		Metadata:       copyMetadata(intent.Metadata),
+	}
+	f.mu.Unlock()
+
+	return f.emit(event)
+}
+
+func (f *Fake) Refund(paymentID string, amount int64) (*Refund, error) {
+	f.mu.Lock()
+	intent, ok := f.intents[paymentID]
+	if !ok {
+		f.mu.Unlock()
+		return nil, ErrIntentNotFound
+	}
+	if intent.Status != IntentSucceeded || amount <= 0 || f.refunded[paymentID]+amount > intent.Amount {
+		f.mu.Unlock()
+		return nil, ErrInvalidRefund
+	}
+
+	f.refunded[paymentID] += amount
+	refund := &Refund{ID: f.nextID("re_fake"), PaymentID: paymentID, Amount: amount, Status: "succeeded"}
+	event := &Event{
+		ID:             f.nextID("evt_fake"),
+		Type:           EventRefunded,
+		PaymentID:      paymentID,
+		Amount:         intent.Amount,
+		AmountRefunded: f.refunded[paymentID],
+		Currency:       intent.Currency,
+		Metadata:       copyMetadata(intent.Metadata),
+	}
+	f.mu.Unlock()
+
+	return refund, f.emit(event)
+}
+
+func (f *Fake) GetIntent(paymentID string) (*Intent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[paymentID]
+	if !ok {
+		return nil, ErrIntentNotFound
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+// ParseEvent decodes an Event serialised as JSON and signed with Sign.
+func (f *Fake) ParseEvent(payload []byte, signature string) (*Event, error) {
+	if !hmac.Equal([]byte(signature), []byte(f.Sign(payload))) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (f *Fake) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.webhookSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) emit(event *Event) error {
+	f.mu.Lock()
+	handlers := append([]EventHandler(nil), f.handlers...)
+	f.mu.Unlock()
+
+	for _, handler := range handlers {
+		if err := handler(event); err != nil {
+			return fmt.Errorf("handle %s: %w", event.Type, err)
+		}
+	}
+	return nil
+}
+
+func (f *Fake) nextID(prefix string) string {
+	f.sequence++
+	return fmt.Sprintf("%s_%d", prefix, f.sequence)
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return copied
+}
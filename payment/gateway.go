@@ -0,0 +1,81 @@
+// Package payment hides the payment provider behind Gateway so top-ups and
+// refunds can run against Stripe in production and against Fake in tests and
+// local development.
+package payment
+
+import "errors"
+
+// Amounts are in the currency's minor unit, e.g. cents.
+
+type IntentStatus string
+
+const (
+	IntentRequiresPaymentMethod IntentStatus = "requires_payment_method"
+	IntentRequiresConfirmation  IntentStatus = "requires_confirmation"
+	IntentRequiresAction        IntentStatus = "requires_action"
+	IntentProcessing            IntentStatus = "processing"
+	IntentSucceeded             IntentStatus = "succeeded"
+	IntentCanceled              IntentStatus = "canceled"
+)
+
+type EventType string
+
+const (
+	EventIntentSucceeded EventType = "payment_intent.succeeded"
+	EventIntentFailed    EventType = "payment_intent.payment_failed"
+	EventRefunded        EventType = "charge.refunded"
+)
+
+// SignatureHeader carries the webhook signature. Fake signs its events the
+// same way so both gateways share one webhook route.
+const SignatureHeader = "Stripe-Signature"
+
+var (
+	ErrIntentNotFound = errors.New("payment intent not found")
+	ErrInvalidRefund  = errors.New("refund amount exceeds the captured amount")
+)
+
+type Intent struct {
+	ID           string
+	ClientSecret string
+	Amount       int64
+	Currency     string
+	Status       IntentStatus
+	Metadata     map[string]string
+}
+
+type Refund struct {
+	ID        string
+	PaymentID string
+	Amount    int64
+	Status    string
+}
+
+// Event is a provider notification about a payment. AmountRefunded is the
+// total refunded so far, not the amount of the latest refund.
+type Event struct {
+	ID             string
+	Type           EventType
+	PaymentID      string
+	Amount         int64
+	AmountRefunded int64
+	Currency       string
+	FailureMessage string
+	Metadata       map[string]string
+}
+
+type Gateway interface {
+	CreateIntent(amount int64, currency string, metadata map[string]string) (*Intent, error)
+	ConfirmIntent(paymentID string) (*Intent, error)
+	Refund(paymentID string, amount int64) (*Refund, error)
+	GetIntent(paymentID string) (*Intent, error)
+	ParseEvent(payload []byte, signature string) (*Event, error)
+}
+
+type EventHandler func(event *Event) error
+
+// EventSource is implemented by gateways that deliver events in process
+// instead of calling the webhook.
+type EventSource interface {
+	Subscribe(handler EventHandler)
+}
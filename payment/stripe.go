@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"encoding/json"
+
+	"github.com/stripe/stripe-go/v72"
+	"github.com/stripe/stripe-go/v72/client"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+type StripeGateway struct {
+	api           *client.API
+	webhookSecret string
+}
+
+func NewStripeGateway(secretKey, webhookSecret string) *StripeGateway {
+	return &StripeGateway{
+		api:           client.New(secretKey, nil),
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (g *StripeGateway) CreateIntent(amount int64, currency string, metadata map[string]string) (*Intent, error) {
+	params := &stripe.PaymentIntentParams{
+		Amount:   stripe.Int64(amount),
+		Currency: stripe.String(currency),
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
+			Enabled: stripe.Bool(true),
+		},
+	}
+	for key, value := range metadata {
+		params.AddMetadata(key, value)
+	}
+
+	pi, err := g.api.PaymentIntents.New(params)
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(pi), nil
+}
+
+func (g *StripeGateway) ConfirmIntent(paymentID string) (*Intent, error) {
+	pi, err := g.api.PaymentIntents.Confirm(paymentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(pi), nil
+}
+
+func (g *StripeGateway) Refund(paymentID string, amount int64) (*Refund, error) {
+	r, err := g.api.Refunds.New(&stripe.RefundParams{
+		PaymentIntent: stripe.String(paymentID),
+		Amount:        stripe.Int64(amount),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{ID: r.ID, PaymentID: paymentID, Amount: r.Amount, Status: string(r.Status)}, nil
+}
+
+func (g *StripeGateway) GetIntent(paymentID string) (*Intent, error) {
+	pi, err := g.api.PaymentIntents.Get(paymentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return stripeIntent(pi), nil
+}
+
+// ParseEvent checks the Stripe-Signature header against the endpoint secret
+// and decodes the event. Events signed more than five minutes ago are rejected
+// to stop replays. Event types the gateway does not map keep their Stripe type
+// and carry no payment data.
+func (g *StripeGateway) ParseEvent(payload []byte, signature string) (*Event, error) {
+	se, err := webhook.ConstructEvent(payload, signature, g.webhookSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &Event{ID: se.ID, Type: EventType(se.Type)}
+
+	switch event.Type {
+	case EventIntentSucceeded, EventIntentFailed:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(se.Data.Raw, &pi); err != nil {
+			return nil, err
+		}
+		event.PaymentID = pi.ID
+		event.Amount = pi.AmountReceived
+		event.Currency = string(pi.Currency)
+		event.Metadata = pi.Metadata
+		if pi.LastPaymentError != nil {
+			event.FailureMessage = pi.LastPaymentError.Msg
+		}
+	case EventRefunded:
+		var charge stripe.Charge
+		if err := json.Unmarshal(se.Data.Raw, &charge); err != nil {
+			return nil, err
+		}
+		if charge.PaymentIntent != nil {
+			event.PaymentID = charge.PaymentIntent.ID
+		}
+		event.Amount = charge.Amount
+		event.AmountRefunded = charge.AmountRefunded
+		event.Currency = string(charge.Currency)
+		event.Metadata = charge.Metadata
+	}
+
+	return event, nil
+}
+
+func stripeIntent(pi *stripe.PaymentIntent) *Intent {
+	return &Intent{
+		ID:           pi.ID,
+		ClientSecret: pi.ClientSecret,
+		Amount:       pi.Amount,
+		Currency:     string(pi.Currency),
+		Status:       IntentStatus(pi.Status),
+		Metadata:     pi.Metadata,
+	}
+}
@@ -0,0 +1,43 @@
+package tests
+
+import (
+	"rent-video-game/payment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGatewayFromEnvRequiresSecrets(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		err  string
+	}{
+		{"stripe without key", map[string]string{"PAYMENT_GATEWAY": "stripe", "STRIPE_WEBHOOK_SECRET": "whsec"}, "STRIPE_SECRET_KEY is required"},
+		{"stripe without webhook secret", map[string]string{"PAYMENT_GATEWAY": "", "STRIPE_SECRET_KEY": "sk_test"}, "STRIPE_WEBHOOK_SECRET is required"},
+		{"fake without webhook secret", map[string]string{"PAYMENT_GATEWAY": "fake"}, "FAKE_WEBHOOK_SECRET is required"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"PAYMENT_GATEWAY", "STRIPE_SECRET_KEY", "STRIPE_WEBHOOK_SECRET", "FAKE_WEBHOOK_SECRET"} {
+				t.Setenv(key, tc.env[key])
+			}
+
+			gateway, err := payment.NewGatewayFromEnv()
+
+			assert.Nil(t, gateway)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestNewGatewayFromEnvFake(t *testing.T) {
+	t.Setenv("PAYMENT_GATEWAY", "fake")
+	t.Setenv("FAKE_WEBHOOK_SECRET", webhookSecret)
+
+	gateway, err := payment.NewGatewayFromEnv()
+
+	assert.NoError(t, err)
+	assert.NotNil(t, gateway)
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"encoding/json"
+	"rent-video-game/payment"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeConfirmEmitsSucceededEvent(t *testing.T) {
+	fake := payment.NewFake(webhookSecret)
+
+	var events []*payment.Event
+	fake.Subscribe(func(event *payment.Event) error {
+		events = append(events, event)
+		return nil
+	})
+
+	intent, err := fake.CreateIntent(2500, "usd", map[string]string{"user_id": "user-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, payment.IntentRequiresConfirmation, intent.Status)
+
+	intent, err = fake.ConfirmIntent(intent.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, payment.IntentSucceeded, intent.Status)
+	assert.Len(t, events, 1)
+	assert.Equal(t, payment.EventIntentSucceeded, events[0].Type)
+	assert.Equal(t, int64(2500), events[0].Amount)
+	assert.Equal(t, "user-1", events[0].Metadata["user_id"])
+}
+
+func TestFakeRefundReportsRunningTotal(t *testing.T) {
+	fake := payment.NewFake(webhookSecret)
+
+	var refunded []int64
+	fake.Subscribe(func(event *payment.Event) error {
+		if event.Type == payment.EventRefunded {
+			refunded = append(refunded, event.AmountRefunded)
+		}
+		return nil
+	})
+
+	intent, _ := fake.CreateIntent(2500, "usd", nil)
+	_, _ = fake.ConfirmIntent(intent.ID)
+
+	_, err := fake.Refund(intent.ID, 1000)
+	assert.NoError(t, err)
+	_, err = fake.Refund(intent.ID, 500)
+	assert.NoError(t, err)
+	_, err = fake.Refund(intent.ID, 1001)
+	assert.ErrorIs(t, err, payment.ErrInvalidRefund)
+
+	assert.Equal(t, []int64{1000, 1500}, refunded)
+}
+
+func TestFakeParseEventChecksSignature(t *testing.T) {
+	fake := payment.NewFake(webhookSecret)
+
+	payload, _ := json.Marshal(payment.Event{ID: "evt_1", Type: payment.EventIntentSucceeded, PaymentID: "pi_1", Amount: 100})
+
+	event, err := fake.ParseEvent(payload, fake.Sign(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, "pi_1", event.PaymentID)
+
+	_, err = fake.ParseEvent(payload, "bad")
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"rent-video-game/payment"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v72/webhook"
+)
+
+const webhookSecret = "whsec_test_secret"
+
+// signFixture reads a recorded event and signs it the way stripe does.
+func signFixture(t *testing.T, name, secret string) ([]byte, string) {
+	payload, err := os.ReadFile("testdata/stripe/" + name + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	return payload, fmt.Sprintf("t=%d,v1=%x", now.Unix(), webhook.ComputeSignature(now, payload, secret))
+}
+
+func TestStripeParseEventRejectsBadSignature(t *testing.T) {
+	gateway := payment.NewStripeGateway("sk_test", webhookSecret)
+	payload, signature := signFixture(t, "payment_intent_succeeded", "whsec_other")
+
+	event, err := gateway.ParseEvent(payload, signature)
+
+	assert.Error(t, err)
+	assert.Nil(t, event)
+}
+
+func TestStripeParsePaymentIntentSucceeded(t *testing.T) {
+	gateway := payment.NewStripeGateway("sk_test", webhookSecret)
+	payload, signature := signFixture(t, "payment_intent_succeeded", webhookSecret)
+
+	event, err := gateway.ParseEvent(payload, signature)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_3PqTopupSucceeded", event.ID)
+	assert.Equal(t, payment.EventIntentSucceeded, event.Type)
+	assert.Equal(t, "pi_3PqTopup0001", event.PaymentID)
+	assert.Equal(t, int64(2500), event.Amount)
+	assert.Equal(t, "7d9f1c2e-4b6a-4f0e-9c55-1a2b3c4d5e6f", event.Metadata["user_id"])
+}
+
+func TestStripeParsePaymentIntentFailed(t *testing.T) {
+	gateway := payment.NewStripeGateway("sk_test", webhookSecret)
+	payload, signature := signFixture(t, "payment_intent_payment_failed", webhookSecret)
+
+	event, err := gateway.ParseEvent(payload, signature)
+
+	assert.NoError(t, err)
+	assert.Equal(t, payment.EventIntentFailed, event.Type)
+	assert.Equal(t, "Your card was declined.", event.FailureMessage)
+}
+
+func TestStripeParseChargeRefunded(t *testing.T) {
+	gateway := payment.NewStripeGateway("sk_test", webhookSecret)
+	payload, signature := signFixture(t, "charge_refunded", webhookSecret)
+
+	event, err := gateway.ParseEvent(payload, signature)
+
+	assert.NoError(t, err)
+	assert.Equal(t, payment.EventRefunded, event.Type)
+	assert.Equal(t, "pi_3PqTopup0001", event.PaymentID)
+	assert.Equal(t, int64(1000), event.AmountRefunded)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"errors"
+	"rent-video-game/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrAccountTokenInvalid = errors.New("token is invalid, expired or already used")
+
+type IAccountTokenRepository interface {
+	CreateAccountToken(token *model.AccountTokens) error
+	VerifyEmail(jti string, userID uuid.UUID, now time.Time) error
+	ResetPassword(jti string, userID uuid.UUID, passwordHash string, now time.Time) error
+}
+
+type AccountTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountTokenRepository(db *gorm.DB) *AccountTokenRepository {
+	return &AccountTokenRepository{db}
+}
+
+// CreateAccountToken stores a new link token and spends the unused ones of
+// the same purpose, so only the latest link mailed to the user works.
+func (r *AccountTokenRepository) CreateAccountToken(token *model.AccountTokens) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.AccountTokens{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *AccountTokenRepository) VerifyEmail(jti string, userID uuid.UUID, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := useAccountToken(tx, jti, userID, model.PurposeVerifyEmail, now); err != nil {
+			return err
+		}
+		return markEmailVerified(tx, userID, now)
+	})
+}
+
+// ResetPassword sets the new password and ends every login of the user. The
+// reset link reached the user's inbox, so it verifies the email as well.
+func (r *AccountTokenRepository) ResetPassword(jti string, userID uuid.UUID, passwordHash string, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := useAccountToken(tx, jti, userID, model.PurposeResetPassword, now); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.Users{}).Where("user_id = ?", userID).
+			Update("password", passwordHash).Error; err != nil {
+			return err
+		}
+
+		if err := markEmailVerified(tx, userID, now); err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, userID, now)
+	})
+}
+
+// useAccountToken spends the token. The conditional update lets only one of
+// two concurrent requests with the same link succeed.
+func useAccountToken(tx *gorm.DB, jti string, userID uuid.UUID, purpose model.AccountTokenPurpose, now time.Time) error {
+	result := tx.Model(&model.AccountTokens{}).
+		Where("jti = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", jti, userID, purpose, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccountTokenInvalid
+	}
+	return nil
+}
+
+func markEmailVerified(tx *gorm.DB, userID uuid.UUID, now time.Time) error {
+	return tx.Model(&model.Users{}).
+		Where("user_id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", now).Error
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"encoding/json"
+	"rent-video-game/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IAuditLogRepository interface {
+	GetAllAuditLog(entityType string, entityID int) ([]model.AuditLogs, error)
+}
+
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db}
+}
+
+// GetAllAuditLog lists the newest entries first. An empty entity type lists
+// every entry and an entity ID of 0 every entity of that type.
+func (r *AuditLogRepository) GetAllAuditLog(entityType string, entityID int) ([]model.AuditLogs, error) {
+	query := r.db.Order("audit_log_id DESC")
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID > 0 {
+		query = query.Where("entity_id = ?", entityID)
+	}
+
+	var logs []model.AuditLogs
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// writeAuditLog records a change inside tx so the entry commits or rolls back
+// with the change itself.
+func writeAuditLog(tx *gorm.DB, actorID uuid.UUID, action model.AuditAction, entityType string, entityID int, changes interface{}) error {
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&model.AuditLogs{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    string(body),
+	}).Error
+}
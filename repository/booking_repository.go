@@ -2,17 +2,42 @@ package repository
 
 import (
 	"errors"
+	"fmt"
+	"rent-video-game/currency"
 	"rent-video-game/model"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// blockingEndDate is the last day a booking holds its unit. A unit that is out
+// with a renter is not back before today, and an overdue one has no known
+// return date at all.
+const blockingEndDate = `CASE bookings.status
+	WHEN 'OVERDUE' THEN 'infinity'::date
+	WHEN 'ACTIVE' THEN GREATEST(bookings.end_date, CURRENT_DATE)
+	ELSE bookings.end_date END`
+
+var (
+	ErrNoUnitAvailable   = errors.New("no unit is available for the requested dates")
+	ErrInvalidTransition = errors.New("booking status transition is not allowed")
 )
 
 type IBookingRepository interface {
 	CreateBooking(booking *model.Bookings) (*model.Bookings, error)
 	GetBookingByID(bookingID int, userID uuid.UUID) (*model.Bookings, error)
 	GetAllBookingByUser(userID uuid.UUID) ([]model.Bookings, error)
-	UpdateBooking(bookingID int, status model.BookingStatus, booking *model.Bookings) (*model.Bookings, error)
+	UpdateBooking(bookingID int, status model.BookingStatus, booking *model.Bookings, changedBy uuid.UUID) (*model.Bookings, error)
+	ReturnBooking(bookingID int, changedBy uuid.UUID, reason string, capture currency.Money, damageNote string) (*model.Bookings, error)
+	GetBookingHistory(bookingID int) ([]model.BookingStatusHistories, error)
+
+	GetBookingByLessor(bookingID, lessorID int) (*model.Bookings, error)
+	GetAllBookingByLessor(lessorID int) ([]model.Bookings, error)
+
+	ExpireUnpaidBookings(cutoff time.Time, reason string) ([]model.Bookings, error)
+	MarkOverdueBookings(reason string) ([]model.Bookings, error)
 
 	IsUserProductOwner(userID uuid.UUID, productID int) (bool, error)
 	GetProductByID(productID int) (*model.Products, error)
@@ -26,8 +51,27 @@ func NewBookingRepository(db *gorm.DB) *BookingRepository {
 	return &BookingRepository{db}
 }
 
+// CreateBooking assigns the first unit of the product that is free for the whole
+// booking window. The product row is locked so concurrent requests for the same
+// product cannot both take the last free unit.
 func (r *BookingRepository) CreateBooking(booking *model.Bookings) (*model.Bookings, error) {
-	if err := r.db.Create(booking).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var product model.Products
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id = ? AND (deleted_at IS NULL OR deleted_at = ?)", booking.ProductID, "0001-01-01 00:00:00").
+			First(&product).Error; err != nil {
+			return err
+		}
+
+		unit, err := findFreeUnit(tx, booking.ProductID, booking.StartDate, booking.EndDate)
+		if err != nil {
+			return err
+		}
+
+		booking.UnitID = &unit.UnitID
+		return tx.Create(booking).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return booking, nil
@@ -35,7 +79,7 @@ func (r *BookingRepository) CreateBooking(booking *model.Bookings) (*model.Booki
 
 func (r *BookingRepository) GetBookingByID(bookingID int, userID uuid.UUID) (*model.Bookings, error) {
 	var booking model.Bookings
-	if err := r.db.Where("booking_id = ? AND user_id = ?", bookingID, userID).First(&booking).Error; err != nil {
+	if err := r.db.Where("booking_id = ? AND user_id = ?", bookingID, userID).Preload("Products").First(&booking).Error; err != nil {
 		return nil, err
 	}
 	return &booking, nil
@@ -49,27 +93,148 @@ func (r *BookingRepository) GetAllBookingByUser(userID uuid.UUID) ([]model.Booki
 	return bookings, nil
 }
 
-func (r *BookingRepository) UpdateBooking(bookingID int, status model.BookingStatus, booking *model.Bookings) (*model.Bookings, error) {
+func (r *BookingRepository) UpdateBooking(bookingID int, status model.BookingStatus, booking *model.Bookings, changedBy uuid.UUID) (*model.Bookings, error) {
+	if status == model.Returned {
+		return r.ReturnBooking(bookingID, changedBy, booking.StatusReason, 0, "")
+	}
+
 	var b model.Bookings
-	err := r.db.Where("booking_id = ?", bookingID).Preload("Products").First(&b).Error
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("booking_id = ?", bookingID).First(&b).Error; err != nil {
+			return err
+		}
+		return transitionBooking(tx, &b, status, changedBy, booking.StatusReason)
+	})
 	if err != nil {
-		return &b, err
+		return nil, err
 	}
 
-	if b.Status == model.Approved {
-		return nil, errors.New("cannot update an already approved booking")
+	if err := r.db.Where("booking_id = ?", bookingID).Preload("Products").Preload("Users").First(&b).Error; err != nil {
+		return nil, err
 	}
+	return &b, nil
+}
 
-	if err := r.db.Model(&model.Bookings{}).Where("booking_id = ?", bookingID).Update("status", status).Error; err != nil {
+// ReturnBooking marks the booking as returned and settles its deposit in the
+// same transaction: late fees up to today and capture go to the lessor, the
+// rest is released to the renter.
+func (r *BookingRepository) ReturnBooking(bookingID int, changedBy uuid.UUID, reason string, capture currency.Money, damageNote string) (*model.Bookings, error) {
+	var b model.Bookings
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("booking_id = ?", bookingID).First(&b).Error; err != nil {
+			return err
+		}
+
+		if err := transitionBooking(tx, &b, model.Returned, changedBy, reason); err != nil {
+			return err
+		}
+
+		_, err := settleDeposit(tx, &b, capture, damageNote, time.Now())
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if err := r.db.Where("booking_id = ?", bookingID).Preload("Products").First(&b).Error; err != nil {
+	if err := r.db.Where("booking_id = ?", bookingID).
+		Preload("Products").Preload("Users").Preload("Deposits").
+		First(&b).Error; err != nil {
 		return nil, err
 	}
 	return &b, nil
 }
 
+func (r *BookingRepository) GetBookingHistory(bookingID int) ([]model.BookingStatusHistories, error) {
+	var history []model.BookingStatusHistories
+	if err := r.db.Where("booking_id = ?", bookingID).Order("history_id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *BookingRepository) GetBookingByLessor(bookingID, lessorID int) (*model.Bookings, error) {
+	var booking model.Bookings
+	if err := r.db.Joins("JOIN products ON products.product_id = bookings.product_id").
+		Where("bookings.booking_id = ? AND products.lessor_id = ?", bookingID, lessorID).
+		Preload("Products").Preload("Users").
+		First(&booking).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+func (r *BookingRepository) GetAllBookingByLessor(lessorID int) ([]model.Bookings, error) {
+	var bookings []model.Bookings
+	if err := r.db.Joins("JOIN products ON products.product_id = bookings.product_id").
+		Where("products.lessor_id = ?", lessorID).
+		Preload("Products").Preload("Users").
+		Order("bookings.start_date").
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+// ExpireUnpaidBookings cancels pending bookings created before cutoff and
+// approved bookings approved before it, so the renter always gets the full
+// payment window after approval. Bookings approved before the status history
+// existed are timed from their last update. Rows locked by a concurrent
+// payment are skipped and picked up by a later run if the payment does not go
+// through.
+func (r *BookingRepository) ExpireUnpaidBookings(cutoff time.Time, reason string) ([]model.Bookings, error) {
+	return r.transitionWhere(model.Cancelled, reason, `(status = ? AND created_at < ?) OR (status = ? AND
+		COALESCE((SELECT MAX(booking_status_histories.created_at) FROM booking_status_histories
+			WHERE booking_status_histories.booking_id = bookings.booking_id
+				AND booking_status_histories.to_status = ?), bookings.updated_at) < ?)`,
+		model.Pending, cutoff, model.Approved, model.Approved, cutoff)
+}
+
+// MarkOverdueBookings flags active bookings whose end date has passed.
+func (r *BookingRepository) MarkOverdueBookings(reason string) ([]model.Bookings, error) {
+	return r.transitionWhere(model.Overdue, reason, "status = ? AND end_date < CURRENT_DATE", model.Active)
+}
+
+func (r *BookingRepository) transitionWhere(status model.BookingStatus, reason string, query string, args ...interface{}) ([]model.Bookings, error) {
+	var bookings []model.Bookings
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where(query, args...).
+			Order("booking_id").
+			Find(&bookings).Error; err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			if err := transitionBooking(tx, &bookings[i], status, uuid.Nil, reason); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bookings) == 0 {
+		return bookings, nil
+	}
+
+	ids := make([]int, 0, len(bookings))
+	for _, booking := range bookings {
+		ids = append(ids, booking.BookingID)
+	}
+
+	if err := r.db.Where("booking_id IN ?", ids).
+		Preload("Users").Preload("Products.Lessors.Users").
+		Order("booking_id").
+		Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
 func (r *BookingRepository) IsUserProductOwner(userID uuid.UUID, productID int) (bool, error) {
 	var count int64
 	err := r.db.Table("products").
@@ -91,3 +256,56 @@ func (r *BookingRepository) GetProductByID(productID int) (*model.Products, erro
 	}
 	return &product, nil
 }
+
+// findFreeUnit returns the lowest numbered active unit of the product that has no
+// blocking booking overlapping startDate..endDate (both inclusive).
+func findFreeUnit(tx *gorm.DB, productID int, startDate, endDate string) (*model.ProductUnits, error) {
+	var unit model.ProductUnits
+	err := tx.Where("product_id = ?", productID).
+		Where("NOT EXISTS (?)", tx.Model(&model.Bookings{}).Select("1").
+			Where("bookings.unit_id = product_units.unit_id AND bookings.status IN ? AND bookings.start_date <= ? AND "+blockingEndDate+" >= ?",
+				model.BlockingBookingStatuses, endDate, startDate)).
+		Order("unit_id").
+		First(&unit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoUnitAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+// transitionBooking moves a locked booking to status when the booking state
+// machine allows it and records the change in the status history. Every status
+// change goes through here. A nil changedBy marks a change made by the system.
+func transitionBooking(tx *gorm.DB, booking *model.Bookings, status model.BookingStatus, changedBy uuid.UUID, reason string) error {
+	if !booking.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, booking.Status, status)
+	}
+
+	history := model.BookingStatusHistories{
+		BookingID:  booking.BookingID,
+		FromStatus: booking.Status,
+		ToStatus:   status,
+		Reason:     reason,
+	}
+	if changedBy != uuid.Nil {
+		history.ChangedBy = &changedBy
+	}
+
+	if err := tx.Model(booking).Updates(map[string]interface{}{
+		"status":        status,
+		"status_reason": reason,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+
+	booking.Status = status
+	booking.StatusReason = reason
+	return nil
+}
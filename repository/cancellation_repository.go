@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"rent-video-game/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ICancellationRepository interface {
+	GetPolicyByLessor(lessorID int) (*model.CancellationPolicies, error)
+	SavePolicy(policy *model.CancellationPolicies) (*model.CancellationPolicies, error)
+	CancelBooking(bookingID int, renterID uuid.UUID, reason string, refundPercent float64) (*model.CancellationResult, error)
+}
+
+type CancellationRepository struct {
+	db *gorm.DB
+}
+
+func NewCancellationRepository(db *gorm.DB) *CancellationRepository {
+	return &CancellationRepository{db}
+}
+
+func (r *CancellationRepository) GetPolicyByLessor(lessorID int) (*model.CancellationPolicies, error) {
+	var policy model.CancellationPolicies
+	if err := r.db.Where("lessor_id = ?", lessorID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *CancellationRepository) SavePolicy(policy *model.CancellationPolicies) (*model.CancellationPolicies, error) {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "lessor_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"full_refund_days", "partial_refund_percent", "updated_at"}),
+	}).Create(policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetPolicyByLessor(policy.LessorID)
+}
+
+// CancelBooking cancels the renter's booking and, when it was paid, refunds
+// refundPercent of the payment from the lessor's wallet and releases the
+// whole deposit. The refund is recorded as a reversal of the original payment.
+// The state machine rejects cancelling a booking that was already picked up.
+func (r *CancellationRepository) CancelBooking(bookingID int, renterID uuid.UUID, reason string, refundPercent float64) (*model.CancellationResult, error) {
+	var result model.CancellationResult
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("booking_id = ? AND user_id = ?", bookingID, renterID).
+			First(&result.Booking).Error; err != nil {
+			return err
+		}
+
+		wasPaid := result.Booking.Status == model.Paid
+
+		if err := transitionBooking(tx, &result.Booking, model.Cancelled, renterID, reason); err != nil {
+			return err
+		}
+
+		if !wasPaid {
+			return nil
+		}
+
+		var payment model.Transactions
+		if err := tx.Where("booking_id = ? AND type = ?", bookingID, model.PaymentTransaction).
+			First(&payment).Error; err != nil {
+			return err
+		}
+
+		if refundPercent > 0 {
+			if err := refundPayment(tx, &payment, refundPercent, &result); err != nil {
+				return err
+			}
+		}
+
+		deposit, err := settleDeposit(tx, &result.Booking, 0, "", time.Now())
+		if err != nil {
+			return err
+		}
+		result.Deposit = deposit
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Where("booking_id = ?", bookingID).
+		Preload("Users").Preload("Products.Lessors.Users").
+		First(&result.Booking).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// refundPayment gives refundPercent of a payment back. Both sides of the
+// original payment are scaled, so the conversion reuses the payment's rate.
+func refundPayment(tx *gorm.DB, payment *model.Transactions, refundPercent float64, result *model.CancellationResult) error {
+	if refundPercent > 100 {
+		return errors.New("refund exceeds the original payment")
+	}
+
+	// policies keep two decimals, so the share is exact in hundredths of a percent
+	share := big.NewRat(int64(math.Round(refundPercent*100)), 100*100)
+
+	result.Reversal = &model.Transactions{
+		BookingID:          payment.BookingID,
+		UserID:             payment.UserID,
+		LessorID:           payment.LessorID,
+		Type:               model.ReversalTransaction,
+		ReversalOfID:       &payment.TransactionID,
+		Currency:           payment.Currency,
+		Amount:             payment.Amount.Mul(share),
+		SettlementCurrency: payment.SettlementCurrency,
+		SettlementAmount:   payment.SettlementAmount.Mul(share),
+		ExchangeRate:       payment.ExchangeRate,
+	}
+	if result.Reversal.Amount <= 0 {
+		result.Reversal = nil
+		return nil
+	}
+	if err := tx.Create(result.Reversal).Error; err != nil {
+		return err
+	}
+
+	lessorWallet, err := lessorWalletAccount(tx, payment.LessorID)
+	if err != nil {
+		return err
+	}
+
+	renterWallet, err := walletAccount(tx, payment.UserID)
+	if err != nil {
+		return err
+	}
+
+	reference := fmt.Sprintf("transaction:%d", result.Reversal.TransactionID)
+	description := fmt.Sprintf("refund for cancelled booking %d", payment.BookingID)
+	return exchange(tx, model.RefundEntry, reference, description, lessorWallet, renterWallet, result.Reversal.SettlementAmount, result.Reversal.Amount)
+}
@@ -1,14 +1,30 @@
 package repository
 
 import (
+	"errors"
 	"rent-video-game/model"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const consoleEntity = "console"
+
+var (
+	ErrConsoleNameTaken = errors.New("a console with this name already exists")
+	ErrConsoleInUse     = errors.New("console is still used by active products")
+	ErrUnknownConsole   = errors.New("console does not exist")
 )
 
 type IConsoleRepository interface {
 	GetAllConsole() ([]model.Consoles, error)
 	GetConsoleID(consoleID int) (*model.Consoles, error)
+
+	CreateConsole(console *model.Consoles, actorID uuid.UUID) (*model.Consoles, error)
+	RenameConsole(consoleID int, name string, actorID uuid.UUID) (*model.Consoles, error)
+	MergeConsole(sourceID, targetID int, actorID uuid.UUID) (*model.ConsoleMergeResult, error)
+	DeleteConsole(consoleID int, actorID uuid.UUID) (*model.Consoles, error)
 }
 
 type ConsoleRepository struct {
@@ -34,3 +50,165 @@ func (r *ConsoleRepository) GetConsoleID(consoleID int) (*model.Consoles, error)
 	}
 	return &console, nil
 }
+
+func (r *ConsoleRepository) CreateConsole(console *model.Consoles, actorID uuid.UUID) (*model.Consoles, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := consoleNameFree(tx, console.Name, 0); err != nil {
+			return err
+		}
+
+		if err := tx.Create(console).Error; err != nil {
+			return err
+		}
+
+		return writeAuditLog(tx, actorID, model.AuditConsoleCreate, consoleEntity, console.ConsoleID, map[string]string{
+			"name": console.Name,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return console, nil
+}
+
+func (r *ConsoleRepository) RenameConsole(consoleID int, name string, actorID uuid.UUID) (*model.Consoles, error) {
+	var console model.Consoles
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockConsole(tx, consoleID, &console); err != nil {
+			return err
+		}
+
+		if err := consoleNameFree(tx, name, consoleID); err != nil {
+			return err
+		}
+
+		oldName := console.Name
+		if err := tx.Model(&console).Update("name", name).Error; err != nil {
+			return err
+		}
+
+		return writeAuditLog(tx, actorID, model.AuditConsoleRename, consoleEntity, consoleID, map[string]string{
+			"from": oldName,
+			"to":   name,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &console, nil
+}
+
+// MergeConsole moves every product of the source console onto the target and
+// soft-deletes the source, for duplicates such as "PS5" and "PlayStation 5".
+func (r *ConsoleRepository) MergeConsole(sourceID, targetID int, actorID uuid.UUID) (*model.ConsoleMergeResult, error) {
+	result := model.ConsoleMergeResult{MergedID: sourceID}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// lock in id order so two opposite merges cannot deadlock
+		first, second := sourceID, targetID
+		if first > second {
+			first, second = second, first
+		}
+
+		var source model.Consoles
+		consoles := map[int]*model.Consoles{sourceID: &source, targetID: &result.Console}
+		for _, id := range []int{first, second} {
+			if err := lockConsole(tx, id, consoles[id]); err != nil {
+				return err
+			}
+		}
+
+		update := tx.Model(&model.Products{}).Unscoped().
+			Where("console_id = ?", sourceID).
+			Update("console_id", targetID)
+		if update.Error != nil {
+			return update.Error
+		}
+		result.MovedProducts = update.RowsAffected
+
+		if err := tx.Delete(&source).Error; err != nil {
+			return err
+		}
+
+		return writeAuditLog(tx, actorID, model.AuditConsoleMerge, consoleEntity, targetID, map[string]interface{}{
+			"merged_console_id":   sourceID,
+			"merged_console_name": source.Name,
+			"moved_products":      result.MovedProducts,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeleteConsole soft-deletes a console that no active product uses. Products
+// already deleted keep pointing at it.
+func (r *ConsoleRepository) DeleteConsole(consoleID int, actorID uuid.UUID) (*model.Consoles, error) {
+	var console model.Consoles
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockConsole(tx, consoleID, &console); err != nil {
+			return err
+		}
+
+		var active int64
+		if err := tx.Model(&model.Products{}).Unscoped().
+			Where("console_id = ? AND (deleted_at IS NULL OR deleted_at = ?)", consoleID, "0001-01-01 00:00:00").
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrConsoleInUse
+		}
+
+		if err := tx.Delete(&console).Error; err != nil {
+			return err
+		}
+
+		return writeAuditLog(tx, actorID, model.AuditConsoleDelete, consoleEntity, consoleID, map[string]string{
+			"name": console.Name,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &console, nil
+}
+
+// lockConsole loads an active console FOR UPDATE, which waits for products
+// being saved against it; see useConsole.
+func lockConsole(tx *gorm.DB, consoleID int, console *model.Consoles) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("console_id = ?", consoleID).
+		First(console).Error
+}
+
+// useConsole checks that a product may point at the console. The share lock
+// keeps the console from being deleted or merged away until tx commits.
+func useConsole(tx *gorm.DB, consoleID int) error {
+	var console model.Consoles
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("console_id = ?", consoleID).
+		First(&console).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUnknownConsole
+	}
+	return err
+}
+
+// consoleNameFree checks that no other active console has the name, ignoring
+// case. The unique index on LOWER(name) backs this up under concurrency.
+func consoleNameFree(tx *gorm.DB, name string, exceptID int) error {
+	var count int64
+	if err := tx.Model(&model.Consoles{}).
+		Where("LOWER(name) = LOWER(?) AND console_id <> ?", name, exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrConsoleNameTaken
+	}
+	return nil
+}
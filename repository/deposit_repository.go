@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrCaptureExceedsDeposit = errors.New("capture amount exceeds the remaining deposit")
+
+type IDepositRepository interface {
+	GetDepositByBooking(bookingID int) (*model.Deposits, error)
+	AccrueLateFees(asOf time.Time) ([]model.Deposits, error)
+}
+
+type DepositRepository struct {
+	db *gorm.DB
+}
+
+func NewDepositRepository(db *gorm.DB) *DepositRepository {
+	return &DepositRepository{db}
+}
+
+func (r *DepositRepository) GetDepositByBooking(bookingID int) (*model.Deposits, error) {
+	var deposit model.Deposits
+	if err := r.db.Where("booking_id = ?", bookingID).First(&deposit).Error; err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+// AccrueLateFees charges every overdue booking the late fee for each day past
+// its end date that has not been charged yet. Fees are paid to the lessor out
+// of the held deposit and stop once the deposit is used up.
+func (r *DepositRepository) AccrueLateFees(asOf time.Time) ([]model.Deposits, error) {
+	var charged []model.Deposits
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var bookings []model.Bookings
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ?", model.Overdue).
+			Find(&bookings).Error; err != nil {
+			return err
+		}
+
+		for i := range bookings {
+			deposit, fee, err := accrueLateFees(tx, &bookings[i], asOf)
+			if err != nil {
+				return err
+			}
+			if fee > 0 {
+				charged = append(charged, *deposit)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return charged, nil
+}
+
+// holdDeposit moves the product's deposit, already converted into the booking
+// currency, from the renter's wallet into escrow. Products without a deposit
+// hold nothing and return a nil deposit.
+func holdDeposit(tx *gorm.DB, booking *model.Bookings, product *model.Products, amount currency.Money, renterWallet *model.LedgerAccounts) (*model.Deposits, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	escrow, err := systemAccount(tx, model.EscrowAccount, renterWallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	deposit := &model.Deposits{
+		BookingID: booking.BookingID,
+		UserID:    booking.UserID,
+		LessorID:  product.LessorID,
+		Currency:  renterWallet.Currency,
+		Amount:    amount,
+		Status:    model.DepositHeld,
+	}
+	if err := tx.Create(deposit).Error; err != nil {
+		return nil, err
+	}
+
+	reference := fmt.Sprintf("deposit:%d", deposit.DepositID)
+	description := fmt.Sprintf("security deposit for booking %d", booking.BookingID)
+	if err := transfer(tx, model.DepositHoldEntry, reference, description, renterWallet, escrow, deposit.Amount); err != nil {
+		return nil, err
+	}
+
+	return deposit, nil
+}
+
+// settleDeposit closes the deposit of a booking that is leaving the rental:
+// outstanding late fees are charged first, then capture goes to the lessor and
+// the rest back to the renter. Bookings paid without a deposit settle to nil.
+func settleDeposit(tx *gorm.DB, booking *model.Bookings, capture currency.Money, damageNote string, asOf time.Time) (*model.Deposits, error) {
+	deposit, _, err := accrueLateFees(tx, booking, asOf)
+	if err != nil {
+		return nil, err
+	}
+	if deposit == nil {
+		if capture > 0 {
+			return nil, ErrCaptureExceedsDeposit
+		}
+		return nil, nil
+	}
+
+	remaining := deposit.Remaining()
+	if capture > remaining {
+		return nil, ErrCaptureExceedsDeposit
+	}
+
+	escrow, err := systemAccount(tx, model.EscrowAccount, deposit.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	reference := fmt.Sprintf("deposit:%d", deposit.DepositID)
+
+	if capture > 0 {
+		lessorWallet, err := lessorWalletAccount(tx, deposit.LessorID)
+		if err != nil {
+			return nil, err
+		}
+		settlement, err := settlementAmount(booking, capture, lessorWallet.Currency)
+		if err != nil {
+			return nil, err
+		}
+		description := fmt.Sprintf("deposit captured for booking %d", booking.BookingID)
+		if damageNote != "" {
+			description += ": " + damageNote
+		}
+		if err := exchange(tx, model.DepositCaptureEntry, reference, description, escrow, lessorWallet, capture, settlement); err != nil {
+			return nil, err
+		}
+		deposit.Captured += capture
+		deposit.DamageNote = damageNote
+	}
+
+	if release := remaining - capture; release > 0 {
+		renterWallet, err := walletAccount(tx, deposit.UserID)
+		if err != nil {
+			return nil, err
+		}
+		description := fmt.Sprintf("deposit released for booking %d", booking.BookingID)
+		if err := transfer(tx, model.DepositReleaseEntry, reference, description, escrow, renterWallet, release); err != nil {
+			return nil, err
+		}
+		deposit.Released += release
+	}
+
+	switch {
+	case deposit.Captured == 0 && deposit.LateFees == 0:
+		deposit.Status = model.DepositReleased
+	case deposit.Released == 0:
+		deposit.Status = model.DepositCaptured
+	default:
+		deposit.Status = model.DepositPartiallyCaptured
+	}
+
+	if err := tx.Save(deposit).Error; err != nil {
+		return nil, err
+	}
+	return deposit, nil
+}
+
+// accrueLateFees charges the days between the booking's end date and asOf that
+// have not been charged yet and returns the locked deposit and the fee booked.
+func accrueLateFees(tx *gorm.DB, booking *model.Bookings, asOf time.Time) (*model.Deposits, currency.Money, error) {
+	var deposit model.Deposits
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("booking_id = ? AND status = ?", booking.BookingID, model.DepositHeld).
+		First(&deposit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	endDate, err := utils.ParseDate(booking.EndDate)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	today := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	lateDays := int(today.Sub(endDate).Hours() / 24)
+	if lateDays <= deposit.LateFeeDays {
+		return &deposit, 0, nil
+	}
+
+	var product model.Products
+	if err := tx.Where("product_id = ?", booking.ProductID).First(&product).Error; err != nil {
+		return nil, 0, err
+	}
+
+	fee, err := bookingAmount(booking, currency.Money(lateDays-deposit.LateFeeDays)*product.LateFeePerDay, product.Currency)
+	if err != nil {
+		return nil, 0, err
+	}
+	if remaining := deposit.Remaining(); fee > remaining {
+		fee = remaining
+	}
+
+	if fee > 0 {
+		escrow, err := systemAccount(tx, model.EscrowAccount, deposit.Currency)
+		if err != nil {
+			return nil, 0, err
+		}
+		lessorWallet, err := lessorWalletAccount(tx, deposit.LessorID)
+		if err != nil {
+			return nil, 0, err
+		}
+		settlement, err := settlementAmount(booking, fee, lessorWallet.Currency)
+		if err != nil {
+			return nil, 0, err
+		}
+		reference := fmt.Sprintf("deposit:%d", deposit.DepositID)
+		description := fmt.Sprintf("late fee for booking %d, %d day(s) overdue", booking.BookingID, lateDays)
+		if err := exchange(tx, model.LateFeeEntry, reference, description, escrow, lessorWallet, fee, settlement); err != nil {
+			return nil, 0, err
+		}
+		deposit.LateFees += fee
+	}
+
+	deposit.LateFeeDays = lateDays
+	if err := tx.Save(&deposit).Error; err != nil {
+		return nil, 0, err
+	}
+	return &deposit, fee, nil
+}
+
+// bookingAmount converts a product-currency amount into the booking currency
+// at the rate fixed when the booking was created.
+func bookingAmount(booking *model.Bookings, amount currency.Money, from currency.Code) (currency.Money, error) {
+	if from == booking.Currency {
+		return amount, nil
+	}
+	rate, err := currency.ParseRate(booking.ExchangeRate)
+	if err != nil {
+		return 0, err
+	}
+	return amount.Mul(rate), nil
+}
+
+// settlementAmount converts a booking-currency amount back into the product
+// currency the lessor is paid in, at the booking's rate.
+func settlementAmount(booking *model.Bookings, amount currency.Money, to currency.Code) (currency.Money, error) {
+	if to == booking.Currency {
+		return amount, nil
+	}
+	rate, err := currency.ParseRate(booking.ExchangeRate)
+	if err != nil {
+		return 0, err
+	}
+	return amount.Mul(currency.Invert(rate)), nil
+}
+
+func lessorWalletAccount(tx *gorm.DB, lessorID int) (*model.LedgerAccounts, error) {
+	var userID uuid.UUID
+	if err := tx.Model(&model.Lessors{}).Select("user_id").Where("lessor_id = ?", lessorID).Scan(&userID).Error; err != nil {
+		return nil, err
+	}
+	if userID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return walletAccount(tx, userID)
+}
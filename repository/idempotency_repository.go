@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"rent-video-game/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IIdempotencyRepository interface {
+	Reserve(record *model.IdempotencyKeys) (*model.IdempotencyKeys, bool, error)
+	Complete(idempotencyKeyID, statusCode int, contentType string, body []byte) error
+	Release(idempotencyKeyID int) error
+	TakeOver(idempotencyKeyID int, staleBefore time.Time) (bool, error)
+	DeleteExpired(createdBefore time.Time) (int64, error)
+}
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db}
+}
+
+// Reserve inserts record unless the same user already used the key on the same
+// route. It reports whether the row is new; otherwise the stored row is
+// returned so the caller can replay or reject the request.
+func (r *IdempotencyRepository) Reserve(record *model.IdempotencyKeys) (*model.IdempotencyKeys, bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	var existing model.IdempotencyKeys
+	if err := r.db.Where("user_id = ? AND key = ? AND route = ?", record.UserID, record.Key, record.Route).
+		First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (r *IdempotencyRepository) Complete(idempotencyKeyID, statusCode int, contentType string, body []byte) error {
+	return r.db.Model(&model.IdempotencyKeys{}).
+		Where("idempotency_key_id = ?", idempotencyKeyID).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+		}).Error
+}
+
+// Release forgets a reserved key so the request can be retried with it.
+func (r *IdempotencyRepository) Release(idempotencyKeyID int) error {
+	return r.db.Where("idempotency_key_id = ?", idempotencyKeyID).Delete(&model.IdempotencyKeys{}).Error
+}
+
+// TakeOver renews the lease of a reserved key that was never answered and was
+// reserved before staleBefore. It reports false when another request answered
+// or took over the key first.
+func (r *IdempotencyRepository) TakeOver(idempotencyKeyID int, staleBefore time.Time) (bool, error) {
+	result := r.db.Model(&model.IdempotencyKeys{}).
+		Where("idempotency_key_id = ? AND status_code = 0 AND created_at < ?", idempotencyKeyID, staleBefore).
+		Update("created_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *IdempotencyRepository) DeleteExpired(createdBefore time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", createdBefore).Delete(&model.IdempotencyKeys{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnbalancedEntry  = errors.New("journal entry postings must sum to zero")
+	ErrCurrencyMismatch = errors.New("accounts hold different currencies")
+)
+
+type ILedgerRepository interface {
+	OpenAccounts() error
+	GetStatementByUser(userID uuid.UUID) ([]model.LedgerStatementData, error)
+	Reconcile() ([]model.LedgerReconciliationData, error)
+}
+
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{db}
+}
+
+// OpenAccounts creates the system accounts and books an opening balance for
+// every wallet that held money before the ledger existed, so that the cached
+// Users.Amount and the postings agree from the first run.
+func (r *LedgerRepository) OpenAccounts() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var users []model.Users
+		if err := tx.Where("amount <> 0 AND user_id NOT IN (?)",
+			tx.Model(&model.LedgerAccounts{}).Select("user_id").Where("user_id IS NOT NULL")).
+			Find(&users).Error; err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			wallet, err := walletAccount(tx, user.UserID)
+			if err != nil {
+				return err
+			}
+
+			clearing, err := systemAccount(tx, model.PaymentClearingAccount, wallet.Currency)
+			if err != nil {
+				return err
+			}
+
+			// the cached balance already holds the money, so only the postings are written
+			entry := &model.JournalEntries{
+				Type:        model.OpeningBalanceEntry,
+				Reference:   "user:" + user.UserID.String(),
+				Description: "opening balance",
+				Postings: []model.Postings{
+					{AccountID: wallet.AccountID, Amount: user.Amount},
+					{AccountID: clearing.AccountID, Amount: -user.Amount},
+				},
+			}
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *LedgerRepository) GetStatementByUser(userID uuid.UUID) ([]model.LedgerStatementData, error) {
+	var statement []model.LedgerStatementData
+	err := r.db.Table("postings").
+		Select(`journal_entries.journal_entry_id, journal_entries.type, journal_entries.reference,
+			journal_entries.description, postings.amount, journal_entries.created_at,
+			SUM(postings.amount) OVER (ORDER BY postings.posting_id) AS balance`).
+		Joins("JOIN journal_entries ON journal_entries.journal_entry_id = postings.journal_entry_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.account_id = postings.account_id").
+		Where("ledger_accounts.user_id = ? AND ledger_accounts.type = ?", userID, model.UserWalletAccount).
+		Order("postings.posting_id").
+		Scan(&statement).Error
+	if err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+func (r *LedgerRepository) Reconcile() ([]model.LedgerReconciliationData, error) {
+	var rows []model.LedgerReconciliationData
+	err := r.db.Table("users").
+		Select(`users.user_id, users.amount AS cached_balance,
+			COALESCE(SUM(postings.amount), 0) AS ledger_balance,
+			users.amount = COALESCE(SUM(postings.amount), 0) AS balanced`).
+		Joins("LEFT JOIN ledger_accounts ON ledger_accounts.user_id = users.user_id AND ledger_accounts.type = ?", model.UserWalletAccount).
+		Joins("LEFT JOIN postings ON postings.account_id = ledger_accounts.account_id").
+		Where("users.deleted_at IS NULL OR users.deleted_at = ?", "0001-01-01 00:00:00").
+		Group("users.user_id, users.amount").
+		Order("users.user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// postJournalEntry writes an entry with its postings and moves the cached
+// balance of every wallet it touches. It must run inside tx so the entry and
+// the cached balances commit together. The postings must balance in each
+// currency on their own, and a wallet may never go below zero.
+func postJournalEntry(tx *gorm.DB, entry *model.JournalEntries) error {
+	if len(entry.Postings) < 2 {
+		return ErrUnbalancedEntry
+	}
+
+	accounts := make([]model.LedgerAccounts, len(entry.Postings))
+	sums := map[currency.Code]currency.Money{}
+	for i, posting := range entry.Postings {
+		if err := tx.Where("account_id = ?", posting.AccountID).First(&accounts[i]).Error; err != nil {
+			return err
+		}
+		sums[accounts[i].Currency] += posting.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return ErrUnbalancedEntry
+		}
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	for i, posting := range entry.Postings {
+		account := accounts[i]
+		if account.Type != model.UserWalletAccount {
+			continue
+		}
+
+		update := tx.Model(&model.Users{}).
+			Where("user_id = ? AND amount + ? >= 0", *account.UserID, posting.Amount).
+			Update("amount", gorm.Expr("amount + ?", posting.Amount))
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			return ErrInsufficientBalance
+		}
+	}
+
+	return nil
+}
+
+// transfer books a two-legged entry moving amount from one account to another
+// of the same currency.
+func transfer(tx *gorm.DB, entryType model.JournalEntryType, reference, description string, from, to *model.LedgerAccounts, amount currency.Money) error {
+	if from.Currency != to.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, from.Currency, to.Currency)
+	}
+	return postJournalEntry(tx, &model.JournalEntries{
+		Type:        entryType,
+		Reference:   reference,
+		Description: description,
+		Postings: []model.Postings{
+			{AccountID: from.AccountID, Amount: -amount},
+			{AccountID: to.AccountID, Amount: amount},
+		},
+	})
+}
+
+// exchange moves fromAmount out of one account and toAmount into another of a
+// different currency. The EXCHANGE account of each currency takes the other
+// leg, so the entry balances per currency. Same-currency moves are a plain
+// transfer of fromAmount.
+func exchange(tx *gorm.DB, entryType model.JournalEntryType, reference, description string, from, to *model.LedgerAccounts, fromAmount, toAmount currency.Money) error {
+	if from.Currency == to.Currency {
+		return transfer(tx, entryType, reference, description, from, to, fromAmount)
+	}
+
+	fromExchange, err := systemAccount(tx, model.ExchangeAccount, from.Currency)
+	if err != nil {
+		return err
+	}
+
+	toExchange, err := systemAccount(tx, model.ExchangeAccount, to.Currency)
+	if err != nil {
+		return err
+	}
+
+	return postJournalEntry(tx, &model.JournalEntries{
+		Type:        entryType,
+		Reference:   reference,
+		Description: description,
+		Postings: []model.Postings{
+			{AccountID: from.AccountID, Amount: -fromAmount},
+			{AccountID: fromExchange.AccountID, Amount: fromAmount},
+			{AccountID: toExchange.AccountID, Amount: -toAmount},
+			{AccountID: to.AccountID, Amount: toAmount},
+		},
+	})
+}
+
+func walletAccount(tx *gorm.DB, userID uuid.UUID) (*model.LedgerAccounts, error) {
+	var account model.LedgerAccounts
+	err := tx.Where("user_id = ? AND type = ?", userID, model.UserWalletAccount).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var walletCurrency currency.Code
+	if err := tx.Model(&model.Users{}).Select("currency").Where("user_id = ?", userID).Scan(&walletCurrency).Error; err != nil {
+		return nil, err
+	}
+	if walletCurrency == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	// another request may open the same wallet concurrently; the unique index keeps one
+	account = model.LedgerAccounts{UserID: &userID, Type: model.UserWalletAccount, Currency: walletCurrency}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+
+	if account.AccountID == 0 {
+		if err := tx.Where("user_id = ? AND type = ?", userID, model.UserWalletAccount).First(&account).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &account, nil
+}
+
+func systemAccount(tx *gorm.DB, accountType model.LedgerAccountType, code currency.Code) (*model.LedgerAccounts, error) {
+	var account model.LedgerAccounts
+	err := tx.Where("user_id IS NULL AND type = ? AND currency = ?", accountType, code).
+		Attrs(model.LedgerAccounts{Type: accountType, Currency: code}).
+		FirstOrCreate(&account).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s %s account: %w", code, accountType, err)
+	}
+	return &account, nil
+}
@@ -36,7 +36,13 @@ func (r *LessorRepository) RegisterLessor(lessor *model.Lessors) (*model.Lessors
 		return nil, err
 	}
 
-	if err := r.db.Create(lessor).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(lessor).Error; err != nil {
+			return err
+		}
+		return grantRole(tx, lessor.UserID, model.RoleLessor)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return lessor, nil
@@ -76,7 +82,13 @@ func (r *LessorRepository) DeleteLessor(lessorID int) (*model.Lessors, error) {
 		return nil, err
 	}
 
-	if err := r.db.Model(&lessor).Update("deleted_at", time.Now()).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&lessor).Update("deleted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return revokeRole(tx, lessor.UserID, model.RoleLessor)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &lessor, nil
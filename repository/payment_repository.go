@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrBookingNotPayable   = errors.New("booking is not approved or has already been paid")
+)
+
+// QuoteFunc prices a booking. PayBooking calls it inside its transaction, on
+// the locked booking and the product as it stands when the payment is taken.
+type QuoteFunc func(booking *model.Bookings, product *model.Products) (*model.QuoteData, error)
+
+type IPaymentRepository interface {
+	PayBooking(bookingID int, renterID uuid.UUID, quoteBooking QuoteFunc) (*model.PaymentResult, error)
+}
+
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
+	return &PaymentRepository{db}
+}
+
+// PayBooking records the transaction, posts the rental payment to the ledger,
+// holds the product's security deposit in escrow and marks the booking as paid
+// in a single database transaction. Only bookings the lessor approved can be
+// paid, and only once. The booking and both wallets are locked with SELECT ...
+// FOR UPDATE so concurrent payments for the same booking or user are
+// serialized. The product row is read FOR SHARE and the booking is quoted
+// from it, so a price change either lands before the quote or waits for the
+// payment to commit. The renter pays the quote in the booking currency and the
+// lessor receives its product-currency amount.
+func (r *PaymentRepository) PayBooking(bookingID int, renterID uuid.UUID, quoteBooking QuoteFunc) (*model.PaymentResult, error) {
+	var result model.PaymentResult
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("booking_id = ? AND user_id = ?", bookingID, renterID).
+			First(&result.Booking).Error; err != nil {
+			return err
+		}
+
+		if result.Booking.Status != model.Approved {
+			return ErrBookingNotPayable
+		}
+
+		var paid int64
+		if err := tx.Model(&model.Transactions{}).Where("booking_id = ? AND type = ?", bookingID, model.PaymentTransaction).Count(&paid).Error; err != nil {
+			return err
+		}
+		if paid > 0 {
+			return ErrBookingNotPayable
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+			Where("product_id = ?", result.Booking.ProductID).First(&result.Product).Error; err != nil {
+			return err
+		}
+
+		quote, err := quoteBooking(&result.Booking, &result.Product)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("lessor_id = ? AND (deleted_at IS NULL OR deleted_at = ?)",
+			result.Product.LessorID, "0001-01-01 00:00:00").First(&result.Lessor).Error; err != nil {
+			return err
+		}
+
+		// lock both wallets in a stable order so two opposite payments cannot deadlock
+		var wallets []model.Users
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id IN ?", []uuid.UUID{renterID, result.Lessor.UserID}).
+			Order("user_id").
+			Find(&wallets).Error; err != nil {
+			return err
+		}
+
+		for _, wallet := range wallets {
+			switch wallet.UserID {
+			case renterID:
+				result.Renter = wallet
+			case result.Lessor.UserID:
+				result.LessorUser = wallet
+			}
+		}
+
+		if result.Renter.UserID == uuid.Nil || result.LessorUser.UserID == uuid.Nil {
+			return gorm.ErrRecordNotFound
+		}
+
+		if result.Renter.Amount < quote.TotalDue {
+			return ErrInsufficientBalance
+		}
+
+		if result.Renter.Currency != quote.Currency || result.LessorUser.Currency != quote.ProductCurrency {
+			return fmt.Errorf("%w: quote in %s to %s", ErrCurrencyMismatch, quote.Currency, quote.ProductCurrency)
+		}
+
+		result.Transaction = model.Transactions{
+			BookingID:          result.Booking.BookingID,
+			UserID:             renterID,
+			LessorID:           result.Lessor.LessorID,
+			Type:               model.PaymentTransaction,
+			Currency:           quote.Currency,
+			Amount:             quote.Amount,
+			SettlementCurrency: quote.ProductCurrency,
+			SettlementAmount:   quote.ProductAmount,
+			ExchangeRate:       quote.ExchangeRate,
+		}
+		if err := tx.Create(&result.Transaction).Error; err != nil {
+			return err
+		}
+
+		renterWallet, err := walletAccount(tx, renterID)
+		if err != nil {
+			return err
+		}
+
+		lessorWallet, err := walletAccount(tx, result.Lessor.UserID)
+		if err != nil {
+			return err
+		}
+
+		reference := fmt.Sprintf("transaction:%d", result.Transaction.TransactionID)
+		description := fmt.Sprintf("rental payment for booking %d", result.Booking.BookingID)
+		if err := exchange(tx, model.RentalPaymentEntry, reference, description, renterWallet, lessorWallet, quote.Amount, quote.ProductAmount); err != nil {
+			return err
+		}
+
+		result.Deposit, err = holdDeposit(tx, &result.Booking, &result.Product, quote.DepositAmount, renterWallet)
+		if err != nil {
+			return err
+		}
+
+		if err := transitionBooking(tx, &result.Booking, model.Paid, renterID, ""); err != nil {
+			return err
+		}
+
+		result.Renter.Amount -= quote.TotalDue
+		result.LessorUser.Amount += quote.ProductAmount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
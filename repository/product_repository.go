@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
+	"rent-video-game/currency"
 	"rent-video-game/model"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+var ErrStockInUse = errors.New("cannot reduce stock below the units that are currently booked")
+
 type IProductRepository interface {
 	RegisterProduct(product *model.Products) (*model.Products, error)
 	GetProductByID(productID, lessorID int) (*model.Products, error)
@@ -15,10 +21,13 @@ type IProductRepository interface {
 	DeleteProduct(productID, lessorID int) (*model.Products, error)
 
 	GetLessorByProductID(productID int) (*model.Lessors, error)
-	GetAllProducts() ([]model.Products, error)
+	GetPublicProductByID(productID int) (*model.Products, error)
+	SearchProducts(filter *model.ProductFilter) ([]model.ProductListing, error)
+	SearchProductsFullText(terms []string, limit int) ([]model.ProductSearchResult, error)
+	SearchProductsFuzzy(query string, limit int) ([]model.ProductSearchResult, error)
 
-	IncrementStockAvailability(productID int) error
-	DecrementStockAvailability(productID int) error
+	GetAvailability(productID int, from, to string) ([]model.AvailabilityData, error)
+	BackfillUnits() error
 }
 
 type ProductRepository struct {
@@ -29,8 +38,30 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{db}
 }
 
+// RegisterProduct prices the product in the currency of the lessor's wallet,
+// which is where its rent and fees are paid to.
 func (r *ProductRepository) RegisterProduct(product *model.Products) (*model.Products, error) {
-	if err := r.db.Create(product).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Users{}).Select("users.currency").
+			Joins("JOIN lessors ON lessors.user_id = users.user_id").
+			Where("lessors.lessor_id = ?", product.LessorID).
+			Scan(&product.Currency).Error; err != nil {
+			return err
+		}
+		if product.Currency == "" {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := useConsole(tx, product.ConsoleID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		return syncUnits(tx, product)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return product, nil
@@ -48,7 +79,7 @@ func (r *ProductRepository) GetProductByID(productID, lessorID int) (*model.Prod
 func (r *ProductRepository) GetAllProductsByLessor(lessorID int) ([]model.Products, error) {
 	var products []model.Products
 	if err := r.db.Where("lessor_id = ? AND (deleted_at IS NULL OR deleted_at = ?)",
-		lessorID, "0001-01-01 00:00:00").Preload("Consoles").Find(&products).Error; err != nil {
+		lessorID, "0001-01-01 00:00:00").Preload("Consoles").Preload("RatingStats").Find(&products).Error; err != nil {
 		return nil, err
 	}
 	return products, nil
@@ -66,9 +97,20 @@ func (r *ProductRepository) UpdateProduct(productID int, product *model.Products
 	p.Name = product.Name
 	p.Description = product.Description
 	p.RentalCostPerMonth = product.RentalCostPerMonth
+	p.DepositAmount = product.DepositAmount
+	p.LateFeePerDay = product.LateFeePerDay
 	p.StockAvailability = product.StockAvailability
 
-	if err := r.db.Save(&p).Error; err != nil {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := useConsole(tx, p.ConsoleID); err != nil {
+			return err
+		}
+		if err := tx.Save(&p).Error; err != nil {
+			return err
+		}
+		return syncUnits(tx, &p)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &p, nil
@@ -97,18 +139,244 @@ func (r *ProductRepository) GetLessorByProductID(productID int) (*model.Lessors,
 	return &lessor, nil
 }
 
-func (r *ProductRepository) GetAllProducts() ([]model.Products, error) {
-	var products []model.Products
-	if err := r.db.Where("deleted_at IS NULL OR deleted_at = ?", "0001-01-01 00:00:00").Preload("Lessors").Find(&products).Error; err != nil {
+// GetPublicProductByID loads a product for its public page, with its console,
+// lessor and rating stats.
+func (r *ProductRepository) GetPublicProductByID(productID int) (*model.Products, error) {
+	var product model.Products
+	if err := r.db.Where("product_id = ? AND (deleted_at IS NULL OR deleted_at = ?)",
+		productID, "0001-01-01 00:00:00").
+		Preload("Consoles").Preload("Lessors").Preload("RatingStats").
+		First(&product).Error; err != nil {
 		return nil, err
 	}
-	return products, nil
+	return &product, nil
 }
 
-func (r *ProductRepository) IncrementStockAvailability(productID int) error {
-	return r.db.Model(&model.Products{}).Where("product_id = ?", productID).Update("stock_availability", gorm.Expr("stock_availability + 1")).Error
+// productSortKeys maps each catalogue order to the column of the catalogue
+// subquery it sorts by, the direction and the type a cursor key is read as.
+var productSortKeys = map[model.ProductSort]struct{ column, direction, keyType string }{
+	model.SortNewest:    {"created_at", "DESC", "timestamp"},
+	model.SortPriceAsc:  {"price", "ASC", "numeric"},
+	model.SortPriceDesc: {"price", "DESC", "numeric"},
+	model.SortRating:    {"stars", "DESC", "numeric"},
 }
 
-func (r *ProductRepository) DecrementStockAvailability(productID int) error {
-	return r.db.Model(&model.Products{}).Where("product_id = ?", productID).Update("stock_availability", gorm.Expr("stock_availability - 1")).Error
+// SearchProducts returns one page of the public catalogue, plus one extra row
+// when there is a next page. Filtering, ordering and the cursor are all done
+// in SQL. Pages are keyed on the sort column and the product ID, so rows never
+// repeat or go missing between pages while the catalogue changes.
+func (r *ProductRepository) SearchProducts(filter *model.ProductFilter) ([]model.ProductListing, error) {
+	sortKey, ok := productSortKeys[filter.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", filter.Sort)
+	}
+
+	var args []interface{}
+
+	price := "products.rental_cost_per_month"
+	if len(filter.PriceRates) > 0 {
+		var cases strings.Builder
+		for _, code := range currency.Supported() {
+			rate, ok := filter.PriceRates[code]
+			if !ok {
+				continue
+			}
+			cases.WriteString(" WHEN ? THEN ?::numeric")
+			args = append(args, code, rate)
+		}
+		price = "ROUND(products.rental_cost_per_month * CASE products.currency" + cases.String() + " END, 2)"
+	}
+
+	inner := []string{"(products.deleted_at IS NULL OR products.deleted_at = ?)"}
+	args = append(args, "0001-01-01 00:00:00")
+
+	if filter.ConsoleID != 0 {
+		inner = append(inner, "products.console_id = ?")
+		args = append(args, filter.ConsoleID)
+	}
+	if filter.Location != "" {
+		inner = append(inner, `lessors.location ILIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filter.Location)+"%")
+	}
+	if filter.InStock {
+		inner = append(inner, `EXISTS (SELECT 1 FROM product_units
+			WHERE product_units.product_id = products.product_id AND product_units.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM bookings
+				WHERE bookings.unit_id = product_units.unit_id AND bookings.deleted_at IS NULL
+				AND bookings.status IN ? AND bookings.start_date <= CURRENT_DATE AND `+blockingEndDate+` >= CURRENT_DATE))`)
+		args = append(args, model.BlockingBookingStatuses)
+	}
+
+	var outer []string
+	if filter.MinPrice != nil {
+		outer = append(outer, "price >= ?")
+		args = append(args, *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		outer = append(outer, "price <= ?")
+		args = append(args, *filter.MaxPrice)
+	}
+	if sortKey.column == "price" {
+		// products in a currency without a rate cannot be priced
+		outer = append(outer, "price IS NOT NULL")
+	}
+	if filter.MinStars > 0 {
+		outer = append(outer, "stars >= ?")
+		args = append(args, filter.MinStars)
+	}
+	if filter.After != nil {
+		comparison := ">"
+		if sortKey.direction == "DESC" {
+			comparison = "<"
+		}
+		outer = append(outer, fmt.Sprintf("(%s, product_id) %s (CAST(? AS %s), ?)",
+			sortKey.column, comparison, sortKey.keyType))
+		args = append(args, filter.After.Key, filter.After.ProductID)
+	}
+
+	where := ""
+	if len(outer) > 0 {
+		where = "WHERE " + strings.Join(outer, " AND ")
+	}
+	args = append(args, filter.Limit+1)
+
+	var listings []model.ProductListing
+	err := r.db.Raw(`
+		SELECT *, `+sortKey.column+`::text AS sort_key FROM (
+			SELECT products.product_id, products.console_id, products.name, products.currency,
+				products.rental_cost_per_month, products.deposit_amount, products.late_fee_per_day,
+				products.stock_availability, products.created_at, lessors.location,
+				`+price+` AS price,
+				COALESCE(product_rating_stats.average_stars, 0) AS stars,
+				COALESCE(product_rating_stats.rating_count, 0) AS rating_count
+			FROM products
+			JOIN lessors ON lessors.lessor_id = products.lessor_id
+			LEFT JOIN product_rating_stats ON product_rating_stats.product_id = products.product_id
+			WHERE `+strings.Join(inner, " AND ")+`
+		) AS catalogue
+		`+where+`
+		ORDER BY `+sortKey.column+` `+sortKey.direction+`, product_id `+sortKey.direction+`
+		LIMIT ?`, args...).Scan(&listings).Error
+	if err != nil {
+		return nil, err
+	}
+	return listings, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func (r *ProductRepository) GetAvailability(productID int, from, to string) ([]model.AvailabilityData, error) {
+	var availability []model.AvailabilityData
+	err := r.db.Raw(`
+		SELECT to_char(day, 'YYYY-MM-DD') AS date,
+			COUNT(DISTINCT product_units.unit_id) AS total_units,
+			COUNT(DISTINCT product_units.unit_id) - COUNT(DISTINCT bookings.unit_id) AS free_units
+		FROM generate_series(?::date, ?::date, interval '1 day') AS day
+		LEFT JOIN product_units ON product_units.product_id = ? AND product_units.deleted_at IS NULL
+		LEFT JOIN bookings ON bookings.unit_id = product_units.unit_id
+			AND bookings.deleted_at IS NULL
+			AND bookings.status IN ?
+			AND bookings.start_date <= day AND `+blockingEndDate+` >= day
+		GROUP BY day
+		ORDER BY day`,
+		from, to, productID, model.BlockingBookingStatuses).Scan(&availability).Error
+	if err != nil {
+		return nil, err
+	}
+	return availability, nil
+}
+
+// BackfillUnits creates units for products registered before per-unit
+// inventory existed. Stock used to be decremented for every open booking, so
+// those bookings are added back to the unit count and then placed on a unit.
+func (r *ProductRepository) BackfillUnits() error {
+	var products []model.Products
+	if err := r.db.Where("NOT EXISTS (?)",
+		r.db.Unscoped().Model(&model.ProductUnits{}).Select("1").Where("product_units.product_id = products.product_id")).
+		Find(&products).Error; err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var bookings []model.Bookings
+			if err := tx.Where("product_id = ? AND unit_id IS NULL AND status IN ?",
+				product.ProductID, model.BlockingBookingStatuses).
+				Order("start_date").Find(&bookings).Error; err != nil {
+				return err
+			}
+
+			product.StockAvailability += len(bookings)
+			if err := tx.Model(&product).Update("stock_availability", product.StockAvailability).Error; err != nil {
+				return err
+			}
+
+			if err := syncUnits(tx, &product); err != nil {
+				return err
+			}
+
+			for _, booking := range bookings {
+				unit, err := findFreeUnit(tx, product.ProductID, booking.StartDate, booking.EndDate)
+				if errors.Is(err, ErrNoUnitAvailable) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+
+				if err := tx.Model(&booking).Update("unit_id", unit.UnitID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncUnits adds or retires units until the product has StockAvailability
+// active units. Only units without a current or upcoming booking are retired.
+func syncUnits(tx *gorm.DB, product *model.Products) error {
+	var count int64
+	if err := tx.Model(&model.ProductUnits{}).Where("product_id = ?", product.ProductID).Count(&count).Error; err != nil {
+		return err
+	}
+
+	for i := int(count); i < product.StockAvailability; i++ {
+		unit := model.ProductUnits{
+			ProductID: product.ProductID,
+			Label:     fmt.Sprintf("unit %d", i+1),
+		}
+		if err := tx.Create(&unit).Error; err != nil {
+			return err
+		}
+	}
+
+	surplus := int(count) - product.StockAvailability
+	if surplus <= 0 {
+		return nil
+	}
+
+	var units []model.ProductUnits
+	if err := tx.Where("product_id = ?", product.ProductID).
+		Where("NOT EXISTS (?)", tx.Model(&model.Bookings{}).Select("1").
+			Where("bookings.unit_id = product_units.unit_id AND bookings.status IN ? AND "+blockingEndDate+" >= CURRENT_DATE",
+				model.BlockingBookingStatuses)).
+		Order("unit_id DESC").
+		Limit(surplus).
+		Find(&units).Error; err != nil {
+		return err
+	}
+
+	if len(units) < surplus {
+		return ErrStockInUse
+	}
+
+	return tx.Delete(&units).Error
 }
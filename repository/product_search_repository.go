@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+	"rent-video-game/model"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ProductSearchSchema keeps products.search_vector up to date. Names and the
+// console name weigh more than descriptions. Renaming a console or moving
+// products to another one re-indexes the products through the trigger. The
+// statements are idempotent and run on every start.
+var ProductSearchSchema = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`CREATE OR REPLACE FUNCTION products_search_vector() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector :=
+			setweight(to_tsvector('simple', COALESCE(NEW.name, '')), 'A') ||
+			setweight(to_tsvector('simple', COALESCE((SELECT name FROM consoles WHERE console_id = NEW.console_id), '')), 'B') ||
+			setweight(to_tsvector('simple', COALESCE(NEW.description, '')), 'C');
+		RETURN NEW;
+	END $$ LANGUAGE plpgsql`,
+	`CREATE OR REPLACE TRIGGER products_search_vector
+		BEFORE INSERT OR UPDATE OF name, description, console_id ON products
+		FOR EACH ROW EXECUTE FUNCTION products_search_vector()`,
+	`CREATE OR REPLACE FUNCTION consoles_search_vector() RETURNS trigger AS $$
+	BEGIN
+		UPDATE products SET name = name WHERE console_id = NEW.console_id;
+		RETURN NEW;
+	END $$ LANGUAGE plpgsql`,
+	`CREATE OR REPLACE TRIGGER consoles_search_vector
+		AFTER UPDATE OF name ON consoles
+		FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name) EXECUTE FUNCTION consoles_search_vector()`,
+	`UPDATE products SET name = name WHERE search_vector IS NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)`,
+	`CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (LOWER(name) gin_trgm_ops)`,
+}
+
+// FuzzySearchThreshold is the least word similarity a fuzzy hit needs. It is
+// low enough for one wrong letter in a short word, e.g. "zelfa" for "zelda";
+// the pg_trgm default of 0.6 is not.
+const FuzzySearchThreshold = 0.3
+
+const productSearchColumns = `products.product_id, products.console_id, consoles.name AS console_name,
+	products.name, products.currency, products.rental_cost_per_month, products.deposit_amount,
+	products.late_fee_per_day, products.stock_availability, lessors.location,
+	COALESCE(product_rating_stats.average_stars, 0) AS stars,
+	COALESCE(product_rating_stats.rating_count, 0) AS rating_count`
+
+const productSearchJoins = `FROM products
+	JOIN consoles ON consoles.console_id = products.console_id
+	JOIN lessors ON lessors.lessor_id = products.lessor_id
+	LEFT JOIN product_rating_stats ON product_rating_stats.product_id = products.product_id`
+
+const headlineOptions = `'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2'`
+
+// SearchProductsFullText ranks products matching every term. Each term also
+// matches as a prefix, so "zel" finds "Zelda" while the renter is typing.
+func (r *ProductRepository) SearchProductsFullText(terms []string, limit int) ([]model.ProductSearchResult, error) {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+
+	var results []model.ProductSearchResult
+	err := r.db.Raw(`
+		WITH query AS (SELECT to_tsquery('simple', ?) AS q)
+		SELECT `+productSearchColumns+`,
+			ts_rank_cd(products.search_vector, query.q) AS rank,
+			ts_headline('simple', products.name, query.q, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS name_highlight,
+			ts_headline('simple', products.description, query.q, `+headlineOptions+`) AS snippet
+		`+productSearchJoins+`, query
+		WHERE products.search_vector @@ query.q
+			AND (products.deleted_at IS NULL OR products.deleted_at = ?)
+		ORDER BY rank DESC, products.product_id
+		LIMIT ?`,
+		strings.Join(prefixes, " & "), "0001-01-01 00:00:00", limit).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SearchProductsFuzzy is the fallback when the full-text search finds
+// nothing, usually because of a typo. It ranks products by trigram word
+// similarity between the query and the product name, which the trigram index
+// serves.
+func (r *ProductRepository) SearchProductsFuzzy(query string, limit int) ([]model.ProductSearchResult, error) {
+	var results []model.ProductSearchResult
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %g", FuzzySearchThreshold)).Error; err != nil {
+			return err
+		}
+
+		return tx.Raw(`
+			SELECT `+productSearchColumns+`,
+				word_similarity(?, LOWER(products.name)) AS rank,
+				products.name AS name_highlight,
+				LEFT(products.description, 200) AS snippet
+			`+productSearchJoins+`
+			WHERE ? <% LOWER(products.name)
+				AND (products.deleted_at IS NULL OR products.deleted_at = ?)
+			ORDER BY rank DESC, products.product_id
+			LIMIT ?`,
+			query, query, "0001-01-01 00:00:00", limit).Scan(&results).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -1,18 +1,33 @@
 package repository
 
 import (
+	"errors"
 	"rent-video-game/model"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRatingNotOwned       = errors.New("rating is not on one of your products")
+	ErrRatingAlreadyReplied = errors.New("rating already has a reply")
 )
 
 type IRatingRepository interface {
 	CreateRating(rating *model.Ratings) (*model.Ratings, error)
+	UpdateRating(rating *model.Ratings) (*model.Ratings, error)
+	DeleteRating(rating *model.Ratings) error
+	ReplyToRating(ratingID, lessorID int, reply string, now time.Time) (*model.Ratings, error)
 
-	GetAllRatingByProduct(productID int) ([]model.Ratings, error)
-	GetAverageRatingByProduct(productID int) (float64, error)
+	GetRatingsByProduct(productID, beforeID, limit int) ([]model.Ratings, error)
+	GetRatingStatsByProduct(productID int) (*model.ProductRatingStats, error)
+	GetRatingSummaryByLessor(lessorID int) (*model.RatingSummaryData, error)
+	BackfillRatingStats() error
+	GetRatingByID(ratingID int) (*model.Ratings, error)
 	GetRatingByUserAndProduct(userID uuid.UUID, productID int) (*model.Ratings, error)
+	HasRentedProduct(userID uuid.UUID, productID int) (bool, error)
 }
 
 type RatingRepository struct {
@@ -23,34 +38,147 @@ func NewRatingRepository(db *gorm.DB) *RatingRepository {
 	return &RatingRepository{db}
 }
 
+// CreateRating stores the rating and refreshes the product's rating stats in
+// the same transaction.
 func (r *RatingRepository) CreateRating(rating *model.Ratings) (*model.Ratings, error) {
-	if err := r.db.Create(rating).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockRatingStats(tx, rating.ProductID); err != nil {
+			return err
+		}
+		if err := tx.Create(rating).Error; err != nil {
+			return err
+		}
+		return refreshRatingStats(tx, rating.ProductID)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return rating, nil
 }
 
-func (r *RatingRepository) GetAllRatingByProduct(productID int) ([]model.Ratings, error) {
+// UpdateRating saves a new review and stars for the rating and refreshes the
+// product's rating stats in the same transaction.
+func (r *RatingRepository) UpdateRating(rating *model.Ratings) (*model.Ratings, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockRatingStats(tx, rating.ProductID); err != nil {
+			return err
+		}
+		if err := tx.Model(rating).Select("review", "stars").Updates(rating).Error; err != nil {
+			return err
+		}
+		return refreshRatingStats(tx, rating.ProductID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rating, nil
+}
+
+func (r *RatingRepository) DeleteRating(rating *model.Ratings) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockRatingStats(tx, rating.ProductID); err != nil {
+			return err
+		}
+		if err := tx.Delete(rating).Error; err != nil {
+			return err
+		}
+		return refreshRatingStats(tx, rating.ProductID)
+	})
+}
+
+// ReplyToRating posts the lessor's public reply to a rating of one of their
+// products. A rating takes a single reply.
+func (r *RatingRepository) ReplyToRating(ratingID, lessorID int, reply string, now time.Time) (*model.Ratings, error) {
+	var rating model.Ratings
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("rating_id = ?", ratingID).First(&rating).Error; err != nil {
+			return err
+		}
+
+		var owned int64
+		if err := tx.Model(&model.Products{}).
+			Where("product_id = ? AND lessor_id = ?", rating.ProductID, lessorID).
+			Count(&owned).Error; err != nil {
+			return err
+		}
+		if owned == 0 {
+			return ErrRatingNotOwned
+		}
+		if rating.RepliedAt != nil {
+			return ErrRatingAlreadyReplied
+		}
+
+		rating.Reply = reply
+		rating.RepliedAt = &now
+		return tx.Model(&rating).Select("reply", "replied_at").Updates(&rating).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// GetRatingsByProduct lists a product's ratings newest first, starting below
+// beforeID when it is set.
+func (r *RatingRepository) GetRatingsByProduct(productID, beforeID, limit int) ([]model.Ratings, error) {
+	query := r.db.Where("product_id = ?", productID)
+	if beforeID > 0 {
+		query = query.Where("rating_id < ?", beforeID)
+	}
+
 	var ratings []model.Ratings
-	if err := r.db.Where("product_id = ?", productID).Find(&ratings).Error; err != nil {
+	if err := query.Order("rating_id DESC").Limit(limit).Find(&ratings).Error; err != nil {
 		return nil, err
 	}
 	return ratings, nil
 }
 
-func (r *RatingRepository) GetAverageRatingByProduct(productID int) (float64, error) {
-	var avgRating float64
-
-	row := r.db.Model(&model.Ratings{}).
-		Select("COALESCE(AVG(stars), 0) as stars").
-		Where("product_id = ?", productID).
-		Row()
+// GetRatingStatsByProduct returns zero stats for a product nobody rated yet.
+func (r *RatingRepository) GetRatingStatsByProduct(productID int) (*model.ProductRatingStats, error) {
+	var stats model.ProductRatingStats
+	if err := r.db.Where("product_id = ?", productID).Limit(1).Find(&stats).Error; err != nil {
+		return nil, err
+	}
+	stats.ProductID = productID
+	return &stats, nil
+}
 
-	if err := row.Scan(&avgRating); err != nil {
-		return 0, err
+// GetRatingSummaryByLessor averages the ratings of all the lessor's products,
+// weighting each product by how often it was rated.
+func (r *RatingRepository) GetRatingSummaryByLessor(lessorID int) (*model.RatingSummaryData, error) {
+	var summary model.RatingSummaryData
+	err := r.db.Raw(`SELECT
+			COALESCE(SUM(product_rating_stats.rating_count), 0) AS rating_count,
+			COALESCE(ROUND(SUM(product_rating_stats.average_stars * product_rating_stats.rating_count)
+				/ NULLIF(SUM(product_rating_stats.rating_count), 0), 2), 0) AS average_stars
+		FROM product_rating_stats
+		JOIN products ON products.product_id = product_rating_stats.product_id
+		WHERE products.lessor_id = ? AND (products.deleted_at IS NULL OR products.deleted_at = ?)`,
+		lessorID, "0001-01-01 00:00:00").Scan(&summary).Error
+	if err != nil {
+		return nil, err
 	}
+	return &summary, nil
+}
 
-	return avgRating, nil
+// BackfillRatingStats recomputes the stats of every rated product. It runs at
+// startup, so products rated before the stats table existed get their rows.
+func (r *RatingRepository) BackfillRatingStats() error {
+	return r.db.Exec(`INSERT INTO product_rating_stats
+		(product_id, ` + ratingStatsColumns + `, updated_at)
+		SELECT product_id, ` + ratingStatsAggregates + `, NOW()
+		FROM ratings WHERE deleted_at IS NULL
+		GROUP BY product_id
+		ON CONFLICT (product_id) DO UPDATE SET ` + ratingStatsUpdates).Error
+}
+
+func (r *RatingRepository) GetRatingByID(ratingID int) (*model.Ratings, error) {
+	var rating model.Ratings
+	if err := r.db.Where("rating_id = ?", ratingID).First(&rating).Error; err != nil {
+		return nil, err
+	}
+	return &rating, nil
 }
 
 func (r *RatingRepository) GetRatingByUserAndProduct(userID uuid.UUID, productID int) (*model.Ratings, error) {
@@ -60,3 +188,53 @@ func (r *RatingRepository) GetRatingByUserAndProduct(userID uuid.UUID, productID
 	}
 	return &rating, nil
 }
+
+// HasRentedProduct reports whether the user has a booking of the product that
+// entitles them to rate it.
+func (r *RatingRepository) HasRentedProduct(userID uuid.UUID, productID int) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.Bookings{}).
+		Where("user_id = ? AND product_id = ? AND status IN ?", userID, productID, model.RatingBookingStatuses).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+const ratingStatsColumns = "rating_count, average_stars, stars_1, stars_2, stars_3, stars_4, stars_5"
+
+const ratingStatsAggregates = `COUNT(*), COALESCE(ROUND(AVG(stars), 2), 0),
+	COUNT(*) FILTER (WHERE ROUND(stars) <= 1),
+	COUNT(*) FILTER (WHERE ROUND(stars) = 2),
+	COUNT(*) FILTER (WHERE ROUND(stars) = 3),
+	COUNT(*) FILTER (WHERE ROUND(stars) = 4),
+	COUNT(*) FILTER (WHERE ROUND(stars) >= 5)`
+
+const ratingStatsUpdates = `rating_count = EXCLUDED.rating_count, average_stars = EXCLUDED.average_stars,
+	stars_1 = EXCLUDED.stars_1, stars_2 = EXCLUDED.stars_2, stars_3 = EXCLUDED.stars_3,
+	stars_4 = EXCLUDED.stars_4, stars_5 = EXCLUDED.stars_5, updated_at = EXCLUDED.updated_at`
+
+// lockRatingStats makes sure the product has a stats row and locks it, so
+// concurrent rating writes for one product refresh the stats one at a time and
+// none of them is left out.
+func lockRatingStats(tx *gorm.DB, productID int) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.ProductRatingStats{ProductID: productID}).Error; err != nil {
+		return err
+	}
+
+	var stats model.ProductRatingStats
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ?", productID).First(&stats).Error
+}
+
+// refreshRatingStats recomputes the product's stats from its ratings. It must
+// run after lockRatingStats in the same transaction.
+func refreshRatingStats(tx *gorm.DB, productID int) error {
+	return tx.Exec(`INSERT INTO product_rating_stats
+		(product_id, `+ratingStatsColumns+`, updated_at)
+		SELECT ?, `+ratingStatsAggregates+`, NOW()
+		FROM ratings WHERE product_id = ? AND deleted_at IS NULL
+		ON CONFLICT (product_id) DO UPDATE SET `+ratingStatsUpdates,
+		productID, productID).Error
+}
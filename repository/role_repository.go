@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrRoleNotFound = errors.New("role not found")
+
+type IRoleRepository interface {
+	SeedRoles(defaults map[model.RoleName][]model.Permission) error
+	GetAllRoles() ([]model.Roles, error)
+	GetRolesByUser(userID uuid.UUID) ([]model.Roles, error)
+	AssignRole(userID uuid.UUID, role model.RoleName) error
+	RevokeRole(userID uuid.UUID, role model.RoleName) error
+}
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db}
+}
+
+// SeedRoles creates the roles and permissions and links them. It also grants
+// the renter role to every user and the lessor role to every active lessor,
+// so accounts created before roles existed keep working. It is safe to run on
+// every start.
+func (r *RoleRepository) SeedRoles(defaults map[model.RoleName][]model.Permission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for name, permissions := range defaults {
+			var role model.Roles
+			if err := tx.Where("name = ?", name).Attrs(model.Roles{Name: name}).FirstOrCreate(&role).Error; err != nil {
+				return fmt.Errorf("failed to seed role %s: %w", name, err)
+			}
+
+			for _, permissionName := range permissions {
+				var permission model.Permissions
+				if err := tx.Where("name = ?", permissionName).Attrs(model.Permissions{Name: permissionName}).FirstOrCreate(&permission).Error; err != nil {
+					return fmt.Errorf("failed to seed permission %s: %w", permissionName, err)
+				}
+
+				if err := tx.Exec(`INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?)
+					ON CONFLICT DO NOTHING`, role.RoleID, permission.PermissionID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Exec(`INSERT INTO user_roles (user_id, role_id, created_at)
+			SELECT users.user_id, roles.role_id, NOW() FROM users, roles
+			WHERE roles.name = ? AND (users.deleted_at IS NULL OR users.deleted_at = ?)
+			ON CONFLICT DO NOTHING`, model.RoleRenter, "0001-01-01 00:00:00").Error; err != nil {
+			return err
+		}
+
+		return tx.Exec(`INSERT INTO user_roles (user_id, role_id, created_at)
+			SELECT lessors.user_id, roles.role_id, NOW() FROM lessors, roles
+			WHERE roles.name = ? AND (lessors.deleted_at IS NULL OR lessors.deleted_at = ?)
+			ON CONFLICT DO NOTHING`, model.RoleLessor, "0001-01-01 00:00:00").Error
+	})
+}
+
+func (r *RoleRepository) GetAllRoles() ([]model.Roles, error) {
+	var roles []model.Roles
+	if err := r.db.Preload("Permissions").Order("role_id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetRolesByUser(userID uuid.UUID) ([]model.Roles, error) {
+	var roles []model.Roles
+	if err := r.db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.role_id").
+		Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) AssignRole(userID uuid.UUID, role model.RoleName) error {
+	return grantRole(r.db, userID, role)
+}
+
+func (r *RoleRepository) RevokeRole(userID uuid.UUID, role model.RoleName) error {
+	return revokeRole(r.db, userID, role)
+}
+
+// grantRole gives the user a role inside tx. Granting a role the user already
+// holds is a no-op.
+func grantRole(tx *gorm.DB, userID uuid.UUID, name model.RoleName) error {
+	var role model.Roles
+	if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+		}
+		return err
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserRoles{UserID: userID, RoleID: role.RoleID}).Error
+}
+
+func revokeRole(tx *gorm.DB, userID uuid.UUID, name model.RoleName) error {
+	return tx.Where("user_id = ? AND role_id IN (?)", userID,
+		tx.Model(&model.Roles{}).Select("role_id").Where("name = ?", name)).
+		Delete(&model.UserRoles{}).Error
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+type ISchedulerRepository interface {
+	RunExclusive(name string, run func() error) (bool, error)
+}
+
+type SchedulerRepository struct {
+	db *gorm.DB
+}
+
+func NewSchedulerRepository(db *gorm.DB) *SchedulerRepository {
+	return &SchedulerRepository{db}
+}
+
+// RunExclusive runs the job only if no other process currently holds the
+// Postgres advisory lock for name. The lock is scoped to a transaction that
+// stays open while the job runs, so it is released even if the process dies.
+// It reports whether this process ran the job.
+func (r *SchedulerRepository) RunExclusive(name string, run func() error) (bool, error) {
+	ran := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(hashtext(?))", "scheduler:"+name).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		ran = true
+		return run()
+	})
+	return ran, err
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"rent-video-game/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetPasswordRevokesRefreshTokens(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewAccountTokenRepository(db)
+
+	now := time.Now()
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "account_tokens" SET "used_at"=\$1 WHERE jti = \$2 AND user_id = \$3 AND purpose = \$4 AND used_at IS NULL AND expires_at > \$5`).
+		WithArgs(now, "jti-1", userID, "reset_password", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "users" SET "password"=\$1,"updated_at"=\$2 WHERE user_id = \$3`).
+		WithArgs("new-hash", sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "users" SET "email_verified_at"=\$1,"updated_at"=\$2 WHERE \(user_id = \$3 AND email_verified_at IS NULL\)`).
+		WithArgs(now, sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE user_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(now, userID).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	err := repo.ResetPassword("jti-1", userID, "new-hash", now)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyEmailTokenAlreadyUsed(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewAccountTokenRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "account_tokens" SET "used_at"=\$1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repo.VerifyEmail("jti-1", uuid.New(), time.Now())
+
+	assert.ErrorIs(t, err, repository.ErrAccountTokenInvalid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
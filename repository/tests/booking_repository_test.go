@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// freeUnitQuery is the findFreeUnit lookup: the first unit with no blocking
+// booking overlapping the requested dates.
+const freeUnitQuery = `SELECT \* FROM "product_units" WHERE product_id = \$1 AND NOT EXISTS \(SELECT 1 FROM "bookings" ` +
+	`WHERE \(bookings.unit_id = product_units.unit_id AND bookings.status IN \(\$2,\$3,\$4,\$5,\$6\) ` +
+	`AND bookings.start_date <= \$7 AND CASE bookings.status .* END >= \$8\) AND "bookings"."deleted_at" IS NULL\) ` +
+	`AND "product_units"."deleted_at" IS NULL ORDER BY unit_id,"product_units"."unit_id" LIMIT \$9`
+
+func expectLockedProduct(mock sqlmock.Sqlmock, productID int) {
+	mock.ExpectQuery(`SELECT \* FROM "products" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "lessor_id"}).AddRow(productID, 3))
+}
+
+func TestCreateBookingTakesFirstFreeUnit(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewBookingRepository(db)
+
+	mock.ExpectBegin()
+	expectLockedProduct(mock, 2)
+	mock.ExpectQuery(freeUnitQuery).
+		WithArgs(2, model.Pending, model.Approved, model.Paid, model.Active, model.Overdue, "2025-03-10", "2025-03-01", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"unit_id", "product_id"}).AddRow(8, 2))
+	mock.ExpectQuery(`INSERT INTO "bookings"`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	booking, err := repo.CreateBooking(&model.Bookings{
+		UserID: uuid.New(), ProductID: 2, StartDate: "2025-03-01", EndDate: "2025-03-10", Status: model.Pending,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 8, *booking.UnitID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateBookingRejectsOverlap(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewBookingRepository(db)
+
+	mock.ExpectBegin()
+	expectLockedProduct(mock, 2)
+	// every unit has a blocking booking overlapping the dates
+	mock.ExpectQuery(freeUnitQuery).
+		WithArgs(2, model.Pending, model.Approved, model.Paid, model.Active, model.Overdue, "2025-03-10", "2025-03-01", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"unit_id", "product_id"}))
+	mock.ExpectRollback()
+
+	booking, err := repo.CreateBooking(&model.Bookings{
+		UserID: uuid.New(), ProductID: 2, StartDate: "2025-03-01", EndDate: "2025-03-10", Status: model.Pending,
+	})
+
+	assert.ErrorIs(t, err, repository.ErrNoUnitAvailable)
+	assert.Nil(t, booking)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateBookingRecordsHistory(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewBookingRepository(db)
+
+	renterID := uuid.New()
+	lessorUserID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE booking_id = \$1 .* FOR UPDATE`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, renterID, 2, model.Pending))
+	mock.ExpectExec(`UPDATE "bookings" SET "status"=\$1,"status_reason"=\$2,"updated_at"=\$3 WHERE`).
+		WithArgs(model.Rejected, "console is broken", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "booking_status_histories" \("booking_id","from_status","to_status","changed_by","reason","created_at"\)`).
+		WithArgs(1, model.Pending, model.Rejected, lessorUserID, "console is broken", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"history_id"}).AddRow(4))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE booking_id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status", "status_reason"}).
+			AddRow(1, renterID, 2, model.Rejected, "console is broken"))
+	mock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(renterID))
+
+	booking, err := repo.UpdateBooking(1, model.Rejected, &model.Bookings{StatusReason: "console is broken"}, lessorUserID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Rejected, booking.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateBookingRejectsIllegalTransition(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewBookingRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE booking_id = \$1 .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, uuid.New(), 2, model.Rejected))
+	mock.ExpectRollback()
+
+	booking, err := repo.UpdateBooking(1, model.Approved, &model.Bookings{}, uuid.New())
+
+	assert.ErrorIs(t, err, repository.ErrInvalidTransition)
+	assert.Nil(t, booking)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// unpaidBookingsQuery times pending bookings from creation and approved ones
+// from their approval.
+const unpaidBookingsQuery = `SELECT \* FROM "bookings" WHERE \(\(status = \$1 AND created_at < \$2\) OR \(status = \$3 AND\s+` +
+	`COALESCE\(\(SELECT MAX\(booking_status_histories.created_at\) FROM booking_status_histories\s+` +
+	`WHERE booking_status_histories.booking_id = bookings.booking_id\s+` +
+	`AND booking_status_histories.to_status = \$4\), bookings.updated_at\) < \$5\)\) ` +
+	`AND "bookings"."deleted_at" IS NULL ORDER BY booking_id FOR UPDATE SKIP LOCKED`
+
+func TestExpireUnpaidBookingsCancelsStalePending(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewBookingRepository(db)
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	renterID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(unpaidBookingsQuery).
+		WithArgs(model.Pending, cutoff, model.Approved, model.Approved, cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "status", "created_at"}).
+			AddRow(1, renterID, model.Pending, cutoff.Add(-time.Hour)))
+	mock.ExpectExec(`UPDATE "bookings" SET "status"=\$1,"status_reason"=\$2,"updated_at"=\$3 WHERE`).
+		WithArgs(model.Cancelled, "expired", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "booking_status_histories"`).
+		WithArgs(1, model.Pending, model.Cancelled, nil, "expired", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"history_id"}).AddRow(2))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE booking_id IN \(\$1\)`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "status"}).
+			AddRow(1, renterID, model.Cancelled))
+	mock.ExpectQuery(`SELECT \* FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(renterID))
+
+	bookings, err := repo.ExpireUnpaidBookings(cutoff, "expired")
+
+	assert.NoError(t, err)
+	assert.Len(t, bookings, 1)
+	assert.Equal(t, model.Cancelled, bookings[0].Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExpireUnpaidBookingsKeepsRecentlyApproved(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewBookingRepository(db)
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+
+	// a booking created two days ago but approved an hour ago is not matched:
+	// approved bookings are timed from their APPROVED history row
+	mock.ExpectBegin()
+	mock.ExpectQuery(unpaidBookingsQuery).
+		WithArgs(model.Pending, cutoff, model.Approved, model.Approved, cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "status", "created_at"}))
+	mock.ExpectCommit()
+
+	bookings, err := repo.ExpireUnpaidBookings(cutoff, "expired")
+
+	assert.NoError(t, err)
+	assert.Empty(t, bookings)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"rent-video-game/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteConsoleInUse(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewConsoleRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "consoles" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"console_id", "name"}).AddRow(1, "PS5"))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	console, err := repo.DeleteConsole(1, uuid.New())
+
+	assert.ErrorIs(t, err, repository.ErrConsoleInUse)
+	assert.Nil(t, console)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMergeConsoleWritesAuditLog(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewConsoleRepository(db)
+
+	actorID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "consoles" WHERE .* FOR UPDATE`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"console_id", "name"}).AddRow(2, "PlayStation 5"))
+	mock.ExpectQuery(`SELECT \* FROM "consoles" WHERE .* FOR UPDATE`).
+		WithArgs(5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"console_id", "name"}).AddRow(5, "PS5"))
+	mock.ExpectExec(`UPDATE "products" SET "console_id"=\$1,"updated_at"=\$2 WHERE console_id = \$3`).
+		WithArgs(2, sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`UPDATE "consoles" SET "deleted_at"=\$1 WHERE "consoles"."console_id" = \$2`).
+		WithArgs(sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs(actorID, "CONSOLE_MERGE", "console", 2, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"audit_log_id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	result, err := repo.MergeConsole(5, 2, actorID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Console.ConsoleID)
+	assert.Equal(t, 5, result.MergedID)
+	assert.Equal(t, int64(3), result.MovedProducts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"rent-video-game/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTakeOverRenewsUnansweredStaleKey(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewIdempotencyRepository(db)
+
+	staleBefore := time.Now().Add(-5 * time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "created_at"=\$1,"updated_at"=\$2 WHERE idempotency_key_id = \$3 AND status_code = 0 AND created_at < \$4`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, staleBefore).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	takenOver, err := repo.TakeOver(1, staleBefore)
+
+	assert.NoError(t, err)
+	assert.True(t, takenOver)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTakeOverLosesRace(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewIdempotencyRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys" SET "created_at"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	takenOver, err := repo.TakeOver(1, time.Now())
+
+	assert.NoError(t, err)
+	assert.False(t, takenOver)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var ledgerAccountColumns = []string{"account_id", "user_id", "type", "currency"}
+
+// expectWithdrawalHold mocks CreateWithdrawal up to the point where the hold
+// entry is posted: the wallet, the withdrawal row and the hold account. The
+// accounts are then loaded again by ID with the currencies given.
+func expectWithdrawalHold(mock sqlmock.Sqlmock, userID uuid.UUID, walletCurrency, holdCurrency currency.Code) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ledger_accounts" WHERE user_id = \$1 AND type = \$2`).
+		WithArgs(userID, model.UserWalletAccount, 1).
+		WillReturnRows(sqlmock.NewRows(ledgerAccountColumns).
+			AddRow(10, userID, model.UserWalletAccount, currency.USD))
+	mock.ExpectQuery(`INSERT INTO "withdrawals"`).
+		WillReturnRows(sqlmock.NewRows([]string{"withdrawal_id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT \* FROM "ledger_accounts" WHERE user_id IS NULL AND type = \$1 AND currency = \$2`).
+		WithArgs(model.WithdrawalHoldAccount, currency.USD, 1).
+		WillReturnRows(sqlmock.NewRows(ledgerAccountColumns).
+			AddRow(20, nil, model.WithdrawalHoldAccount, currency.USD))
+	mock.ExpectQuery(`SELECT \* FROM "ledger_accounts" WHERE account_id = \$1`).
+		WithArgs(10, 1).
+		WillReturnRows(sqlmock.NewRows(ledgerAccountColumns).
+			AddRow(10, userID, model.UserWalletAccount, walletCurrency))
+	mock.ExpectQuery(`SELECT \* FROM "ledger_accounts" WHERE account_id = \$1`).
+		WithArgs(20, 1).
+		WillReturnRows(sqlmock.NewRows(ledgerAccountColumns).
+			AddRow(20, nil, model.WithdrawalHoldAccount, holdCurrency))
+}
+
+func TestPostJournalEntryRejectsUnbalancedEntry(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewWithdrawalRepository(db)
+
+	userID := uuid.New()
+
+	// the postings sum to zero overall but not within each currency
+	expectWithdrawalHold(mock, userID, currency.USD, currency.SGD)
+	mock.ExpectRollback()
+
+	withdrawal, err := repo.CreateWithdrawal(&model.Withdrawals{UserID: userID, Amount: 2000, Destination: "bank"})
+
+	assert.ErrorIs(t, err, repository.ErrUnbalancedEntry)
+	assert.Nil(t, withdrawal)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostJournalEntryRejectsWalletOverdraft(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewWithdrawalRepository(db)
+
+	userID := uuid.New()
+
+	expectWithdrawalHold(mock, userID, currency.USD, currency.USD)
+	mock.ExpectQuery(`INSERT INTO "journal_entries"`).
+		WithArgs(model.WithdrawalHoldEntry, "withdrawal:5", "withdrawal requested", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id"}).AddRow(7))
+	mock.ExpectQuery(`INSERT INTO "postings"`).
+		WillReturnRows(sqlmock.NewRows([]string{"posting_id"}).AddRow(1).AddRow(2))
+	mock.ExpectExec(`UPDATE "users" SET "amount"=amount \+ \$1,"updated_at"=\$2 WHERE \(user_id = \$3 AND amount \+ \$4 >= 0\)`).
+		WithArgs(currency.Money(-2000), sqlmock.AnyArg(), userID, currency.Money(-2000)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	withdrawal, err := repo.CreateWithdrawal(&model.Withdrawals{UserID: userID, Amount: 2000, Destination: "bank"})
+
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalance)
+	assert.Nil(t, withdrawal)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOpenAccountsBooksOpeningBalances(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewLedgerRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectBegin()
+	// wallets that already have a ledger account are left out by the query
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(amount <> 0 AND user_id NOT IN \(SELECT "user_id" FROM "ledger_accounts" WHERE user_id IS NOT NULL\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "amount", "currency"}).
+			AddRow(userID, "12.50", currency.USD))
+	mock.ExpectQuery(`SELECT \* FROM "ledger_accounts" WHERE user_id = \$1 AND type = \$2`).
+		WithArgs(userID, model.UserWalletAccount, 1).
+		WillReturnRows(sqlmock.NewRows(ledgerAccountColumns))
+	mock.ExpectQuery(`SELECT "currency" FROM "users" WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow(currency.USD))
+	mock.ExpectQuery(`INSERT INTO "ledger_accounts" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"account_id"}).AddRow(10))
+	mock.ExpectQuery(`SELECT \* FROM "ledger_accounts" WHERE user_id IS NULL AND type = \$1 AND currency = \$2`).
+		WithArgs(model.PaymentClearingAccount, currency.USD, 1).
+		WillReturnRows(sqlmock.NewRows(ledgerAccountColumns).
+			AddRow(30, nil, model.PaymentClearingAccount, currency.USD))
+	mock.ExpectQuery(`INSERT INTO "journal_entries"`).
+		WithArgs(model.OpeningBalanceEntry, "user:"+userID.String(), "opening balance", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "postings"`).
+		WithArgs(1, 10, currency.Money(1250), sqlmock.AnyArg(), 1, 30, currency.Money(-1250), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"posting_id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	err := repo.OpenAccounts()
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOpenAccountsWithEveryWalletOpened(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewLedgerRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(amount <> 0 AND user_id NOT IN \(SELECT "user_id" FROM "ledger_accounts" WHERE user_id IS NOT NULL\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "amount", "currency"}))
+	mock.ExpectCommit()
+
+	err := repo.OpenAccounts()
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReconcileFlagsDrift(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewLedgerRepository(db)
+
+	balancedID := uuid.New()
+	driftedID := uuid.New()
+
+	mock.ExpectQuery(`SELECT users.user_id, users.amount AS cached_balance,\s+`+
+		`COALESCE\(SUM\(postings.amount\), 0\) AS ledger_balance,\s+`+
+		`users.amount = COALESCE\(SUM\(postings.amount\), 0\) AS balanced FROM "users" `+
+		`LEFT JOIN ledger_accounts ON ledger_accounts.user_id = users.user_id AND ledger_accounts.type = \$1 `+
+		`LEFT JOIN postings ON postings.account_id = ledger_accounts.account_id `+
+		`WHERE users.deleted_at IS NULL OR users.deleted_at = \$2 `+
+		`GROUP BY users.user_id, users.amount ORDER BY users.user_id`).
+		WithArgs(model.UserWalletAccount, "0001-01-01 00:00:00").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "cached_balance", "ledger_balance", "balanced"}).
+			AddRow(balancedID, "10.00", "10.00", true).
+			AddRow(driftedID, "15.00", "10.00", false))
+
+	rows, err := repo.Reconcile()
+
+	assert.NoError(t, err)
+	assert.Len(t, rows, 2)
+	assert.True(t, rows[0].Balanced)
+	assert.False(t, rows[1].Balanced)
+	assert.Equal(t, currency.Money(1500), rows[1].CachedBalance)
+	assert.Equal(t, currency.Money(1000), rows[1].LedgerBalance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetStatementByUserRunsBalance(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewLedgerRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectQuery(`SUM\(postings.amount\) OVER \(ORDER BY postings.posting_id\) AS balance FROM "postings" .*`+
+		`WHERE ledger_accounts.user_id = \$1 AND ledger_accounts.type = \$2 ORDER BY postings.posting_id`).
+		WithArgs(userID, model.UserWalletAccount).
+		WillReturnRows(sqlmock.NewRows([]string{"journal_entry_id", "type", "amount", "balance"}).
+			AddRow(1, model.TopupEntry, "20.00", "20.00").
+			AddRow(2, model.RentalPaymentEntry, "-5.00", "15.00"))
+
+	statement, err := repo.GetStatementByUser(userID)
+
+	assert.NoError(t, err)
+	assert.Len(t, statement, 2)
+	assert.Equal(t, currency.Money(1500), statement[1].Balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"errors"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixedQuote prices every booking at quote, whatever the product.
+func fixedQuote(quote *model.QuoteData) repository.QuoteFunc {
+	return func(*model.Bookings, *model.Products) (*model.QuoteData, error) {
+		return quote, nil
+	}
+}
+
+func TestPayBookingInsufficientBalance(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewPaymentRepository(db)
+
+	renterID := uuid.New()
+	lessorUserID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, renterID, 2, model.Approved))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "transactions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "lessor_id"}).AddRow(2, 3))
+	mock.ExpectQuery(`SELECT \* FROM "lessors"`).
+		WillReturnRows(sqlmock.NewRows([]string{"lessor_id", "user_id"}).AddRow(3, lessorUserID))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "amount"}).
+			AddRow(renterID, "5.00").
+			AddRow(lessorUserID, "0.00"))
+	mock.ExpectRollback()
+
+	result, err := repo.PayBooking(1, renterID, fixedQuote(&model.QuoteData{Amount: 1000, TotalDue: 1000}))
+
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalance)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPayBookingNotApproved(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewPaymentRepository(db)
+
+	renterID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, renterID, 2, model.Pending))
+	mock.ExpectRollback()
+
+	result, err := repo.PayBooking(1, renterID, fixedQuote(&model.QuoteData{Amount: 1000, TotalDue: 1000}))
+
+	assert.ErrorIs(t, err, repository.ErrBookingNotPayable)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPayBookingAlreadyPaid(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewPaymentRepository(db)
+
+	renterID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, renterID, 2, model.Approved))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "transactions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	result, err := repo.PayBooking(1, renterID, fixedQuote(&model.QuoteData{Amount: 1000, TotalDue: 1000}))
+
+	assert.ErrorIs(t, err, repository.ErrBookingNotPayable)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPayBookingBalanceMustCoverDeposit(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewPaymentRepository(db)
+
+	renterID := uuid.New()
+	lessorUserID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, renterID, 2, model.Approved))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "transactions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "products"`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "lessor_id", "deposit_amount"}).AddRow(2, 3, "50.00"))
+	mock.ExpectQuery(`SELECT \* FROM "lessors"`).
+		WillReturnRows(sqlmock.NewRows([]string{"lessor_id", "user_id"}).AddRow(3, lessorUserID))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "amount"}).
+			AddRow(renterID, "40.00").
+			AddRow(lessorUserID, "0.00"))
+	mock.ExpectRollback()
+
+	// enough for the rent, not for rent plus deposit
+	result, err := repo.PayBooking(1, renterID, fixedQuote(&model.QuoteData{Amount: 1000, DepositAmount: 5000, TotalDue: 6000}))
+
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalance)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPayBookingQuotesLockedProduct(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewPaymentRepository(db)
+
+	renterID := uuid.New()
+	errStop := errors.New("stop after quote")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "bookings" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"booking_id", "user_id", "product_id", "status"}).
+			AddRow(1, renterID, 2, model.Approved))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "transactions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "products" WHERE product_id = \$1 .* FOR SHARE`).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "lessor_id", "rental_cost_per_month"}).AddRow(2, 3, "25.00"))
+	mock.ExpectRollback()
+
+	var quoted *model.Products
+	result, err := repo.PayBooking(1, renterID, func(booking *model.Bookings, product *model.Products) (*model.QuoteData, error) {
+		quoted = product
+		return nil, errStop
+	})
+
+	assert.ErrorIs(t, err, errStop)
+	assert.Nil(t, result)
+	assert.Equal(t, currency.Money(2500), quoted.RentalCostPerMonth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
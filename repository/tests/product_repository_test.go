@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchProductsByPriceAfterCursor(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewProductRepository(db)
+
+	maxPrice := currency.Money(5000)
+	filter := &model.ProductFilter{
+		ConsoleID:  2,
+		MaxPrice:   &maxPrice,
+		Currency:   currency.USD,
+		PriceRates: map[currency.Code]string{currency.USD: "1", currency.SGD: "0.74"},
+		Sort:       model.SortPriceAsc,
+		After:      &model.ProductCursor{Sort: model.SortPriceAsc, Key: "20.00", ProductID: 7},
+		Limit:      2,
+	}
+
+	mock.ExpectQuery(`SELECT \*, price::text AS sort_key FROM .*`+
+		`ROUND\(products.rental_cost_per_month \* CASE products.currency WHEN \$1 THEN \$2::numeric WHEN \$3 THEN \$4::numeric END, 2\) AS price.*`+
+		`WHERE \(products.deleted_at IS NULL OR products.deleted_at = \$5\) AND products.console_id = \$6.*`+
+		`WHERE price <= \$7 AND price IS NOT NULL AND \(price, product_id\) > \(CAST\(\$8 AS numeric\), \$9\)\s+`+
+		`ORDER BY price ASC, product_id ASC\s+LIMIT \$10`).
+		WithArgs(currency.SGD, "0.74", currency.USD, "1", sqlmock.AnyArg(), 2, maxPrice, "20.00", 7, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "name", "currency", "rental_cost_per_month", "stars", "rating_count", "location", "sort_key"}).
+			AddRow(8, "PS5", "USD", "20.00", "4.50", 12, "Jakarta", "20.00").
+			AddRow(3, "Switch", "SGD", "30.00", "0", 0, "Singapore", "22.20"))
+
+	listings, err := repo.SearchProducts(filter)
+
+	assert.NoError(t, err)
+	assert.Len(t, listings, 2)
+	assert.Equal(t, 4.5, listings[0].Stars)
+	assert.Equal(t, 12, listings[0].RatingCount)
+	assert.Equal(t, "Jakarta", listings[0].Location)
+	assert.Equal(t, "22.20", listings[1].SortKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchProductsRejectsUnknownSort(t *testing.T) {
+	db, _ := NewMockDB()
+	repo := repository.NewProductRepository(db)
+
+	_, err := repo.SearchProducts(&model.ProductFilter{Sort: "cheapest", Limit: 20})
+
+	assert.Error(t, err)
+}
+
+func TestSearchProductsFullTextMatchesPrefixes(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewProductRepository(db)
+
+	mock.ExpectQuery(`WITH query AS \(SELECT to_tsquery\('simple', \$1\) AS q\).*`+
+		`WHERE products.search_vector @@ query.q.*ORDER BY rank DESC, products.product_id\s+LIMIT \$3`).
+		WithArgs("zelda:* & switch:*", sqlmock.AnyArg(), 20).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "console_name", "name", "rank", "name_highlight", "snippet"}).
+			AddRow(4, "Nintendo Switch", "Zelda: Tears of the Kingdom", 0.6, "<mark>Zelda</mark>: Tears of the Kingdom", "Open world"))
+
+	results, err := repo.SearchProductsFullText([]string{"zelda", "switch"}, 20)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 4, results[0].ProductID)
+	assert.Equal(t, "Nintendo Switch", results[0].ConsoleName)
+	assert.Equal(t, "<mark>Zelda</mark>: Tears of the Kingdom", results[0].NameHighlight)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchProductsFuzzyLowersThreshold(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewProductRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL pg_trgm.word_similarity_threshold = 0.3`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`WHERE \$2 <% LOWER\(products.name\)`).
+		WithArgs("zelfa", "zelfa", sqlmock.AnyArg(), 20).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "name", "rank"}).AddRow(4, "Zelda", 0.5))
+	mock.ExpectCommit()
+
+	results, err := repo.SearchProductsFuzzy("zelfa", 20)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 0.5, results[0].Rank)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRatingRefreshesStats(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewRatingRepository(db)
+
+	rating := &model.Ratings{UserID: uuid.New(), ProductID: 4, Review: "Great", Stars: 4.5}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "product_rating_stats" .* ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "product_rating_stats" WHERE product_id = \$1 .* FOR UPDATE`).
+		WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}).AddRow(4))
+	mock.ExpectQuery(`INSERT INTO "ratings"`).
+		WillReturnRows(sqlmock.NewRows([]string{"rating_id"}).AddRow(9))
+	mock.ExpectExec(`INSERT INTO product_rating_stats .* FROM ratings WHERE product_id = \$2 AND deleted_at IS NULL\s+ON CONFLICT \(product_id\) DO UPDATE`).
+		WithArgs(4, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	created, err := repo.CreateRating(rating)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 9, created.RatingID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRatingStatsByProductWithoutRatings(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewRatingRepository(db)
+
+	mock.ExpectQuery(`SELECT \* FROM "product_rating_stats" WHERE product_id = \$1 LIMIT \$2`).
+		WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id"}))
+
+	stats, err := repo.GetRatingStatsByProduct(4)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, stats.ProductID)
+	assert.Equal(t, 0, stats.RatingCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRatingsByProductBeforeCursor(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewRatingRepository(db)
+
+	mock.ExpectQuery(`SELECT \* FROM "ratings" WHERE product_id = \$1 AND rating_id < \$2 AND "ratings"."deleted_at" IS NULL ORDER BY rating_id DESC LIMIT \$3`).
+		WithArgs(4, 7, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"rating_id", "product_id", "stars"}).
+			AddRow(5, 4, "4.0").
+			AddRow(2, 4, "3.5"))
+
+	ratings, err := repo.GetRatingsByProduct(4, 7, 3)
+
+	assert.NoError(t, err)
+	assert.Len(t, ratings, 2)
+	assert.Equal(t, 5, ratings[0].RatingID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplyToRatingAlreadyReplied(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewRatingRepository(db)
+
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "ratings" WHERE rating_id = \$1 .* FOR UPDATE`).
+		WithArgs(9, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"rating_id", "product_id", "reply", "replied_at"}).
+			AddRow(9, 4, "Thanks!", now.Add(-time.Hour)))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "products" WHERE \(product_id = \$1 AND lessor_id = \$2\)`).
+		WithArgs(4, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	rating, err := repo.ReplyToRating(9, 3, "Thanks again!", now)
+
+	assert.ErrorIs(t, err, repository.ErrRatingAlreadyReplied)
+	assert.Nil(t, rating)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasRentedProduct(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewRatingRepository(db)
+
+	userID := uuid.New()
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "bookings" WHERE \(user_id = \$1 AND product_id = \$2 AND status IN \(\$3,\$4,\$5,\$6,\$7\)\)`).
+		WithArgs(userID, 4, model.Approved, model.Paid, model.Active, model.Overdue, model.Returned).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	rented, err := repo.HasRentedProduct(userID, 4)
+
+	assert.NoError(t, err)
+	assert.True(t, rented)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"rent-video-game/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExclusiveSkipsWhenLockIsHeld(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewSchedulerRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock`).
+		WithArgs("scheduler:job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(false))
+	mock.ExpectCommit()
+
+	called := false
+	ran, err := repo.RunExclusive("job", func() error {
+		called = true
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, ran)
+	assert.False(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunExclusiveRunsWhenLockIsFree(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewSchedulerRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock`).
+		WithArgs("scheduler:job").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(true))
+	mock.ExpectCommit()
+
+	called := false
+	ran, err := repo.RunExclusive("job", func() error {
+		called = true
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	assert.True(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
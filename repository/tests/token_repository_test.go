@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewTokenRepository(db)
+
+	now := time.Now()
+	familyID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "refresh_tokens" WHERE token_hash = \$1 .* FOR UPDATE`).
+		WithArgs("spent", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"refresh_token_id", "user_id", "family_id", "expires_at", "used_at"}).
+			AddRow(1, uuid.New(), familyID, now.Add(time.Hour), now.Add(-time.Minute)))
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=\$1 WHERE family_id = \$2 AND revoked_at IS NULL`).
+		WithArgs(now, familyID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	next, err := repo.RotateRefreshToken("spent", &model.RefreshTokens{TokenHash: "next"}, now)
+
+	assert.ErrorIs(t, err, repository.ErrRefreshTokenReused)
+	assert.Nil(t, next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshTokenExpired(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewTokenRepository(db)
+
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "refresh_tokens" WHERE token_hash = \$1 .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"refresh_token_id", "user_id", "family_id", "expires_at"}).
+			AddRow(1, uuid.New(), uuid.New(), now.Add(-time.Minute)))
+	mock.ExpectRollback()
+
+	next, err := repo.RotateRefreshToken("stale", &model.RefreshTokens{TokenHash: "next"}, now)
+
+	assert.ErrorIs(t, err, repository.ErrRefreshTokenInvalid)
+	assert.Nil(t, next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
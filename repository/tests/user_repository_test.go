@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -51,8 +52,15 @@ func TestRegisterUser(t *testing.T) {
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(testUser.UserID))
+	mock.ExpectQuery(`SELECT \* FROM "roles" WHERE name = \$1`).
+		WithArgs(model.RoleRenter, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"role_id", "name"}).AddRow(3, model.RoleRenter))
+	mock.ExpectExec(`INSERT INTO "user_roles"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	mock.ExpectCommit()
 
@@ -64,3 +72,21 @@ func TestRegisterUser(t *testing.T) {
 	assert.Equal(t, testUser.Email, result.Email)
 	assert.Equal(t, testUser.Password, result.Password)
 }
+
+func TestRegisterUserWithoutSeededRoles(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewUserRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(uuid.New()))
+	mock.ExpectQuery(`SELECT \* FROM "roles" WHERE name = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"role_id", "name"}))
+	mock.ExpectRollback()
+
+	result, err := repo.RegisterUser(&model.Users{Name: "test", Email: "test@example.com"})
+
+	assert.ErrorIs(t, err, repository.ErrRoleNotFound)
+	assert.Nil(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
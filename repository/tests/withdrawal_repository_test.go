@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayWithdrawalRequiresApproval(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewWithdrawalRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "withdrawals" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"withdrawal_id", "user_id", "amount", "status"}).
+			AddRow(1, uuid.New(), 20.0, model.WithdrawalPending))
+	mock.ExpectRollback()
+
+	withdrawal, err := repo.UpdateWithdrawalStatus(1, model.WithdrawalPaid, "")
+
+	assert.ErrorIs(t, err, repository.ErrInvalidWithdrawalTransition)
+	assert.Nil(t, withdrawal)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaidWithdrawalCannotFail(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := repository.NewWithdrawalRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "withdrawals" WHERE .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"withdrawal_id", "user_id", "amount", "status"}).
+			AddRow(1, uuid.New(), 20.0, model.WithdrawalPaid))
+	mock.ExpectRollback()
+
+	withdrawal, err := repo.UpdateWithdrawalStatus(1, model.WithdrawalFailed, "bank rejected")
+
+	assert.ErrorIs(t, err, repository.ErrInvalidWithdrawalTransition)
+	assert.Nil(t, withdrawal)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"errors"
+	"rent-video-game/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; all sessions of this login were revoked")
+)
+
+type ITokenRepository interface {
+	CreateRefreshToken(token *model.RefreshTokens) error
+	RotateRefreshToken(tokenHash string, next *model.RefreshTokens, now time.Time) (*model.RefreshTokens, error)
+	RevokeFamily(tokenHash string, userID uuid.UUID, now time.Time) error
+	RevokeAllByUser(userID uuid.UUID, now time.Time) error
+
+	RevokeAccessToken(token *model.RevokedTokens) error
+	IsAccessTokenRevoked(jti string) (bool, error)
+	PurgeExpired(now time.Time) (int64, error)
+}
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(token *model.RefreshTokens) error {
+	return r.db.Create(token).Error
+}
+
+// RotateRefreshToken spends the presented token and stores next in its family.
+// The row is locked so two concurrent refreshes with the same token cannot
+// both succeed; the loser sees a used token and revokes the family. A reuse
+// is reported only after that revocation commits.
+func (r *TokenRepository) RotateRefreshToken(tokenHash string, next *model.RefreshTokens, now time.Time) (*model.RefreshTokens, error) {
+	var current model.RefreshTokens
+	reused := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		if current.UsedAt != nil {
+			reused = true
+			return revokeFamily(tx, current.FamilyID, now)
+		}
+		if current.RevokedAt != nil || !current.ExpiresAt.After(now) {
+			return ErrRefreshTokenInvalid
+		}
+
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return next, nil
+}
+
+// RevokeFamily logs out the login the token belongs to. Tokens of other users
+// are ignored.
+func (r *TokenRepository) RevokeFamily(tokenHash string, userID uuid.UUID, now time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var token model.RefreshTokens
+		if err := tx.Where("token_hash = ? AND user_id = ?", tokenHash, userID).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+		return revokeFamily(tx, token.FamilyID, now)
+	})
+}
+
+// RevokeAllByUser ends every login of the user, e.g. after a password change.
+func (r *TokenRepository) RevokeAllByUser(userID uuid.UUID, now time.Time) error {
+	return revokeUserTokens(r.db, userID, now)
+}
+
+func (r *TokenRepository) RevokeAccessToken(token *model.RevokedTokens) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&model.RevokedTokens{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PurgeExpired deletes denylist entries, refresh tokens and account tokens
+// that can no longer be presented.
+func (r *TokenRepository) PurgeExpired(now time.Time) (int64, error) {
+	var purged int64
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		denylist := tx.Where("expires_at < ?", now).Delete(&model.RevokedTokens{})
+		if denylist.Error != nil {
+			return denylist.Error
+		}
+
+		refresh := tx.Where("expires_at < ?", now).Delete(&model.RefreshTokens{})
+		if refresh.Error != nil {
+			return refresh.Error
+		}
+
+		account := tx.Where("expires_at < ?", now).Delete(&model.AccountTokens{})
+		if account.Error != nil {
+			return account.Error
+		}
+
+		purged = denylist.RowsAffected + refresh.RowsAffected + account.RowsAffected
+		return nil
+	})
+	return purged, err
+}
+
+func revokeFamily(tx *gorm.DB, familyID uuid.UUID, now time.Time) error {
+	return tx.Model(&model.RefreshTokens{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func revokeUserTokens(tx *gorm.DB, userID uuid.UUID, now time.Time) error {
+	return tx.Model(&model.RefreshTokens{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error
+}
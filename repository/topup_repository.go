@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEventAlreadyProcessed = errors.New("event has already been processed")
+	ErrRefundExceedsTopup    = errors.New("refund exceeds the amount left on the top-up")
+)
+
+type ITopupRepository interface {
+	CreditTopup(event *model.PaymentEvents, userID uuid.UUID, code currency.Code, amount currency.Money) (*model.TopupHistory, error)
+	RefundTopup(event *model.PaymentEvents, refundedTotal currency.Money) (*model.TopupHistory, currency.Money, error)
+	ReserveRefund(topupHistoryID int, userID uuid.UUID, amount currency.Money) (*model.TopupHistory, currency.Money, error)
+	CancelRefund(topupHistoryID int, amount currency.Money) error
+	RecordEvent(event *model.PaymentEvents) error
+}
+
+type TopupRepository struct {
+	db *gorm.DB
+}
+
+func NewTopupRepository(db *gorm.DB) *TopupRepository {
+	return &TopupRepository{db}
+}
+
+// CreditTopup credits a settled payment to the user's wallet and writes its
+// topup history. A payment is credited once no matter how many events report
+// it: the event ID and the payment ID are both unique. A payment in another
+// currency than the wallet is refused with ErrCurrencyMismatch.
+func (r *TopupRepository) CreditTopup(event *model.PaymentEvents, userID uuid.UUID, code currency.Code, amount currency.Money) (*model.TopupHistory, error) {
+	var topupHistory model.TopupHistory
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := recordEvent(tx, event); err != nil {
+			return err
+		}
+
+		wallet, err := walletAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+		if wallet.Currency != code {
+			return fmt.Errorf("%w: %s payment for a %s wallet", ErrCurrencyMismatch, code, wallet.Currency)
+		}
+
+		topupHistory = model.TopupHistory{
+			UserID:    userID,
+			PaymentID: event.PaymentID,
+			Currency:  code,
+			Amount:    amount,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&topupHistory)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEventAlreadyProcessed
+		}
+
+		clearing, err := systemAccount(tx, model.PaymentClearingAccount, code)
+		if err != nil {
+			return err
+		}
+
+		return transfer(tx, model.TopupEntry, "payment:"+event.PaymentID, "wallet top-up", clearing, wallet, amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &topupHistory, nil
+}
+
+// RefundTopup takes back from the wallet whatever part of refundedTotal was not
+// taken back yet and returns that amount. A wallet that already spent the
+// money fails with ErrInsufficientBalance and a refund larger than the top-up
+// with ErrRefundExceedsTopup; either way the wallet is left untouched.
+func (r *TopupRepository) RefundTopup(event *model.PaymentEvents, refundedTotal currency.Money) (*model.TopupHistory, currency.Money, error) {
+	var topupHistory model.TopupHistory
+	var refund currency.Money
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := recordEvent(tx, event); err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payment_id = ?", event.PaymentID).
+			First(&topupHistory).Error; err != nil {
+			return err
+		}
+
+		refund = refundedTotal - topupHistory.RefundedAmount
+		if refund <= 0 {
+			return nil
+		}
+		if refundedTotal > topupHistory.Amount {
+			return fmt.Errorf("%w: refund of %s exceeds top-up of %s", ErrRefundExceedsTopup, refundedTotal, topupHistory.Amount)
+		}
+
+		clearing, err := systemAccount(tx, model.PaymentClearingAccount, topupHistory.Currency)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := walletAccount(tx, topupHistory.UserID)
+		if err != nil {
+			return err
+		}
+
+		if err := transfer(tx, model.RefundEntry, "payment:"+event.PaymentID, "top-up refunded", wallet, clearing, refund); err != nil {
+			return err
+		}
+
+		topupHistory.RefundedAmount = refundedTotal
+		return tx.Model(&topupHistory).Update("refunded_amount", refundedTotal).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &topupHistory, refund, nil
+}
+
+// ReserveRefund takes a user-requested refund out of the wallet before the
+// gateway is asked to pay it back, so the money cannot be spent in between. An
+// amount of 0 reserves whatever is left of the top-up; the amount actually
+// reserved is returned.
+// The refund event that follows finds refunded_amount already up to date and
+// takes nothing a second time.
+func (r *TopupRepository) ReserveRefund(topupHistoryID int, userID uuid.UUID, amount currency.Money) (*model.TopupHistory, currency.Money, error) {
+	var topupHistory model.TopupHistory
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("topup_history_id = ? AND user_id = ?", topupHistoryID, userID).
+			First(&topupHistory).Error; err != nil {
+			return err
+		}
+
+		remaining := topupHistory.Amount - topupHistory.RefundedAmount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return ErrRefundExceedsTopup
+		}
+
+		clearing, err := systemAccount(tx, model.PaymentClearingAccount, topupHistory.Currency)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := walletAccount(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := transfer(tx, model.RefundEntry, "payment:"+topupHistory.PaymentID, "top-up refund requested", wallet, clearing, amount); err != nil {
+			return err
+		}
+
+		topupHistory.RefundedAmount += amount
+		return tx.Model(&topupHistory).Update("refunded_amount", topupHistory.RefundedAmount).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &topupHistory, amount, nil
+}
+
+// CancelRefund puts a reserved refund back into the wallet after the gateway
+// refused to pay it.
+func (r *TopupRepository) CancelRefund(topupHistoryID int, amount currency.Money) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var topupHistory model.TopupHistory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("topup_history_id = ?", topupHistoryID).
+			First(&topupHistory).Error; err != nil {
+			return err
+		}
+
+		clearing, err := systemAccount(tx, model.PaymentClearingAccount, topupHistory.Currency)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := walletAccount(tx, topupHistory.UserID)
+		if err != nil {
+			return err
+		}
+
+		if err := transfer(tx, model.TopupEntry, "payment:"+topupHistory.PaymentID, "top-up refund declined", clearing, wallet, amount); err != nil {
+			return err
+		}
+
+		return tx.Model(&topupHistory).Update("refunded_amount", gorm.Expr("refunded_amount - ?", amount)).Error
+	})
+}
+
+func (r *TopupRepository) RecordEvent(event *model.PaymentEvents) error {
+	return recordEvent(r.db, event)
+}
+
+func recordEvent(tx *gorm.DB, event *model.PaymentEvents) error {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEventAlreadyProcessed
+	}
+	return nil
+}
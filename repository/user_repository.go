@@ -11,8 +11,6 @@ type IUserRepository interface {
 	RegisterUser(user *model.Users) (*model.Users, error)
 	GetUserByID(userID uuid.UUID) (*model.Users, error)
 	GetUserByEmail(email string) (*model.Users, error)
-	TopupUser(userID uuid.UUID, user *model.Users) (*model.Users, error)
-	TransactionUser(userID uuid.UUID, user *model.Users) (*model.Users, error)
 }
 
 type UserRepository struct {
@@ -23,8 +21,16 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db}
 }
 
+// RegisterUser creates the user with the renter role every account starts
+// with.
 func (r *UserRepository) RegisterUser(user *model.Users) (*model.Users, error) {
-	if err := r.db.Create(user).Error; err != nil {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return grantRole(tx, user.UserID, model.RoleRenter)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return user, nil
@@ -47,31 +53,3 @@ func (r *UserRepository) GetUserByEmail(email string) (*model.Users, error) {
 	}
 	return &user, nil
 }
-
-func (r *UserRepository) TopupUser(userID uuid.UUID, user *model.Users) (*model.Users, error) {
-	var u model.Users
-	err := r.db.Where("user_id = ?", userID).First(&u).Error
-	if err != nil {
-		return &u, err
-	}
-
-	u.Amount += user.Amount
-	if err := r.db.Save(&u).Error; err != nil {
-		return nil, err
-	}
-	return &u, nil
-}
-
-func (r *UserRepository) TransactionUser(userID uuid.UUID, user *model.Users) (*model.Users, error) {
-	var u model.Users
-	err := r.db.Where("user_id = ?", userID).First(&u).Error
-	if err != nil {
-		return &u, err
-	}
-
-	u.Amount = user.Amount
-	if err := r.db.Save(&u).Error; err != nil {
-		return nil, err
-	}
-	return &u, nil
-}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidWithdrawalTransition = errors.New("withdrawal status transition is not allowed")
+
+type IWithdrawalRepository interface {
+	CreateWithdrawal(withdrawal *model.Withdrawals) (*model.Withdrawals, error)
+	GetWithdrawalByID(withdrawalID int) (*model.Withdrawals, error)
+	GetAllWithdrawalByUser(userID uuid.UUID) ([]model.Withdrawals, error)
+	GetAllWithdrawalByStatus(status model.WithdrawalStatus) ([]model.Withdrawals, error)
+	UpdateWithdrawalStatus(withdrawalID int, status model.WithdrawalStatus, reason string) (*model.Withdrawals, error)
+}
+
+type WithdrawalRepository struct {
+	db *gorm.DB
+}
+
+func NewWithdrawalRepository(db *gorm.DB) *WithdrawalRepository {
+	return &WithdrawalRepository{db}
+}
+
+// CreateWithdrawal records the request and holds the amount out of the wallet
+// in the same transaction, so the money cannot be spent while it is paid out.
+func (r *WithdrawalRepository) CreateWithdrawal(withdrawal *model.Withdrawals) (*model.Withdrawals, error) {
+	withdrawal.Status = model.WithdrawalPending
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		wallet, err := walletAccount(tx, withdrawal.UserID)
+		if err != nil {
+			return err
+		}
+
+		withdrawal.Currency = wallet.Currency
+		if err := tx.Create(withdrawal).Error; err != nil {
+			return err
+		}
+
+		hold, err := systemAccount(tx, model.WithdrawalHoldAccount, wallet.Currency)
+		if err != nil {
+			return err
+		}
+
+		reference := fmt.Sprintf("withdrawal:%d", withdrawal.WithdrawalID)
+		return transfer(tx, model.WithdrawalHoldEntry, reference, "withdrawal requested", wallet, hold, withdrawal.Amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return withdrawal, nil
+}
+
+func (r *WithdrawalRepository) GetWithdrawalByID(withdrawalID int) (*model.Withdrawals, error) {
+	var withdrawal model.Withdrawals
+	if err := r.db.Where("withdrawal_id = ?", withdrawalID).First(&withdrawal).Error; err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+func (r *WithdrawalRepository) GetAllWithdrawalByUser(userID uuid.UUID) ([]model.Withdrawals, error) {
+	var withdrawals []model.Withdrawals
+	if err := r.db.Where("user_id = ?", userID).Order("withdrawal_id DESC").Find(&withdrawals).Error; err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+func (r *WithdrawalRepository) GetAllWithdrawalByStatus(status model.WithdrawalStatus) ([]model.Withdrawals, error) {
+	var withdrawals []model.Withdrawals
+	if err := r.db.Where("status = ?", status).Order("withdrawal_id").Find(&withdrawals).Error; err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+// UpdateWithdrawalStatus moves a withdrawal along its state machine. Paying it
+// sends the held amount out of the platform; failing it returns the amount to
+// the wallet.
+func (r *WithdrawalRepository) UpdateWithdrawalStatus(withdrawalID int, status model.WithdrawalStatus, reason string) (*model.Withdrawals, error) {
+	var withdrawal model.Withdrawals
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("withdrawal_id = ?", withdrawalID).
+			First(&withdrawal).Error; err != nil {
+			return err
+		}
+
+		if !withdrawal.Status.CanTransitionTo(status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidWithdrawalTransition, withdrawal.Status, status)
+		}
+
+		hold, err := systemAccount(tx, model.WithdrawalHoldAccount, withdrawal.Currency)
+		if err != nil {
+			return err
+		}
+
+		reference := fmt.Sprintf("withdrawal:%d", withdrawal.WithdrawalID)
+
+		switch status {
+		case model.WithdrawalPaid:
+			clearing, err := systemAccount(tx, model.PaymentClearingAccount, withdrawal.Currency)
+			if err != nil {
+				return err
+			}
+			if err := transfer(tx, model.PayoutEntry, reference, "withdrawal paid out", hold, clearing, withdrawal.Amount); err != nil {
+				return err
+			}
+		case model.WithdrawalFailed:
+			wallet, err := walletAccount(tx, withdrawal.UserID)
+			if err != nil {
+				return err
+			}
+			if err := transfer(tx, model.WithdrawalReleaseEntry, reference, "withdrawal failed", hold, wallet, withdrawal.Amount); err != nil {
+				return err
+			}
+			withdrawal.FailureReason = reason
+		}
+
+		withdrawal.Status = status
+		if status == model.WithdrawalPaid || status == model.WithdrawalFailed {
+			now := time.Now()
+			withdrawal.ProcessedAt = &now
+		}
+		return tx.Save(&withdrawal).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &withdrawal, nil
+}
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"fmt"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"rent-video-game/utils"
+	"time"
+)
+
+func ExpireUnpaidBookings(bookingUsecase *usecase.BookingUsecase, ttl time.Duration) func() error {
+	return func() error {
+		bookings, err := bookingUsecase.ExpireUnpaidBookings(ttl)
+		if err != nil {
+			return err
+		}
+
+		notifyBookings(bookings)
+		return nil
+	}
+}
+
+func MarkOverdueBookings(bookingUsecase *usecase.BookingUsecase) func() error {
+	return func() error {
+		bookings, err := bookingUsecase.MarkOverdueBookings()
+		if err != nil {
+			return err
+		}
+
+		notifyBookings(bookings)
+		return nil
+	}
+}
+
+// notifyBookings emails the renter and the lessor of every booking whose
+// status a job changed.
+func notifyBookings(bookings []model.Bookings) {
+	for _, booking := range bookings {
+		renter := booking.Users
+		lessorUser := booking.Products.Lessors.Users
+
+		go func() {
+			for _, user := range []model.Users{renter, lessorUser} {
+				if user.Email == "" {
+					continue
+				}
+
+				err := utils.SendBookingStatusNotification(user.Email, user.Name, booking.BookingID, booking.Products.Name, string(booking.Status), booking.StatusReason)
+				if err != nil {
+					fmt.Printf("failed to send booking notification: %v\n", err)
+				}
+			}
+		}()
+	}
+}
+
+// AccrueLateFees charges overdue bookings their daily late fee out of the
+// held deposit.
+func AccrueLateFees(depositUsecase *usecase.DepositUsecase) func() error {
+	return func() error {
+		deposits, err := depositUsecase.AccrueLateFees()
+		if err != nil {
+			return err
+		}
+
+		if len(deposits) > 0 {
+			fmt.Printf("accrued late fees for %d booking(s)\n", len(deposits))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,23 @@
+package scheduler
+
+import (
+	"fmt"
+	"rent-video-game/usecase"
+	"time"
+)
+
+// PurgeIdempotencyKeys forgets stored responses older than ttl. Keys left in
+// flight by a crashed request are freed the same way.
+func PurgeIdempotencyKeys(idempotencyUsecase *usecase.IdempotencyUsecase, ttl time.Duration) func() error {
+	return func() error {
+		purged, err := idempotencyUsecase.PurgeExpired(ttl)
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			fmt.Printf("purged %d idempotency key(s)\n", purged)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"fmt"
+	"rent-video-game/repository"
+	"sync"
+	"time"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func() error
+}
+
+// Scheduler runs periodic jobs in-process. Every dyno runs the same
+// scheduler; the leader lock makes sure a job runs on one of them at a time.
+type Scheduler struct {
+	schedulerRepo repository.ISchedulerRepository
+	jobs          []job
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+func NewScheduler(schedulerRepo repository.ISchedulerRepository) *Scheduler {
+	return &Scheduler{
+		schedulerRepo: schedulerRepo,
+		stop:          make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Register(name string, interval time.Duration, run func() error) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop waits for running jobs to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if _, err := s.schedulerRepo.RunExclusive(j.name, j.run); err != nil {
+				fmt.Printf("scheduler job %s failed: %v\n", j.name, err)
+			}
+		}
+	}
+}
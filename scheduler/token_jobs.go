@@ -0,0 +1,22 @@
+package scheduler
+
+import (
+	"fmt"
+	"rent-video-game/usecase"
+)
+
+// PurgeExpiredTokens drops refresh tokens and denylisted access tokens that
+// have expired and so can no longer be presented.
+func PurgeExpiredTokens(tokenUsecase *usecase.TokenUsecase) func() error {
+	return func() error {
+		purged, err := tokenUsecase.PurgeExpired()
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			fmt.Printf("purged %d expired token(s)\n", purged)
+		}
+		return nil
+	}
+}
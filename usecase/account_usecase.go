@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultEmailVerificationTTL = 48 * time.Hour
+	DefaultPasswordResetTTL     = time.Hour
+)
+
+var ErrEmailAlreadyVerified = errors.New("email is already verified")
+
+type AccountUsecase struct {
+	accountTokenRepo repository.IAccountTokenRepository
+	userRepo         repository.IUserRepository
+	verifyTTL        time.Duration
+	resetTTL         time.Duration
+	appURL           string
+}
+
+func NewAccountUsecase(accountTokenRepo repository.IAccountTokenRepository, userRepo repository.IUserRepository, verifyTTL, resetTTL time.Duration, appURL string) *AccountUsecase {
+	if verifyTTL <= 0 {
+		verifyTTL = DefaultEmailVerificationTTL
+	}
+	if resetTTL <= 0 {
+		resetTTL = DefaultPasswordResetTTL
+	}
+	return &AccountUsecase{
+		accountTokenRepo: accountTokenRepo,
+		userRepo:         userRepo,
+		verifyTTL:        verifyTTL,
+		resetTTL:         resetTTL,
+		appURL:           appURL,
+	}
+}
+
+// SendVerification mails the user a fresh verification link. Links sent
+// earlier stop working.
+func (u *AccountUsecase) SendVerification(user *model.Users) error {
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := u.issue(user.UserID, model.PurposeVerifyEmail, u.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/email/verify?token=%s", u.appURL, url.QueryEscape(token))
+	return utils.SendEmailVerification(user.Email, user.Name, link)
+}
+
+func (u *AccountUsecase) VerifyEmail(token string) error {
+	userID, jti, err := utils.VerifyAccountToken(token, model.PurposeVerifyEmail)
+	if err != nil {
+		return repository.ErrAccountTokenInvalid
+	}
+	return u.accountTokenRepo.VerifyEmail(jti, userID, time.Now())
+}
+
+// ForgotPassword mails a reset token when the email belongs to a user. An
+// unknown email is not an error, so the endpoint does not reveal who has an
+// account.
+func (u *AccountUsecase) ForgotPassword(email string) error {
+	user, err := u.userRepo.GetUserByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := u.issue(user.UserID, model.PurposeResetPassword, u.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	return utils.SendPasswordReset(user.Email, user.Name, token, u.resetTTL.String())
+}
+
+func (u *AccountUsecase) ResetPassword(token, password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+
+	userID, jti, err := utils.VerifyAccountToken(token, model.PurposeResetPassword)
+	if err != nil {
+		return repository.ErrAccountTokenInvalid
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return u.accountTokenRepo.ResetPassword(jti, userID, string(hashedPassword), time.Now())
+}
+
+func (u *AccountUsecase) issue(userID uuid.UUID, purpose model.AccountTokenPurpose, ttl time.Duration) (string, error) {
+	record := &model.AccountTokens{
+		JTI:       uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	token, err := utils.GenerateAccountToken(userID, purpose, record.JTI, record.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.accountTokenRepo.CreateAccountToken(record); err != nil {
+		return "", err
+	}
+	return token, nil
+}
@@ -0,0 +1,18 @@
+package usecase
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+)
+
+type AuditLogUsecase struct {
+	auditLogRepo repository.IAuditLogRepository
+}
+
+func NewAuditLogUsecase(auditLogRepo repository.IAuditLogRepository) *AuditLogUsecase {
+	return &AuditLogUsecase{auditLogRepo: auditLogRepo}
+}
+
+func (u *AuditLogUsecase) GetAllAuditLog(entityType string, entityID int) ([]model.AuditLogs, error) {
+	return u.auditLogRepo.GetAllAuditLog(entityType, entityID)
+}
@@ -2,21 +2,31 @@ package usecase
 
 import (
 	"errors"
+	"fmt"
+	"rent-video-game/currency"
 	"rent-video-game/model"
 	"rent-video-game/repository"
+	"rent-video-game/utils"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrInvalidBooking = errors.New("invalid booking")
+
 type BookingUsecase struct {
 	bookingRepo repository.IBookingRepository
+	rates       *currency.Rates
 }
 
-func NewBookingUsecase(bookingRepo repository.IBookingRepository) *BookingUsecase {
-	return &BookingUsecase{bookingRepo: bookingRepo}
+func NewBookingUsecase(bookingRepo repository.IBookingRepository, rates *currency.Rates) *BookingUsecase {
+	return &BookingUsecase{bookingRepo: bookingRepo, rates: rates}
 }
 
+// CreateBooking validates the booking and fixes its exchange rate: the renter
+// is charged in booking.Currency at today's rate no matter when they pay.
+// Invalid input fails with ErrInvalidBooking.
 func (u *BookingUsecase) CreateBooking(booking *model.Bookings) (*model.Bookings, error) {
 	var error []string
 
@@ -37,8 +47,41 @@ func (u *BookingUsecase) CreateBooking(booking *model.Bookings) (*model.Bookings
 	}
 
 	if len(error) > 0 {
-		return nil, errors.New(strings.Join(error, ", "))
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBooking, strings.Join(error, ", "))
+	}
+
+	start, err := utils.ParseDate(booking.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBooking, err)
+	}
+
+	end, err := utils.ParseDate(booking.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBooking, err)
+	}
+
+	now := time.Now()
+	if start.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+		return nil, fmt.Errorf("%w: start date must not be in the past", ErrInvalidBooking)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("%w: end date must not be before start date", ErrInvalidBooking)
+	}
+
+	product, err := u.bookingRepo.GetProductByID(booking.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.Currency == "" {
+		booking.Currency = product.Currency
+	}
+
+	rate, err := u.rates.Rate(product.Currency, booking.Currency)
+	if err != nil {
+		return nil, err
 	}
+	booking.ExchangeRate = currency.FormatRate(rate)
 
 	return u.bookingRepo.CreateBooking(booking)
 }
@@ -51,17 +94,92 @@ func (u *BookingUsecase) GetAllBookingByUser(userID uuid.UUID) ([]model.Bookings
 	return u.bookingRepo.GetAllBookingByUser(userID)
 }
 
-func (u *BookingUsecase) UpdateBooking(bookingID int, status model.BookingStatus, booking *model.Bookings) (*model.Bookings, error) {
+func (u *BookingUsecase) UpdateBooking(bookingID int, status model.BookingStatus, booking *model.Bookings, changedBy uuid.UUID) (*model.Bookings, error) {
 	currentBooking, err := u.bookingRepo.GetBookingByID(bookingID, booking.UserID)
 	if err != nil {
 		return nil, err
 	}
 
-	if currentBooking.Status == model.Approved {
-		return nil, errors.New("cannot update an already approved booking")
+	if !currentBooking.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", repository.ErrInvalidTransition, currentBooking.Status, status)
 	}
 
-	return u.bookingRepo.UpdateBooking(bookingID, status, booking)
+	return u.bookingRepo.UpdateBooking(bookingID, status, booking, changedBy)
+}
+
+func (u *BookingUsecase) GetBookingHistory(bookingID int) ([]model.BookingStatusHistories, error) {
+	return u.bookingRepo.GetBookingHistory(bookingID)
+}
+
+func (u *BookingUsecase) GetBookingByLessor(bookingID, lessorID int) (*model.Bookings, error) {
+	return u.bookingRepo.GetBookingByLessor(bookingID, lessorID)
+}
+
+func (u *BookingUsecase) GetAllBookingByLessor(lessorID int) ([]model.Bookings, error) {
+	return u.bookingRepo.GetAllBookingByLessor(lessorID)
+}
+
+func (u *BookingUsecase) ApproveBooking(bookingID, lessorID int, changedBy uuid.UUID, reason string) (*model.Bookings, error) {
+	return u.lessorTransition(bookingID, lessorID, model.Approved, changedBy, reason)
+}
+
+func (u *BookingUsecase) RejectBooking(bookingID, lessorID int, changedBy uuid.UUID, reason string) (*model.Bookings, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidBooking)
+	}
+
+	return u.lessorTransition(bookingID, lessorID, model.Rejected, changedBy, reason)
+}
+
+// HandOverBooking marks a paid booking as picked up by the renter.
+func (u *BookingUsecase) HandOverBooking(bookingID, lessorID int, changedBy uuid.UUID, reason string) (*model.Bookings, error) {
+	return u.lessorTransition(bookingID, lessorID, model.Active, changedBy, reason)
+}
+
+// ReturnBooking marks an active or overdue booking as returned, which frees
+// its unit for new bookings and settles the deposit. capture is the part of
+// the deposit the lessor keeps and needs a damage note.
+func (u *BookingUsecase) ReturnBooking(bookingID, lessorID int, changedBy uuid.UUID, reason string, capture currency.Money, damageNote string) (*model.Bookings, error) {
+	var error []string
+
+	if capture < 0 {
+		error = append(error, "deposit capture must not be negative")
+	}
+	if capture > 0 && strings.TrimSpace(damageNote) == "" {
+		error = append(error, "damage note is required to capture the deposit")
+	}
+
+	if len(error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBooking, strings.Join(error, ", "))
+	}
+
+	if _, err := u.bookingRepo.GetBookingByLessor(bookingID, lessorID); err != nil {
+		return nil, err
+	}
+
+	return u.bookingRepo.ReturnBooking(bookingID, changedBy, reason, capture, damageNote)
+}
+
+func (u *BookingUsecase) lessorTransition(bookingID, lessorID int, status model.BookingStatus, changedBy uuid.UUID, reason string) (*model.Bookings, error) {
+	booking, err := u.bookingRepo.GetBookingByLessor(bookingID, lessorID)
+	if err != nil {
+		return nil, err
+	}
+
+	booking.StatusReason = reason
+	return u.UpdateBooking(bookingID, status, booking, changedBy)
+}
+
+func (u *BookingUsecase) ExpireUnpaidBookings(ttl time.Duration) ([]model.Bookings, error) {
+	if ttl <= 0 {
+		return nil, errors.New("ttl must be greater than 0")
+	}
+
+	return u.bookingRepo.ExpireUnpaidBookings(time.Now().Add(-ttl), "expired: not paid within "+ttl.String())
+}
+
+func (u *BookingUsecase) MarkOverdueBookings() ([]model.Bookings, error) {
+	return u.bookingRepo.MarkOverdueBookings("not returned by end date")
 }
 
 func (u *BookingUsecase) IsUserProductOwner(userID uuid.UUID, productID int) (bool, error) {
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"errors"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Lessors that never configured a policy get these terms.
+const (
+	DefaultFullRefundDays       = 7
+	DefaultPartialRefundPercent = 50
+)
+
+type CancellationUsecase struct {
+	cancellationRepo repository.ICancellationRepository
+	bookingRepo      repository.IBookingRepository
+}
+
+func NewCancellationUsecase(cancellationRepo repository.ICancellationRepository, bookingRepo repository.IBookingRepository) *CancellationUsecase {
+	return &CancellationUsecase{cancellationRepo: cancellationRepo, bookingRepo: bookingRepo}
+}
+
+func (u *CancellationUsecase) GetPolicyByLessor(lessorID int) (*model.CancellationPolicies, error) {
+	policy, err := u.cancellationRepo.GetPolicyByLessor(lessorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &model.CancellationPolicies{
+			LessorID:             lessorID,
+			FullRefundDays:       DefaultFullRefundDays,
+			PartialRefundPercent: DefaultPartialRefundPercent,
+		}, nil
+	}
+	return policy, err
+}
+
+func (u *CancellationUsecase) SavePolicy(policy *model.CancellationPolicies) (*model.CancellationPolicies, error) {
+	var error []string
+
+	if policy.LessorID <= 0 {
+		error = append(error, "lessor ID is required")
+	}
+	if policy.FullRefundDays < 0 {
+		error = append(error, "full refund days must be 0 or greater")
+	}
+	if policy.PartialRefundPercent < 0 || policy.PartialRefundPercent > 100 {
+		error = append(error, "partial refund percent must be between 0 and 100")
+	}
+
+	if len(error) > 0 {
+		return nil, errors.New(strings.Join(error, ", "))
+	}
+
+	return u.cancellationRepo.SavePolicy(policy)
+}
+
+// CancelBooking cancels the renter's booking and refunds what the lessor's
+// policy allows for the time left before the start date.
+func (u *CancellationUsecase) CancelBooking(bookingID int, renterID uuid.UUID, reason string) (*model.CancellationResult, float64, error) {
+	booking, err := u.bookingRepo.GetBookingByID(bookingID, renterID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	policy, err := u.GetPolicyByLessor(booking.Products.LessorID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	percent, err := RefundPercent(policy, booking, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := u.cancellationRepo.CancelBooking(bookingID, renterID, reason, percent)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, percent, nil
+}
+
+// RefundPercent applies policy to a booking cancelled at now. Days are counted
+// in whole calendar days up to the start date.
+func RefundPercent(policy *model.CancellationPolicies, booking *model.Bookings, now time.Time) (float64, error) {
+	switch booking.Status {
+	case model.Active, model.Overdue, model.Returned:
+		return 0, nil
+	}
+
+	start, err := utils.ParseDate(booking.StartDate)
+	if err != nil {
+		return 0, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	daysBeforeStart := int(start.Sub(today).Hours() / 24)
+
+	if daysBeforeStart >= policy.FullRefundDays {
+		return 100, nil
+	}
+	return policy.PartialRefundPercent, nil
+}
@@ -1,8 +1,12 @@
 package usecase
 
 import (
+	"errors"
 	"rent-video-game/model"
 	"rent-video-game/repository"
+	"strings"
+
+	"github.com/google/uuid"
 )
 
 type ConsoleUsecase struct {
@@ -20,3 +24,66 @@ func (u *ConsoleUsecase) GetAllConsole() ([]model.Consoles, error) {
 func (u *ConsoleUsecase) GetConsoleID(consoleID int) (*model.Consoles, error) {
 	return u.consoleRepo.GetConsoleID(consoleID)
 }
+
+func (u *ConsoleUsecase) CreateConsole(name string, actorID uuid.UUID) (*model.Consoles, error) {
+	name = strings.TrimSpace(name)
+	if err := validateConsoleChange(name, actorID); err != nil {
+		return nil, err
+	}
+	return u.consoleRepo.CreateConsole(&model.Consoles{Name: name}, actorID)
+}
+
+func (u *ConsoleUsecase) RenameConsole(consoleID int, name string, actorID uuid.UUID) (*model.Consoles, error) {
+	name = strings.TrimSpace(name)
+	if err := validateConsoleChange(name, actorID); err != nil {
+		return nil, err
+	}
+	return u.consoleRepo.RenameConsole(consoleID, name, actorID)
+}
+
+// MergeConsole folds the source console into the target.
+func (u *ConsoleUsecase) MergeConsole(sourceID, targetID int, actorID uuid.UUID) (*model.ConsoleMergeResult, error) {
+	var error []string
+
+	if sourceID <= 0 || targetID <= 0 {
+		error = append(error, "source and target console ID are required")
+	}
+	if sourceID == targetID {
+		error = append(error, "cannot merge a console into itself")
+	}
+	if actorID == uuid.Nil {
+		error = append(error, "actor ID is required")
+	}
+
+	if len(error) > 0 {
+		return nil, errors.New(strings.Join(error, ", "))
+	}
+
+	return u.consoleRepo.MergeConsole(sourceID, targetID, actorID)
+}
+
+func (u *ConsoleUsecase) DeleteConsole(consoleID int, actorID uuid.UUID) (*model.Consoles, error) {
+	if actorID == uuid.Nil {
+		return nil, errors.New("actor ID is required")
+	}
+	return u.consoleRepo.DeleteConsole(consoleID, actorID)
+}
+
+func validateConsoleChange(name string, actorID uuid.UUID) error {
+	var error []string
+
+	if name == "" {
+		error = append(error, "name is required")
+	}
+	if len(name) > 255 {
+		error = append(error, "name must be at most 255 characters")
+	}
+	if actorID == uuid.Nil {
+		error = append(error, "actor ID is required")
+	}
+
+	if len(error) > 0 {
+		return errors.New(strings.Join(error, ", "))
+	}
+	return nil
+}
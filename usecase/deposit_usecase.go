@@ -0,0 +1,25 @@
+package usecase
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"time"
+)
+
+type DepositUsecase struct {
+	depositRepo repository.IDepositRepository
+}
+
+func NewDepositUsecase(depositRepo repository.IDepositRepository) *DepositUsecase {
+	return &DepositUsecase{depositRepo: depositRepo}
+}
+
+func (u *DepositUsecase) GetDepositByBooking(bookingID int) (*model.Deposits, error) {
+	return u.depositRepo.GetDepositByBooking(bookingID)
+}
+
+// AccrueLateFees charges overdue bookings for every day past their end date up
+// to today.
+func (u *DepositUsecase) AccrueLateFees() ([]model.Deposits, error) {
+	return u.depositRepo.AccrueLateFees(time.Now())
+}
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"errors"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxIdempotencyKeyLength = 255
+	DefaultIdempotencyLease = 5 * time.Minute
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+type IdempotencyUsecase struct {
+	idempotencyRepo repository.IIdempotencyRepository
+	lease           time.Duration
+}
+
+// NewIdempotencyUsecase builds the usecase. A reserved key that is still
+// unanswered after lease is taken to belong to a crashed request, and the next
+// retry with the same body takes it over.
+func NewIdempotencyUsecase(idempotencyRepo repository.IIdempotencyRepository, lease time.Duration) *IdempotencyUsecase {
+	if lease <= 0 {
+		lease = DefaultIdempotencyLease
+	}
+	return &IdempotencyUsecase{idempotencyRepo: idempotencyRepo, lease: lease}
+}
+
+// Begin claims key for the request. It returns the stored record and true when
+// the request was already answered and the response should be replayed, or the
+// fresh record and false when the caller should run the request and Complete it.
+func (u *IdempotencyUsecase) Begin(userID uuid.UUID, key, route, requestHash string) (*model.IdempotencyKeys, bool, error) {
+	var error []string
+
+	if userID == uuid.Nil {
+		error = append(error, "user ID is required")
+	}
+	if strings.TrimSpace(key) == "" {
+		error = append(error, "idempotency key is required")
+	}
+	if len(key) > MaxIdempotencyKeyLength {
+		error = append(error, "idempotency key must be at most 255 characters")
+	}
+
+	if len(error) > 0 {
+		return nil, false, errors.New(strings.Join(error, ", "))
+	}
+
+	record, created, err := u.idempotencyRepo.Reserve(&model.IdempotencyKeys{
+		UserID:      userID,
+		Key:         key,
+		Route:       route,
+		RequestHash: requestHash,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		return record, false, nil
+	}
+
+	if record.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyKeyReused
+	}
+	if record.Completed() {
+		return record, true, nil
+	}
+
+	if record.CreatedAt.After(time.Now().Add(-u.lease)) {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+	takenOver, err := u.idempotencyRepo.TakeOver(record.IdempotencyKeyID, time.Now().Add(-u.lease))
+	if err != nil {
+		return nil, false, err
+	}
+	if !takenOver {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+	return record, false, nil
+}
+
+func (u *IdempotencyUsecase) Complete(record *model.IdempotencyKeys, statusCode int, contentType string, body []byte) error {
+	return u.idempotencyRepo.Complete(record.IdempotencyKeyID, statusCode, contentType, body)
+}
+
+func (u *IdempotencyUsecase) Release(record *model.IdempotencyKeys) error {
+	return u.idempotencyRepo.Release(record.IdempotencyKeyID)
+}
+
+func (u *IdempotencyUsecase) PurgeExpired(ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return 0, errors.New("ttl must be greater than 0")
+	}
+
+	return u.idempotencyRepo.DeleteExpired(time.Now().Add(-ttl))
+}
@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/repository"
+
+	"github.com/google/uuid"
+)
+
+type LedgerUsecase struct {
+	ledgerRepo repository.ILedgerRepository
+}
+
+func NewLedgerUsecase(ledgerRepo repository.ILedgerRepository) *LedgerUsecase {
+	return &LedgerUsecase{ledgerRepo: ledgerRepo}
+}
+
+func (u *LedgerUsecase) OpenAccounts() error {
+	return u.ledgerRepo.OpenAccounts()
+}
+
+func (u *LedgerUsecase) GetStatementByUser(userID uuid.UUID) ([]model.LedgerStatementData, error) {
+	return u.ledgerRepo.GetStatementByUser(userID)
+}
+
+func (u *LedgerUsecase) Reconcile() ([]model.LedgerReconciliationData, error) {
+	return u.ledgerRepo.Reconcile()
+}
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPayment = errors.New("invalid payment")
+
+type PaymentUsecase struct {
+	paymentRepo    repository.IPaymentRepository
+	pricingUsecase *PricingUsecase
+}
+
+func NewPaymentUsecase(paymentRepo repository.IPaymentRepository, pricingUsecase *PricingUsecase) *PaymentUsecase {
+	return &PaymentUsecase{paymentRepo: paymentRepo, pricingUsecase: pricingUsecase}
+}
+
+// PayBooking charges the server-side quote for the booking. The quote is
+// taken inside the payment transaction, from the product price at that time.
+// A client amount is optional and only used as a guard: if present it must
+// equal the quoted rent in the booking currency.
+func (u *PaymentUsecase) PayBooking(bookingID int, renterID uuid.UUID, clientAmount currency.Money) (*model.PaymentResult, error) {
+	var error []string
+
+	if bookingID <= 0 {
+		error = append(error, "booking ID is required")
+	}
+	if renterID == uuid.Nil {
+		error = append(error, "user ID is required")
+	}
+	if clientAmount < 0 {
+		error = append(error, "amount must not be negative")
+	}
+
+	if len(error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayment, strings.Join(error, ", "))
+	}
+
+	return u.paymentRepo.PayBooking(bookingID, renterID, u.quoteBooking(clientAmount))
+}
+
+// quoteBooking prices the booking and checks the client amount against it.
+func (u *PaymentUsecase) quoteBooking(clientAmount currency.Money) repository.QuoteFunc {
+	return func(booking *model.Bookings, product *model.Products) (*model.QuoteData, error) {
+		quote, err := u.pricingUsecase.Quote(booking, product)
+		if err != nil {
+			return nil, err
+		}
+
+		if clientAmount != 0 && clientAmount != quote.Amount {
+			return nil, ErrAmountMismatch
+		}
+		return quote, nil
+	}
+}
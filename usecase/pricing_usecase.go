@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"errors"
+	"math/big"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DaysPerMonth         = 30
+	DefaultMinRentalDays = 1
+)
+
+var ErrAmountMismatch = errors.New("amount does not match the booking quote")
+
+type PricingUsecase struct {
+	bookingRepo   repository.IBookingRepository
+	minRentalDays int
+}
+
+func NewPricingUsecase(bookingRepo repository.IBookingRepository, minRentalDays int) *PricingUsecase {
+	if minRentalDays <= 0 {
+		minRentalDays = DefaultMinRentalDays
+	}
+	return &PricingUsecase{bookingRepo: bookingRepo, minRentalDays: minRentalDays}
+}
+
+func (u *PricingUsecase) QuoteBooking(bookingID int, userID uuid.UUID) (*model.QuoteData, error) {
+	booking, err := u.bookingRepo.GetBookingByID(bookingID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := u.bookingRepo.GetProductByID(booking.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.Quote(booking, product)
+}
+
+// Quote prices a booking from the product's monthly rate. Both dates are
+// inclusive; whole 30-day months are charged at the monthly rate and the
+// remaining days pro rata, rounded up to the next minor unit. Rentals shorter
+// than the minimum period are billed as the minimum period. The rent and
+// deposit are then converted into the booking currency at the booking's rate.
+func (u *PricingUsecase) Quote(booking *model.Bookings, product *model.Products) (*model.QuoteData, error) {
+	start, err := utils.ParseDate(booking.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := utils.ParseDate(booking.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if end.Before(start) {
+		return nil, errors.New("end date must not be before start date")
+	}
+
+	rentalDays := int(end.Sub(start).Hours()/24) + 1
+	billedDays := max(rentalDays, u.minRentalDays)
+	months := billedDays / DaysPerMonth
+	days := billedDays % DaysPerMonth
+
+	monthly := product.RentalCostPerMonth
+	productAmount := currency.Money(months)*monthly + (currency.Money(days)*monthly+DaysPerMonth-1)/DaysPerMonth
+
+	bookingCurrency, rate := booking.Currency, big.NewRat(1, 1)
+	if bookingCurrency == "" {
+		bookingCurrency = product.Currency
+	} else if bookingCurrency != product.Currency {
+		rate, err = currency.ParseRate(booking.ExchangeRate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	amount := productAmount.Mul(rate)
+	deposit := product.DepositAmount.Mul(rate)
+
+	return &model.QuoteData{
+		BookingID:          booking.BookingID,
+		ProductID:          product.ProductID,
+		StartDate:          start.Format(utils.DateLayout),
+		EndDate:            end.Format(utils.DateLayout),
+		RentalDays:         rentalDays,
+		BilledDays:         billedDays,
+		BilledMonths:       months,
+		ProductCurrency:    product.Currency,
+		RentalCostPerMonth: product.RentalCostPerMonth,
+		ProductAmount:      productAmount,
+		ExchangeRate:       currency.FormatRate(rate),
+		Currency:           bookingCurrency,
+		Amount:             amount,
+		DepositAmount:      deposit,
+		TotalDue:           amount + deposit,
+	}, nil
+}
@@ -1,18 +1,47 @@
 package usecase
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"rent-video-game/currency"
 	"rent-video-game/model"
 	"rent-video-game/repository"
+	"rent-video-game/utils"
+	"slices"
 	"strings"
 )
 
+const (
+	MaxAvailabilityDays    = 366
+	DefaultProductPageSize = 20
+	MaxProductPageSize     = 100
+)
+
+const (
+	MaxSearchTerms       = 8
+	MaxSearchQueryLength = 100
+)
+
+var (
+	ErrInvalidProductFilter = errors.New("invalid product search")
+	ErrInvalidDateRange     = errors.New("invalid date range")
+)
+
+// searchTerms picks the words out of a search query. Anything else, such as
+// tsquery operators, is dropped.
+var searchTerms = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
 type ProductUsecase struct {
 	productRepo repository.IProductRepository
+	rates       *currency.Rates
 }
 
-func NewProductUsecase(productRepo repository.IProductRepository) *ProductUsecase {
-	return &ProductUsecase{productRepo: productRepo}
+func NewProductUsecase(productRepo repository.IProductRepository, rates *currency.Rates) *ProductUsecase {
+	return &ProductUsecase{productRepo: productRepo, rates: rates}
 }
 
 func (u *ProductUsecase) RegisterProduct(product *model.Products) (*model.Products, error) {
@@ -30,6 +59,12 @@ func (u *ProductUsecase) RegisterProduct(product *model.Products) (*model.Produc
 	if product.RentalCostPerMonth <= 0 {
 		error = append(error, "rental cost per month must be greater than 0")
 	}
+	if product.DepositAmount < 0 {
+		error = append(error, "deposit amount must be 0 or greater")
+	}
+	if product.LateFeePerDay < 0 {
+		error = append(error, "late fee per day must be 0 or greater")
+	}
 	if product.StockAvailability < 0 {
 		error = append(error, "stock availability must be 0 or greater")
 	}
@@ -64,6 +99,12 @@ func (u *ProductUsecase) UpdateProduct(productID int, product *model.Products) (
 	if product.RentalCostPerMonth <= 0 {
 		error = append(error, "rental cost per month must be greater than 0")
 	}
+	if product.DepositAmount < 0 {
+		error = append(error, "deposit amount must be 0 or greater")
+	}
+	if product.LateFeePerDay < 0 {
+		error = append(error, "late fee per day must be 0 or greater")
+	}
 	if product.StockAvailability < 0 {
 		error = append(error, "stock availability must be 0 or greater")
 	}
@@ -83,14 +124,191 @@ func (u *ProductUsecase) GetLessorByProductID(productID int) (*model.Lessors, er
 	return u.productRepo.GetLessorByProductID(productID)
 }
 
-func (u *ProductUsecase) GetAllProducts() ([]model.Products, error) {
-	return u.productRepo.GetAllProducts()
+func (u *ProductUsecase) GetPublicProductByID(productID int) (*model.Products, error) {
+	return u.productRepo.GetPublicProductByID(productID)
+}
+
+// SearchProducts returns a page of the public catalogue. cursor is the
+// NextCursor of the previous page, or empty for the first one; it only
+// continues a search with the same sort and currency.
+func (u *ProductUsecase) SearchProducts(filter *model.ProductFilter, cursor string) (*model.ProductPage, error) {
+	var error []string
+
+	if filter.Sort == "" {
+		filter.Sort = model.SortNewest
+	}
+	if !slices.Contains(model.ProductSorts, filter.Sort) {
+		error = append(error, fmt.Sprintf("sort must be one of %v", model.ProductSorts))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultProductPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > MaxProductPageSize {
+		error = append(error, fmt.Sprintf("limit must be between 1 and %d", MaxProductPageSize))
+	}
+	if filter.ConsoleID < 0 {
+		error = append(error, "console_id must be positive")
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		error = append(error, "min_price must not be greater than max_price")
+	}
+	if filter.MinStars < 0 || filter.MinStars > 5 {
+		error = append(error, "min_stars must be between 0 and 5")
+	}
+	if filter.Currency != "" && !filter.Currency.Valid() {
+		error = append(error, "currency is not supported")
+	}
+
+	if len(error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidProductFilter, strings.Join(error, ", "))
+	}
+
+	pricing := filter.MinPrice != nil || filter.MaxPrice != nil ||
+		filter.Sort == model.SortPriceAsc || filter.Sort == model.SortPriceDesc
+	if pricing {
+		if filter.Currency == "" {
+			filter.Currency = currency.Default
+		}
+		filter.PriceRates = u.priceRates(filter.Currency)
+	}
+
+	if cursor != "" {
+		after, err := decodeProductCursor(cursor)
+		if err != nil || after.Sort != filter.Sort || (pricing && after.Currency != filter.Currency) {
+			return nil, fmt.Errorf("%w: cursor does not belong to this search", ErrInvalidProductFilter)
+		}
+		filter.After = after
+	}
+
+	listings, err := u.productRepo.SearchProducts(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.ProductPage{Products: listings}
+	if len(listings) > filter.Limit {
+		page.Products = listings[:filter.Limit]
+		last := page.Products[len(page.Products)-1]
+
+		next := model.ProductCursor{Sort: filter.Sort, Key: last.SortKey, ProductID: last.ProductID}
+		if pricing {
+			next.Currency = filter.Currency
+		}
+		page.NextCursor = encodeProductCursor(next)
+	}
+	return page, nil
 }
 
-func (u *ProductUsecase) IncrementStockAvailability(productID int) error {
-	return u.productRepo.IncrementStockAvailability(productID)
+// SearchProductsByText searches names, descriptions and console names. When
+// no product contains every word it falls back to a fuzzy match on the name,
+// and reports which kind of match the results are. Highlights are HTML-escaped
+// apart from their <mark> tags.
+func (u *ProductUsecase) SearchProductsByText(query string, limit int) ([]model.ProductSearchResult, model.ProductMatch, error) {
+	var error []string
+
+	query = strings.TrimSpace(query)
+	terms := searchTerms.FindAllString(strings.ToLower(query), MaxSearchTerms)
+	if len(terms) == 0 {
+		error = append(error, "q must contain a word")
+	}
+	if len(query) > MaxSearchQueryLength {
+		error = append(error, fmt.Sprintf("q must be at most %d characters", MaxSearchQueryLength))
+	}
+	if limit == 0 {
+		limit = DefaultProductPageSize
+	}
+	if limit < 0 || limit > MaxProductPageSize {
+		error = append(error, fmt.Sprintf("limit must be between 1 and %d", MaxProductPageSize))
+	}
+
+	if len(error) > 0 {
+		return nil, "", fmt.Errorf("%w: %s", ErrInvalidProductFilter, strings.Join(error, ", "))
+	}
+
+	match := model.MatchFullText
+	results, err := u.productRepo.SearchProductsFullText(terms, limit)
+	if err == nil && len(results) == 0 {
+		match = model.MatchFuzzy
+		results, err = u.productRepo.SearchProductsFuzzy(strings.Join(terms, " "), limit)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	for i := range results {
+		results[i].NameHighlight = escapeHighlight(results[i].NameHighlight)
+		results[i].Snippet = escapeHighlight(results[i].Snippet)
+	}
+	return results, match, nil
+}
+
+// escapeHighlight escapes the product text, which lessors write, while
+// keeping the <mark> tags the database put around matched words.
+func escapeHighlight(s string) string {
+	return strings.NewReplacer("&lt;mark&gt;", "<mark>", "&lt;/mark&gt;", "</mark>").Replace(html.EscapeString(s))
+}
+
+// priceRates converts every product currency into to. Currencies without a
+// rate are left out, and their products drop out of price searches.
+func (u *ProductUsecase) priceRates(to currency.Code) map[currency.Code]string {
+	rates := make(map[currency.Code]string)
+	for _, code := range currency.Supported() {
+		if code == to {
+			rates[code] = "1"
+			continue
+		}
+		if u.rates == nil {
+			continue
+		}
+		rate, err := u.rates.Rate(code, to)
+		if err != nil {
+			continue
+		}
+		rates[code] = currency.FormatRate(rate)
+	}
+	return rates
+}
+
+func encodeProductCursor(cursor model.ProductCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(value string) (*model.ProductCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor model.ProductCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func (u *ProductUsecase) GetAvailability(productID int, from, to string) ([]model.AvailabilityData, error) {
+	fromDate, err := utils.ParseDate(from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: from: %v", ErrInvalidDateRange, err)
+	}
+
+	toDate, err := utils.ParseDate(to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: to: %v", ErrInvalidDateRange, err)
+	}
+
+	if toDate.Before(fromDate) {
+		return nil, fmt.Errorf("%w: to must not be before from", ErrInvalidDateRange)
+	}
+
+	if toDate.Sub(fromDate).Hours()/24 >= MaxAvailabilityDays {
+		return nil, fmt.Errorf("%w: availability range must be shorter than %d days", ErrInvalidDateRange, MaxAvailabilityDays)
+	}
+
+	return u.productRepo.GetAvailability(productID, fromDate.Format(utils.DateLayout), toDate.Format(utils.DateLayout))
 }
 
-func (u *ProductUsecase) DecrementStockAvailability(productID int) error {
-	return u.productRepo.DecrementStockAvailability(productID)
+func (u *ProductUsecase) BackfillUnits() error {
+	return u.productRepo.BackfillUnits()
 }
@@ -1,50 +1,206 @@
 package usecase
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"rent-video-game/model"
 	"rent-video-game/repository"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const (
+	DefaultRatingPageSize   = 20
+	MaxRatingPageSize       = 100
+	DefaultRatingEditWindow = 7 * 24 * time.Hour
+	MaxReplyLength          = 2000
+)
+
+var (
+	ErrInvalidRating          = errors.New("invalid rating")
+	ErrInvalidRatingPage      = errors.New("invalid rating page")
+	ErrRatingNotRented        = errors.New("you can only rate a product you have rented")
+	ErrRatingNotAuthor        = errors.New("you can only change your own rating")
+	ErrRatingEditWindowClosed = errors.New("rating can no longer be changed")
+)
+
 type RatingUsecase struct {
 	ratingRepo repository.IRatingRepository
+	editWindow time.Duration
 }
 
-func NewRatingUsecase(ratingRepo repository.IRatingRepository) *RatingUsecase {
-	return &RatingUsecase{ratingRepo: ratingRepo}
+// NewRatingUsecase builds the usecase. Authors may edit or delete a rating
+// for editWindow after posting it.
+func NewRatingUsecase(ratingRepo repository.IRatingRepository, editWindow time.Duration) *RatingUsecase {
+	if editWindow <= 0 {
+		editWindow = DefaultRatingEditWindow
+	}
+	return &RatingUsecase{ratingRepo: ratingRepo, editWindow: editWindow}
 }
 
+// CreateRating posts a rating for a product the user has rented.
 func (u *RatingUsecase) CreateRating(rating *model.Ratings) (*model.Ratings, error) {
+	error := validateRating(rating.Review, rating.Stars)
+	if rating.ProductID <= 0 {
+		error = append([]string{"product ID is required"}, error...)
+	}
+
+	if len(error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRating, strings.Join(error, ", "))
+	}
+
+	rented, err := u.ratingRepo.HasRentedProduct(rating.UserID, rating.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if !rented {
+		return nil, ErrRatingNotRented
+	}
+
+	return u.ratingRepo.CreateRating(rating)
+}
+
+// UpdateRating changes the review and stars of the user's own rating while
+// the edit window is open.
+func (u *RatingUsecase) UpdateRating(ratingID int, userID uuid.UUID, review string, stars float64) (*model.Ratings, error) {
+	if error := validateRating(review, stars); len(error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRating, strings.Join(error, ", "))
+	}
+
+	rating, err := u.editableRating(ratingID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rating.Review = review
+	rating.Stars = stars
+	return u.ratingRepo.UpdateRating(rating)
+}
+
+// DeleteRating removes the user's own rating while the edit window is open.
+func (u *RatingUsecase) DeleteRating(ratingID int, userID uuid.UUID) error {
+	rating, err := u.editableRating(ratingID, userID)
+	if err != nil {
+		return err
+	}
+	return u.ratingRepo.DeleteRating(rating)
+}
+
+// ReplyToRating posts the lessor's reply to a rating of one of their products.
+func (u *RatingUsecase) ReplyToRating(ratingID, lessorID int, reply string) (*model.Ratings, error) {
+	reply = strings.TrimSpace(reply)
+
 	var error []string
+	if reply == "" {
+		error = append(error, "reply is required")
+	}
+	if len(reply) > MaxReplyLength {
+		error = append(error, fmt.Sprintf("reply must be at most %d characters", MaxReplyLength))
+	}
+
+	if len(error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRating, strings.Join(error, ", "))
+	}
+
+	return u.ratingRepo.ReplyToRating(ratingID, lessorID, reply, time.Now())
+}
 
-	if rating.ProductID < 0 {
-		error = append(error, "product ID is required")
+func (u *RatingUsecase) editableRating(ratingID int, userID uuid.UUID) (*model.Ratings, error) {
+	rating, err := u.ratingRepo.GetRatingByID(ratingID)
+	if err != nil {
+		return nil, err
 	}
-	if rating.Review == "" {
+	if rating.UserID != userID {
+		return nil, ErrRatingNotAuthor
+	}
+	if time.Since(rating.CreatedAt) > u.editWindow {
+		return nil, ErrRatingEditWindowClosed
+	}
+	return rating, nil
+}
+
+// validateRating checks a review and its stars, which go from 1 to 5 in half
+// steps.
+func validateRating(review string, stars float64) []string {
+	var error []string
+
+	if strings.TrimSpace(review) == "" {
 		error = append(error, "review is required")
 	}
-	if rating.Stars <= 0 {
-		error = append(error, "stars must be greater than 0")
+	if stars < 1 || stars > 5 || stars*2 != math.Trunc(stars*2) {
+		error = append(error, "stars must be between 1 and 5 in steps of 0.5")
 	}
 
-	if len(error) > 0 {
-		return nil, errors.New(strings.Join(error, ", "))
+	return error
+}
+
+// GetRatingsByProduct returns a page of the product's ratings, newest first.
+// cursor is the NextCursor of the previous page, or empty for the first one.
+func (u *RatingUsecase) GetRatingsByProduct(productID, limit int, cursor string) (*model.RatingPage, error) {
+	if limit == 0 {
+		limit = DefaultRatingPageSize
+	}
+	if limit < 0 || limit > MaxRatingPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidRatingPage, MaxRatingPageSize)
 	}
 
-	return u.ratingRepo.CreateRating(rating)
+	beforeID := 0
+	if cursor != "" {
+		after, err := decodeRatingCursor(cursor)
+		if err != nil || after.RatingID <= 0 {
+			return nil, fmt.Errorf("%w: invalid cursor", ErrInvalidRatingPage)
+		}
+		beforeID = after.RatingID
+	}
+
+	ratings, err := u.ratingRepo.GetRatingsByProduct(productID, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.RatingPage{Ratings: ratings}
+	if len(ratings) > limit {
+		page.Ratings = ratings[:limit]
+		page.NextCursor = encodeRatingCursor(model.RatingCursor{RatingID: page.Ratings[limit-1].RatingID})
+	}
+	return page, nil
+}
+
+func (u *RatingUsecase) GetRatingStatsByProduct(productID int) (*model.ProductRatingStats, error) {
+	return u.ratingRepo.GetRatingStatsByProduct(productID)
 }
 
-func (u *RatingUsecase) GetAllRatingByProduct(productID int) ([]model.Ratings, error) {
-	return u.ratingRepo.GetAllRatingByProduct(productID)
+func (u *RatingUsecase) GetRatingSummaryByLessor(lessorID int) (*model.RatingSummaryData, error) {
+	return u.ratingRepo.GetRatingSummaryByLessor(lessorID)
 }
 
-func (u *RatingUsecase) GetAverageRatingByProduct(productID int) (float64, error) {
-	return u.ratingRepo.GetAverageRatingByProduct(productID)
+func (u *RatingUsecase) BackfillRatingStats() error {
+	return u.ratingRepo.BackfillRatingStats()
 }
 
 func (u *RatingUsecase) GetRatingByUserAndProduct(userID uuid.UUID, productID int) (*model.Ratings, error) {
 	return u.ratingRepo.GetRatingByUserAndProduct(userID, productID)
 }
+
+func encodeRatingCursor(cursor model.RatingCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeRatingCursor(value string) (*model.RatingCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor model.RatingCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
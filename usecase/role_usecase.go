@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"errors"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type RoleUsecase struct {
+	roleRepo repository.IRoleRepository
+}
+
+func NewRoleUsecase(roleRepo repository.IRoleRepository) *RoleUsecase {
+	return &RoleUsecase{roleRepo: roleRepo}
+}
+
+func (u *RoleUsecase) SeedRoles() error {
+	return u.roleRepo.SeedRoles(model.DefaultRolePermissions)
+}
+
+func (u *RoleUsecase) GetAllRoles() ([]model.Roles, error) {
+	return u.roleRepo.GetAllRoles()
+}
+
+// GetAccess flattens the user's roles into the role and permission names a
+// token carries.
+func (u *RoleUsecase) GetAccess(userID uuid.UUID) (*model.Access, error) {
+	roles, err := u.roleRepo.GetRolesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &model.Access{Roles: []model.RoleName{}, Permissions: []model.Permission{}}
+	seen := map[model.Permission]bool{}
+	for _, role := range roles {
+		access.Roles = append(access.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !seen[permission.Name] {
+				seen[permission.Name] = true
+				access.Permissions = append(access.Permissions, permission.Name)
+			}
+		}
+	}
+	sort.Slice(access.Permissions, func(i, j int) bool { return access.Permissions[i] < access.Permissions[j] })
+
+	return access, nil
+}
+
+func (u *RoleUsecase) AssignRole(userID uuid.UUID, role model.RoleName) error {
+	if err := validateRole(userID, role); err != nil {
+		return err
+	}
+	return u.roleRepo.AssignRole(userID, role)
+}
+
+func (u *RoleUsecase) RevokeRole(userID uuid.UUID, role model.RoleName) error {
+	if err := validateRole(userID, role); err != nil {
+		return err
+	}
+	return u.roleRepo.RevokeRole(userID, role)
+}
+
+func validateRole(userID uuid.UUID, role model.RoleName) error {
+	var error []string
+
+	if userID == uuid.Nil {
+		error = append(error, "user ID is required")
+	}
+	if _, ok := model.DefaultRolePermissions[role]; !ok {
+		error = append(error, "role is not supported")
+	}
+
+	if len(error) > 0 {
+		return errors.New(strings.Join(error, ", "))
+	}
+	return nil
+}
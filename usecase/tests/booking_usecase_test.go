@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"rent-video-game/mocks"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/usecase"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectBookingRequiresReason(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := usecase.NewBookingUsecase(mocks.NewMockIBookingRepository(ctrl), nil)
+
+	for _, reason := range []string{"", "   "} {
+		booking, err := u.RejectBooking(1, 3, uuid.New(), reason)
+
+		assert.ErrorIs(t, err, usecase.ErrInvalidBooking)
+		assert.EqualError(t, err, "invalid booking: reason is required")
+		assert.Nil(t, booking)
+	}
+}
+
+func TestReturnBookingValidatesCapture(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := usecase.NewBookingUsecase(mocks.NewMockIBookingRepository(ctrl), nil)
+
+	_, err := u.ReturnBooking(1, 3, uuid.New(), "", -1, "")
+	assert.ErrorIs(t, err, usecase.ErrInvalidBooking)
+
+	_, err = u.ReturnBooking(1, 3, uuid.New(), "", 500, " ")
+	assert.ErrorIs(t, err, usecase.ErrInvalidBooking)
+	assert.EqualError(t, err, "invalid booking: damage note is required to capture the deposit")
+}
+
+func TestApproveRejectedBooking(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockIBookingRepository(ctrl)
+	u := usecase.NewBookingUsecase(repo, nil)
+
+	renterID := uuid.New()
+	rejected := &model.Bookings{BookingID: 1, UserID: renterID, Status: model.Rejected}
+	repo.EXPECT().GetBookingByLessor(1, 3).Return(rejected, nil)
+	repo.EXPECT().GetBookingByID(1, renterID).Return(rejected, nil)
+
+	booking, err := u.ApproveBooking(1, 3, uuid.New(), "")
+
+	assert.ErrorIs(t, err, repository.ErrInvalidTransition)
+	assert.Nil(t, booking)
+}
+
+func TestRejectPendingBooking(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockIBookingRepository(ctrl)
+	u := usecase.NewBookingUsecase(repo, nil)
+
+	renterID := uuid.New()
+	lessorUserID := uuid.New()
+	pending := &model.Bookings{BookingID: 1, UserID: renterID, Status: model.Pending}
+	repo.EXPECT().GetBookingByLessor(1, 3).Return(pending, nil)
+	repo.EXPECT().GetBookingByID(1, renterID).Return(pending, nil)
+	repo.EXPECT().UpdateBooking(1, model.Rejected, pending, lessorUserID).
+		Return(&model.Bookings{BookingID: 1, Status: model.Rejected, StatusReason: "console is broken"}, nil)
+
+	booking, err := u.RejectBooking(1, 3, lessorUserID, "console is broken")
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Rejected, booking.Status)
+	assert.Equal(t, "console is broken", pending.StatusReason)
+}
+
+func TestCreateBookingRejectsInvalidDates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := usecase.NewBookingUsecase(mocks.NewMockIBookingRepository(ctrl), nil)
+
+	today := time.Now().UTC()
+	cases := map[string][2]string{
+		"bad format":        {"01/02/2030", "2030-01-10"},
+		"end before start":  {today.AddDate(0, 0, 5).Format("2006-01-02"), today.AddDate(0, 0, 2).Format("2006-01-02")},
+		"start in the past": {today.AddDate(0, 0, -1).Format("2006-01-02"), today.AddDate(0, 0, 2).Format("2006-01-02")},
+	}
+	for name, dates := range cases {
+		t.Run(name, func(t *testing.T) {
+			booking, err := u.CreateBooking(&model.Bookings{
+				UserID:    uuid.New(),
+				ProductID: 1,
+				StartDate: dates[0],
+				EndDate:   dates[1],
+				Status:    model.Pending,
+			})
+
+			assert.ErrorIs(t, err, usecase.ErrInvalidBooking)
+			assert.Nil(t, booking)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefundPercentFullBeforeCutoff(t *testing.T) {
+	policy := &model.CancellationPolicies{FullRefundDays: 7, PartialRefundPercent: 50}
+	booking := &model.Bookings{Status: model.Paid, StartDate: "2025-03-08"}
+
+	percent, err := usecase.RefundPercent(policy, booking, time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, percent)
+}
+
+func TestRefundPercentPartialAfterCutoff(t *testing.T) {
+	policy := &model.CancellationPolicies{FullRefundDays: 7, PartialRefundPercent: 50}
+	booking := &model.Bookings{Status: model.Paid, StartDate: "2025-03-07"}
+
+	percent, err := usecase.RefundPercent(policy, booking, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 50.0, percent)
+}
+
+func TestRefundPercentNoneOncePickedUp(t *testing.T) {
+	policy := &model.CancellationPolicies{FullRefundDays: 7, PartialRefundPercent: 50}
+	booking := &model.Bookings{Status: model.Active, StartDate: "2025-03-30"}
+
+	percent, err := usecase.RefundPercent(policy, booking, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, percent)
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"rent-video-game/mocks"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeginReservesNewKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(mockRepo, time.Minute)
+
+	userID := uuid.New()
+	mockRepo.EXPECT().Reserve(gomock.Any()).DoAndReturn(func(record *model.IdempotencyKeys) (*model.IdempotencyKeys, bool, error) {
+		record.IdempotencyKeyID = 1
+		return record, true, nil
+	})
+
+	record, replay, err := idempotencyUsecase.Begin(userID, "key-1", "POST /user/topup", "hash")
+
+	assert.NoError(t, err)
+	assert.False(t, replay)
+	assert.Equal(t, 1, record.IdempotencyKeyID)
+}
+
+func TestBeginReplaysCompletedKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(mockRepo, time.Minute)
+
+	stored := &model.IdempotencyKeys{IdempotencyKeyID: 1, RequestHash: "hash", StatusCode: 200, ResponseBody: []byte(`{}`)}
+	mockRepo.EXPECT().Reserve(gomock.Any()).Return(stored, false, nil)
+
+	record, replay, err := idempotencyUsecase.Begin(uuid.New(), "key-1", "POST /user/topup", "hash")
+
+	assert.NoError(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, stored, record)
+}
+
+func TestBeginRejectsDifferentBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(mockRepo, time.Minute)
+
+	stored := &model.IdempotencyKeys{IdempotencyKeyID: 1, RequestHash: "hash", StatusCode: 200}
+	mockRepo.EXPECT().Reserve(gomock.Any()).Return(stored, false, nil)
+
+	_, _, err := idempotencyUsecase.Begin(uuid.New(), "key-1", "POST /user/topup", "other-hash")
+
+	assert.ErrorIs(t, err, usecase.ErrIdempotencyKeyReused)
+}
+
+func TestBeginRejectsKeyInProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(mockRepo, time.Minute)
+
+	stored := &model.IdempotencyKeys{IdempotencyKeyID: 1, RequestHash: "hash", CreatedAt: time.Now()}
+	mockRepo.EXPECT().Reserve(gomock.Any()).Return(stored, false, nil)
+
+	_, _, err := idempotencyUsecase.Begin(uuid.New(), "key-1", "POST /user/topup", "hash")
+
+	assert.ErrorIs(t, err, usecase.ErrIdempotencyKeyInProgress)
+}
+
+func TestBeginTakesOverStaleKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(mockRepo, time.Minute)
+
+	stored := &model.IdempotencyKeys{IdempotencyKeyID: 1, RequestHash: "hash", CreatedAt: time.Now().Add(-2 * time.Minute)}
+	mockRepo.EXPECT().Reserve(gomock.Any()).Return(stored, false, nil)
+	mockRepo.EXPECT().TakeOver(1, gomock.Any()).Return(true, nil)
+
+	record, replay, err := idempotencyUsecase.Begin(uuid.New(), "key-1", "POST /user/topup", "hash")
+
+	assert.NoError(t, err)
+	assert.False(t, replay)
+	assert.Equal(t, stored, record)
+}
+
+func TestBeginLosesStaleKeyToAnotherRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockIIdempotencyRepository(ctrl)
+	idempotencyUsecase := usecase.NewIdempotencyUsecase(mockRepo, time.Minute)
+
+	stored := &model.IdempotencyKeys{IdempotencyKeyID: 1, RequestHash: "hash", CreatedAt: time.Now().Add(-2 * time.Minute)}
+	mockRepo.EXPECT().Reserve(gomock.Any()).Return(stored, false, nil)
+	mockRepo.EXPECT().TakeOver(1, gomock.Any()).Return(false, nil)
+
+	_, _, err := idempotencyUsecase.Begin(uuid.New(), "key-1", "POST /user/topup", "hash")
+
+	assert.ErrorIs(t, err, usecase.ErrIdempotencyKeyInProgress)
+}
@@ -0,0 +1,18 @@
+package tests
+
+import (
+	"rent-video-game/usecase"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayBookingRejectsInvalidRequest(t *testing.T) {
+	u := usecase.NewPaymentUsecase(nil, nil)
+
+	_, err := u.PayBooking(0, uuid.New(), -100)
+
+	assert.ErrorIs(t, err, usecase.ErrInvalidPayment)
+	assert.ErrorContains(t, err, "booking ID is required, amount must not be negative")
+}
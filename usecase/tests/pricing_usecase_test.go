@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteProratesPartialMonth(t *testing.T) {
+	pricingUsecase := usecase.NewPricingUsecase(nil, 1)
+
+	booking := &model.Bookings{BookingID: 1, StartDate: "2025-01-01", EndDate: "2025-02-14"}
+	product := &model.Products{ProductID: 2, RentalCostPerMonth: 10000}
+
+	quote, err := pricingUsecase.Quote(booking, product)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 45, quote.RentalDays)
+	assert.Equal(t, 1, quote.BilledMonths)
+	// 100.00 for the first 30 days, 15/30 of 100.00 for the rest
+	assert.Equal(t, currency.Money(15000), quote.Amount)
+}
+
+func TestQuoteRoundsUpToCent(t *testing.T) {
+	pricingUsecase := usecase.NewPricingUsecase(nil, 1)
+
+	booking := &model.Bookings{StartDate: "2025-01-01", EndDate: "2025-01-01"}
+	product := &model.Products{RentalCostPerMonth: 1000}
+
+	quote, err := pricingUsecase.Quote(booking, product)
+
+	assert.NoError(t, err)
+	assert.Equal(t, currency.Money(34), quote.Amount)
+}
+
+func TestQuoteAppliesMinimumPeriod(t *testing.T) {
+	pricingUsecase := usecase.NewPricingUsecase(nil, 7)
+
+	booking := &model.Bookings{StartDate: "2025-01-01", EndDate: "2025-01-02"}
+	product := &model.Products{RentalCostPerMonth: 3000}
+
+	quote, err := pricingUsecase.Quote(booking, product)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, quote.RentalDays)
+	assert.Equal(t, 7, quote.BilledDays)
+	assert.Equal(t, currency.Money(700), quote.Amount)
+}
+
+func TestQuoteRejectsReversedDates(t *testing.T) {
+	pricingUsecase := usecase.NewPricingUsecase(nil, 1)
+
+	booking := &model.Bookings{StartDate: "2025-01-10", EndDate: "2025-01-01"}
+	product := &model.Products{RentalCostPerMonth: 30}
+
+	quote, err := pricingUsecase.Quote(booking, product)
+
+	assert.Error(t, err)
+	assert.Nil(t, quote)
+}
+
+func TestQuoteAddsDepositToTotalDue(t *testing.T) {
+	pricingUsecase := usecase.NewPricingUsecase(nil, 1)
+
+	booking := &model.Bookings{StartDate: "2025-01-01", EndDate: "2025-01-30"}
+	product := &model.Products{RentalCostPerMonth: 10000, DepositAmount: 4999}
+
+	quote, err := pricingUsecase.Quote(booking, product)
+
+	assert.NoError(t, err)
+	assert.Equal(t, currency.Money(10000), quote.Amount)
+	assert.Equal(t, currency.Money(4999), quote.DepositAmount)
+	assert.Equal(t, currency.Money(14999), quote.TotalDue)
+}
+
+func TestQuoteConvertsAtBookingRate(t *testing.T) {
+	pricingUsecase := usecase.NewPricingUsecase(nil, 1)
+
+	booking := &model.Bookings{StartDate: "2025-01-01", EndDate: "2025-01-30", Currency: currency.SGD, ExchangeRate: "1.3500000000"}
+	product := &model.Products{Currency: currency.USD, RentalCostPerMonth: 10000, DepositAmount: 2001}
+
+	quote, err := pricingUsecase.Quote(booking, product)
+
+	assert.NoError(t, err)
+	assert.Equal(t, currency.SGD, quote.Currency)
+	assert.Equal(t, currency.Money(10000), quote.ProductAmount)
+	assert.Equal(t, currency.Money(13500), quote.Amount)
+	// 20.01 USD is 27.0135 SGD, rounded to the cent
+	assert.Equal(t, currency.Money(2701), quote.DepositAmount)
+	assert.Equal(t, currency.Money(16201), quote.TotalDue)
+}
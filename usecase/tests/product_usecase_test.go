@@ -0,0 +1,22 @@
+package tests
+
+import (
+	"rent-video-game/usecase"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAvailabilityRejectsInvalidRange(t *testing.T) {
+	u := usecase.NewProductUsecase(nil, nil)
+
+	for _, r := range [][2]string{
+		{"2025-13-01", "2025-03-10"},
+		{"2025-03-10", "2025-03-01"},
+		{"2025-01-01", "2026-01-02"},
+	} {
+		_, err := u.GetAvailability(2, r[0], r[1])
+
+		assert.ErrorIs(t, err, usecase.ErrInvalidDateRange, "%s to %s", r[0], r[1])
+	}
+}
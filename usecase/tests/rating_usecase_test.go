@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"rent-video-game/mocks"
+	"rent-video-game/model"
+	"rent-video-game/usecase"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRatingsByProductPagesWithCursor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockIRatingRepository(ctrl)
+	u := usecase.NewRatingUsecase(repo, time.Hour)
+
+	repo.EXPECT().GetRatingsByProduct(4, 0, 3).
+		Return([]model.Ratings{{RatingID: 9}, {RatingID: 7}, {RatingID: 5}}, nil)
+
+	page, err := u.GetRatingsByProduct(4, 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, page.Ratings, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	repo.EXPECT().GetRatingsByProduct(4, 7, 3).
+		Return([]model.Ratings{{RatingID: 5}}, nil)
+
+	page, err = u.GetRatingsByProduct(4, 2, page.NextCursor)
+	assert.NoError(t, err)
+	assert.Len(t, page.Ratings, 1)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestGetRatingsByProductRejectsBadCursor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := usecase.NewRatingUsecase(mocks.NewMockIRatingRepository(ctrl), time.Hour)
+
+	_, err := u.GetRatingsByProduct(4, 0, "not-a-cursor")
+
+	assert.ErrorIs(t, err, usecase.ErrInvalidRatingPage)
+}
+
+func TestCreateRatingRequiresRental(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockIRatingRepository(ctrl)
+	u := usecase.NewRatingUsecase(repo, time.Hour)
+
+	userID := uuid.New()
+	repo.EXPECT().HasRentedProduct(userID, 4).Return(false, nil)
+
+	_, err := u.CreateRating(&model.Ratings{UserID: userID, ProductID: 4, Review: "Great", Stars: 4.5})
+
+	assert.ErrorIs(t, err, usecase.ErrRatingNotRented)
+}
+
+func TestCreateRatingRejectsStarsOffHalfSteps(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	u := usecase.NewRatingUsecase(mocks.NewMockIRatingRepository(ctrl), time.Hour)
+
+	for _, stars := range []float64{0, 0.5, 3.3, 5.5} {
+		_, err := u.CreateRating(&model.Ratings{UserID: uuid.New(), ProductID: 4, Review: "Great", Stars: stars})
+
+		assert.ErrorIs(t, err, usecase.ErrInvalidRating, "stars %v", stars)
+	}
+}
+
+func TestUpdateRatingAfterEditWindow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockIRatingRepository(ctrl)
+	u := usecase.NewRatingUsecase(repo, time.Hour)
+
+	userID := uuid.New()
+	repo.EXPECT().GetRatingByID(9).
+		Return(&model.Ratings{RatingID: 9, UserID: userID, CreatedAt: time.Now().Add(-2 * time.Hour)}, nil)
+
+	_, err := u.UpdateRating(9, userID, "Changed my mind", 2)
+
+	assert.ErrorIs(t, err, usecase.ErrRatingEditWindowClosed)
+}
+
+func TestDeleteRatingOfAnotherUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mocks.NewMockIRatingRepository(ctrl)
+	u := usecase.NewRatingUsecase(repo, time.Hour)
+
+	repo.EXPECT().GetRatingByID(9).
+		Return(&model.Ratings{RatingID: 9, UserID: uuid.New(), CreatedAt: time.Now()}, nil)
+
+	err := u.DeleteRating(9, uuid.New())
+
+	assert.ErrorIs(t, err, usecase.ErrRatingNotAuthor)
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"rent-video-game/currency"
+	"rent-video-game/mocks"
+	"rent-video-game/model"
+	"rent-video-game/payment"
+	"rent-video-game/repository"
+	"rent-video-game/usecase"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopupCreditedWhenFakePaymentConfirmed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	gateway := payment.NewFake("secret")
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, gateway)
+	gateway.Subscribe(func(event *payment.Event) error {
+		_, err := topupUsecase.HandleEvent(event)
+		return err
+	})
+
+	userID := uuid.New()
+
+	intent, err := topupUsecase.CreateTopup(userID, currency.USD, 2510)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2510), intent.Amount)
+
+	mockRepo.EXPECT().CreditTopup(gomock.Any(), userID, currency.USD, currency.Money(2510)).
+		DoAndReturn(func(event *model.PaymentEvents, userID uuid.UUID, code currency.Code, amount currency.Money) (*model.TopupHistory, error) {
+			assert.Equal(t, intent.ID, event.PaymentID)
+			return &model.TopupHistory{UserID: userID, PaymentID: event.PaymentID, Currency: code, Amount: amount}, nil
+		})
+
+	intent, err = topupUsecase.ConfirmTopup(userID, intent.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, payment.IntentSucceeded, intent.Status)
+}
+
+func TestConfirmTopupRejectsOtherUsersPayment(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, payment.NewFake("secret"))
+
+	intent, err := topupUsecase.CreateTopup(uuid.New(), currency.USD, 1000)
+	assert.NoError(t, err)
+
+	_, err = topupUsecase.ConfirmTopup(uuid.New(), intent.ID)
+
+	assert.ErrorIs(t, err, usecase.ErrPaymentNotOwned)
+}
+
+func TestTopupRefundedThroughFakeGateway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	gateway := payment.NewFake("secret")
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, gateway)
+	gateway.Subscribe(func(event *payment.Event) error {
+		_, err := topupUsecase.HandleEvent(event)
+		return err
+	})
+
+	userID := uuid.New()
+	intent, _ := topupUsecase.CreateTopup(userID, currency.USD, 2500)
+
+	mockRepo.EXPECT().CreditTopup(gomock.Any(), userID, currency.USD, currency.Money(2500)).Return(&model.TopupHistory{}, nil)
+	_, err := topupUsecase.ConfirmTopup(userID, intent.ID)
+	assert.NoError(t, err)
+
+	mockRepo.EXPECT().RefundTopup(gomock.Any(), currency.Money(1000)).Return(&model.TopupHistory{RefundedAmount: 1000}, currency.Money(1000), nil)
+	_, err = gateway.Refund(intent.ID, 1000)
+
+	assert.NoError(t, err)
+}
+
+func TestHandleRedeliveredEventIsSkipped(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, payment.NewFake("secret"))
+
+	mockRepo.EXPECT().CreditTopup(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, repository.ErrEventAlreadyProcessed)
+
+	result, err := topupUsecase.HandleEvent(&payment.Event{
+		ID:        "evt_1",
+		Type:      payment.EventIntentSucceeded,
+		PaymentID: "pi_1",
+		Amount:    2500,
+		Currency:  "usd",
+		Metadata:  map[string]string{"user_id": uuid.NewString()},
+	})
+
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestHandlePaymentFailedRecordsEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	gateway := payment.NewFake("secret")
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, gateway)
+	gateway.Subscribe(func(event *payment.Event) error {
+		_, err := topupUsecase.HandleEvent(event)
+		return err
+	})
+
+	mockRepo.EXPECT().RecordEvent(gomock.Any()).DoAndReturn(func(event *model.PaymentEvents) error {
+		assert.Equal(t, model.PaymentEventFailed, event.Status)
+		assert.Equal(t, "Your card was declined.", event.Error)
+		return nil
+	})
+
+	intent, _ := topupUsecase.CreateTopup(uuid.New(), currency.USD, 2500)
+	err := gateway.FailIntent(intent.ID, "Your card was declined.")
+
+	assert.NoError(t, err)
+}
+
+func TestHandleUncollectableRefundIsRecordedAsFailed(t *testing.T) {
+	for _, refundErr := range []error{repository.ErrInsufficientBalance, repository.ErrRefundExceedsTopup} {
+		t.Run(refundErr.Error(), func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockITopupRepository(ctrl)
+			topupUsecase := usecase.NewTopupUsecase(mockRepo, payment.NewFake("secret"))
+
+			mockRepo.EXPECT().RefundTopup(gomock.Any(), currency.Money(1000)).Return(nil, currency.Money(0), refundErr)
+			mockRepo.EXPECT().RecordEvent(gomock.Any()).DoAndReturn(func(event *model.PaymentEvents) error {
+				assert.Equal(t, model.PaymentEventFailed, event.Status)
+				return nil
+			})
+
+			result, err := topupUsecase.HandleEvent(&payment.Event{
+				ID:             "evt_2",
+				Type:           payment.EventRefunded,
+				PaymentID:      "pi_1",
+				AmountRefunded: 1000,
+			})
+
+			assert.NoError(t, err)
+			assert.Nil(t, result)
+		})
+	}
+}
+
+func TestRefundTopupReservesWalletBeforeGateway(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	gateway := payment.NewFake("secret")
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, gateway)
+	gateway.Subscribe(func(event *payment.Event) error {
+		_, err := topupUsecase.HandleEvent(event)
+		return err
+	})
+
+	userID := uuid.New()
+	intent, _ := topupUsecase.CreateTopup(userID, currency.USD, 2500)
+
+	mockRepo.EXPECT().CreditTopup(gomock.Any(), userID, currency.USD, currency.Money(2500)).Return(&model.TopupHistory{}, nil)
+	_, err := topupUsecase.ConfirmTopup(userID, intent.ID)
+	assert.NoError(t, err)
+
+	gomock.InOrder(
+		mockRepo.EXPECT().ReserveRefund(7, userID, currency.Money(0)).
+			Return(&model.TopupHistory{TopupHistoryID: 7, PaymentID: intent.ID, Amount: 2500, RefundedAmount: 2500}, currency.Money(2500), nil),
+		// the refund event finds the wallet already debited
+		mockRepo.EXPECT().RefundTopup(gomock.Any(), currency.Money(2500)).Return(&model.TopupHistory{RefundedAmount: 2500}, currency.Money(0), nil),
+	)
+
+	topupHistory, refund, err := topupUsecase.RefundTopup(userID, 7, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2500), refund.Amount)
+	assert.Equal(t, currency.Money(2500), topupHistory.RefundedAmount)
+}
+
+func TestRefundTopupRestoresWalletWhenGatewayDeclines(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, payment.NewFake("secret"))
+
+	userID := uuid.New()
+
+	mockRepo.EXPECT().ReserveRefund(7, userID, currency.Money(1000)).
+		Return(&model.TopupHistory{TopupHistoryID: 7, PaymentID: "pi_unknown", Amount: 2500, RefundedAmount: 1000}, currency.Money(1000), nil)
+	mockRepo.EXPECT().CancelRefund(7, currency.Money(1000)).Return(nil)
+
+	_, _, err := topupUsecase.RefundTopup(userID, 7, 1000)
+
+	assert.ErrorIs(t, err, payment.ErrIntentNotFound)
+}
+
+func TestTopupInAnotherCurrencyIsRecordedAsFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockITopupRepository(ctrl)
+	topupUsecase := usecase.NewTopupUsecase(mockRepo, payment.NewFake("secret"))
+
+	userID := uuid.New()
+
+	mockRepo.EXPECT().CreditTopup(gomock.Any(), userID, currency.SGD, currency.Money(2500)).
+		Return(nil, repository.ErrCurrencyMismatch)
+	mockRepo.EXPECT().RecordEvent(gomock.Any()).DoAndReturn(func(event *model.PaymentEvents) error {
+		assert.Equal(t, model.PaymentEventFailed, event.Status)
+		return nil
+	})
+
+	result, err := topupUsecase.HandleEvent(&payment.Event{
+		ID:        "evt_3",
+		Type:      payment.EventIntentSucceeded,
+		PaymentID: "pi_1",
+		Amount:    2500,
+		Currency:  "sgd",
+		Metadata:  map[string]string{"user_id": userID.String()},
+	})
+
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+}
@@ -0,0 +1,138 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"rent-video-game/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+type TokenUsecase struct {
+	tokenRepo   repository.ITokenRepository
+	roleUsecase *RoleUsecase
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+}
+
+func NewTokenUsecase(tokenRepo repository.ITokenRepository, roleUsecase *RoleUsecase, accessTTL, refreshTTL time.Duration) *TokenUsecase {
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTokenTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTokenTTL
+	}
+	return &TokenUsecase{tokenRepo: tokenRepo, roleUsecase: roleUsecase, accessTTL: accessTTL, refreshTTL: refreshTTL}
+}
+
+// Login starts a new refresh-token family for the user.
+func (u *TokenUsecase) Login(userID uuid.UUID) (*model.TokenPair, error) {
+	refreshToken, record, err := u.newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	record.UserID = userID
+	record.FamilyID = uuid.New()
+
+	if err := u.tokenRepo.CreateRefreshToken(record); err != nil {
+		return nil, err
+	}
+
+	return u.pair(userID, refreshToken)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. The new access token carries the user's current roles.
+func (u *TokenUsecase) Refresh(refreshToken string) (*model.TokenPair, error) {
+	nextToken, next, err := u.newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	next, err = u.tokenRepo.RotateRefreshToken(hashToken(refreshToken), next, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return u.pair(next.UserID, nextToken)
+}
+
+// Logout revokes the access token in use and, when given, the login the
+// refresh token belongs to.
+func (u *TokenUsecase) Logout(userID uuid.UUID, jti string, expiresAt time.Time, refreshToken string) error {
+	if jti != "" {
+		if err := u.tokenRepo.RevokeAccessToken(&model.RevokedTokens{JTI: jti, UserID: userID, ExpiresAt: expiresAt}); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	return u.tokenRepo.RevokeFamily(hashToken(refreshToken), userID, time.Now())
+}
+
+// RevokeAll ends every login of the user. Access tokens already handed out
+// stay valid until they expire, which the short access TTL bounds.
+func (u *TokenUsecase) RevokeAll(userID uuid.UUID) error {
+	return u.tokenRepo.RevokeAllByUser(userID, time.Now())
+}
+
+// IssueAccessToken signs an access token without touching refresh tokens,
+// for callers that just changed the user's roles.
+func (u *TokenUsecase) IssueAccessToken(userID uuid.UUID) (string, error) {
+	access, err := u.roleUsecase.GetAccess(userID)
+	if err != nil {
+		return "", err
+	}
+	return utils.GenerateUserToken(userID, access, u.accessTTL)
+}
+
+func (u *TokenUsecase) IsRevoked(jti string) (bool, error) {
+	return u.tokenRepo.IsAccessTokenRevoked(jti)
+}
+
+func (u *TokenUsecase) PurgeExpired() (int64, error) {
+	return u.tokenRepo.PurgeExpired(time.Now())
+}
+
+func (u *TokenUsecase) pair(userID uuid.UUID, refreshToken string) (*model.TokenPair, error) {
+	accessToken, err := u.IssueAccessToken(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		ExpiresIn:    int(u.accessTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func (u *TokenUsecase) newRefreshToken() (string, *model.RefreshTokens, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, &model.RefreshTokens{
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(u.refreshTTL),
+	}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"rent-video-game/currency"
+	"rent-video-game/model"
+	"rent-video-game/payment"
+	"rent-video-game/repository"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrPaymentNotOwned = errors.New("payment does not belong to user")
+
+type TopupUsecase struct {
+	topupRepo repository.ITopupRepository
+	gateway   payment.Gateway
+}
+
+func NewTopupUsecase(topupRepo repository.ITopupRepository, gateway payment.Gateway) *TopupUsecase {
+	return &TopupUsecase{topupRepo: topupRepo, gateway: gateway}
+}
+
+// CreateTopup opens a payment intent for the amount in the wallet's currency.
+// The wallet is credited only once the gateway reports the payment succeeded.
+func (u *TopupUsecase) CreateTopup(userID uuid.UUID, code currency.Code, amount currency.Money) (*payment.Intent, error) {
+	var error []string
+
+	if userID == uuid.Nil {
+		error = append(error, "user ID is required")
+	}
+	if !code.Valid() {
+		error = append(error, "currency is not supported")
+	}
+	if amount <= 0 {
+		error = append(error, "amount must be greater than 0")
+	}
+
+	if len(error) > 0 {
+		return nil, errors.New(strings.Join(error, ", "))
+	}
+
+	return u.gateway.CreateIntent(amount.Minor(), strings.ToLower(string(code)), map[string]string{
+		"user_id": userID.String(),
+	})
+}
+
+// ConfirmTopup confirms the user's own intent from the server. Browser and
+// mobile clients normally confirm with the client secret instead.
+func (u *TopupUsecase) ConfirmTopup(userID uuid.UUID, paymentID string) (*payment.Intent, error) {
+	intent, err := u.gateway.GetIntent(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Metadata["user_id"] != userID.String() {
+		return nil, ErrPaymentNotOwned
+	}
+
+	return u.gateway.ConfirmIntent(paymentID)
+}
+
+// RefundTopup pays part or all of a top-up back to the card it came from. An
+// amount of 0 refunds whatever is left of the top-up. The wallet is debited
+// first and credited again if the gateway declines the refund.
+func (u *TopupUsecase) RefundTopup(userID uuid.UUID, topupHistoryID int, amount currency.Money) (*model.TopupHistory, *payment.Refund, error) {
+	var error []string
+
+	if userID == uuid.Nil {
+		error = append(error, "user ID is required")
+	}
+	if topupHistoryID <= 0 {
+		error = append(error, "topup history ID is required")
+	}
+	if amount < 0 {
+		error = append(error, "amount must not be negative")
+	}
+
+	if len(error) > 0 {
+		return nil, nil, errors.New(strings.Join(error, ", "))
+	}
+
+	topupHistory, reserved, err := u.topupRepo.ReserveRefund(topupHistoryID, userID, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refund, err := u.gateway.Refund(topupHistory.PaymentID, reserved.Minor())
+	if err != nil && refund == nil {
+		if cancelErr := u.topupRepo.CancelRefund(topupHistoryID, reserved); cancelErr != nil {
+			return nil, nil, fmt.Errorf("refund failed: %v; restoring wallet failed: %w", err, cancelErr)
+		}
+		return nil, nil, err
+	}
+
+	return topupHistory, refund, nil
+}
+
+func (u *TopupUsecase) ParseEvent(payload []byte, signature string) (*payment.Event, error) {
+	return u.gateway.ParseEvent(payload, signature)
+}
+
+// HandleEvent applies a verified gateway event to the wallets. It returns the
+// topup history the event changed, or nil when nothing changed. Events that
+// were already handled are skipped, so the gateway may redeliver freely.
+func (u *TopupUsecase) HandleEvent(event *payment.Event) (*model.TopupHistory, error) {
+	if event.ID == "" {
+		return nil, errors.New("event ID is required")
+	}
+
+	var (
+		topupHistory *model.TopupHistory
+		err          error
+	)
+
+	switch event.Type {
+	case payment.EventIntentSucceeded:
+		topupHistory, err = u.creditTopup(event)
+	case payment.EventIntentFailed:
+		err = u.topupRepo.RecordEvent(&model.PaymentEvents{
+			EventID:   event.ID,
+			Type:      string(event.Type),
+			PaymentID: event.PaymentID,
+			Status:    model.PaymentEventFailed,
+			Error:     event.FailureMessage,
+		})
+	case payment.EventRefunded:
+		topupHistory, err = u.refundTopup(event)
+	default:
+		return nil, nil
+	}
+
+	if errors.Is(err, repository.ErrEventAlreadyProcessed) {
+		return nil, nil
+	}
+	return topupHistory, err
+}
+
+func (u *TopupUsecase) creditTopup(event *payment.Event) (*model.TopupHistory, error) {
+	record := &model.PaymentEvents{EventID: event.ID, Type: string(event.Type), PaymentID: event.PaymentID, Status: model.PaymentEventProcessed}
+
+	userID, err := uuid.Parse(event.Metadata["user_id"])
+	if err != nil || event.Amount <= 0 {
+		record.Status = model.PaymentEventIgnored
+		record.Error = "payment intent has no user or no amount received"
+		return nil, u.topupRepo.RecordEvent(record)
+	}
+
+	code, err := currency.Parse(event.Currency)
+	if err != nil {
+		record.Status = model.PaymentEventFailed
+		record.Error = err.Error()
+		return nil, u.topupRepo.RecordEvent(record)
+	}
+
+	topupHistory, err := u.topupRepo.CreditTopup(record, userID, code, currency.Money(event.Amount))
+	if !errors.Is(err, repository.ErrCurrencyMismatch) {
+		return topupHistory, err
+	}
+
+	// paid in a currency the wallet does not hold; keep it for a manual refund
+	record.Status = model.PaymentEventFailed
+	record.Error = err.Error()
+	return nil, u.topupRepo.RecordEvent(record)
+}
+
+func (u *TopupUsecase) refundTopup(event *payment.Event) (*model.TopupHistory, error) {
+	record := &model.PaymentEvents{EventID: event.ID, Type: string(event.Type), PaymentID: event.PaymentID, Status: model.PaymentEventProcessed}
+	if event.PaymentID == "" {
+		record.Status = model.PaymentEventIgnored
+		record.Error = "charge has no payment intent"
+		return nil, u.topupRepo.RecordEvent(record)
+	}
+
+	topupHistory, _, err := u.topupRepo.RefundTopup(record, currency.Money(event.AmountRefunded))
+	if !errors.Is(err, repository.ErrInsufficientBalance) && !errors.Is(err, repository.ErrRefundExceedsTopup) &&
+		!errors.Is(err, gorm.ErrRecordNotFound) {
+		return topupHistory, err
+	}
+
+	// the money is gone from the wallet, is more than was topped up or was
+	// never a top-up; keep the event
+	// for a manual follow-up instead of making the gateway retry it forever
+	record.Status = model.PaymentEventFailed
+	record.Error = fmt.Sprintf("refund of %s not collected: %v", currency.Money(event.AmountRefunded), err)
+	return nil, u.topupRepo.RecordEvent(record)
+}
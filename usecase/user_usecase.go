@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"errors"
+	"rent-video-game/currency"
 	"rent-video-game/model"
 	"rent-video-game/repository"
 	"strings"
@@ -13,8 +14,6 @@ type IUserUsecase interface {
 	RegisterUser(user *model.Users) (*model.Users, error)
 	GetUserByID(userID uuid.UUID) (*model.Users, error)
 	GetUserByEmail(email string) (*model.Users, error)
-	TopupUser(userID uuid.UUID, user *model.Users) (*model.Users, error)
-	TransactionUser(userID uuid.UUID, user *model.Users) (*model.Users, error)
 }
 
 type UserUsecase struct {
@@ -49,6 +48,13 @@ func (u *UserUsecase) RegisterUser(user *model.Users) (*model.Users, error) {
 	if user.Amount != 0 {
 		error = append(error, "cannot set amount")
 	}
+	if user.Currency == "" {
+		user.Currency = currency.Default
+	} else if code, err := currency.Parse(string(user.Currency)); err != nil {
+		error = append(error, "currency is not supported")
+	} else {
+		user.Currency = code
+	}
 
 	if len(error) > 0 {
 		return nil, errors.New(strings.Join(error, ", "))
@@ -64,19 +70,3 @@ func (u *UserUsecase) GetUserByID(userID uuid.UUID) (*model.Users, error) {
 func (u *UserUsecase) GetUserByEmail(email string) (*model.Users, error) {
 	return u.userRepo.GetUserByEmail(email)
 }
-
-func (u *UserUsecase) TopupUser(userID uuid.UUID, user *model.Users) (*model.Users, error) {
-	if user.Amount < 0 {
-		return nil, errors.New("amount must be greater than 0")
-	}
-
-	return u.userRepo.TopupUser(userID, user)
-}
-
-func (u *UserUsecase) TransactionUser(userID uuid.UUID, user *model.Users) (*model.Users, error) {
-	if user.Amount < 0 {
-		return nil, errors.New("amount must be greater than 0")
-	}
-
-	return u.userRepo.TransactionUser(userID, user)
-}
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"errors"
+	"rent-video-game/model"
+	"rent-video-game/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type WithdrawalUsecase struct {
+	withdrawalRepo repository.IWithdrawalRepository
+}
+
+func NewWithdrawalUsecase(withdrawalRepo repository.IWithdrawalRepository) *WithdrawalUsecase {
+	return &WithdrawalUsecase{withdrawalRepo: withdrawalRepo}
+}
+
+func (u *WithdrawalUsecase) CreateWithdrawal(withdrawal *model.Withdrawals) (*model.Withdrawals, error) {
+	var error []string
+
+	if withdrawal.UserID == uuid.Nil {
+		error = append(error, "user ID is required")
+	}
+	if withdrawal.Amount <= 0 {
+		error = append(error, "amount must be greater than 0")
+	}
+	if strings.TrimSpace(withdrawal.Destination) == "" {
+		error = append(error, "destination is required")
+	}
+
+	if len(error) > 0 {
+		return nil, errors.New(strings.Join(error, ", "))
+	}
+
+	return u.withdrawalRepo.CreateWithdrawal(withdrawal)
+}
+
+func (u *WithdrawalUsecase) GetWithdrawalByID(withdrawalID int) (*model.Withdrawals, error) {
+	return u.withdrawalRepo.GetWithdrawalByID(withdrawalID)
+}
+
+func (u *WithdrawalUsecase) GetAllWithdrawalByUser(userID uuid.UUID) ([]model.Withdrawals, error) {
+	return u.withdrawalRepo.GetAllWithdrawalByUser(userID)
+}
+
+func (u *WithdrawalUsecase) GetAllWithdrawalByStatus(status model.WithdrawalStatus) ([]model.Withdrawals, error) {
+	return u.withdrawalRepo.GetAllWithdrawalByStatus(status)
+}
+
+func (u *WithdrawalUsecase) ApproveWithdrawal(withdrawalID int) (*model.Withdrawals, error) {
+	return u.withdrawalRepo.UpdateWithdrawalStatus(withdrawalID, model.WithdrawalApproved, "")
+}
+
+func (u *WithdrawalUsecase) PayWithdrawal(withdrawalID int) (*model.Withdrawals, error) {
+	return u.withdrawalRepo.UpdateWithdrawalStatus(withdrawalID, model.WithdrawalPaid, "")
+}
+
+// FailWithdrawal rejects or aborts a withdrawal and returns the held amount to
+// the wallet. A reason is required so the user knows what went wrong.
+func (u *WithdrawalUsecase) FailWithdrawal(withdrawalID int, reason string) (*model.Withdrawals, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("reason is required")
+	}
+	return u.withdrawalRepo.UpdateWithdrawalStatus(withdrawalID, model.WithdrawalFailed, reason)
+}
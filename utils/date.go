@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+// ParseDate accepts a plain date as well as the timestamp form Postgres
+// returns for DATE columns.
+func ParseDate(str string) (time.Time, error) {
+	if date, err := time.Parse(DateLayout, str); err == nil {
+		return date, nil
+	}
+
+	date, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected format YYYY-MM-DD", str)
+	}
+
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
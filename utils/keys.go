@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"rent-video-game/model"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	privateKeySuffix = ".pem"
+	publicKeySuffix  = ".pub.pem"
+)
+
+var ErrNoSigningKey = errors.New("no signing key loaded")
+
+// SigningKey is one entry of the key directory. Retired keys have no private
+// half: they only verify tokens signed before the rotation.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds every key tokens may be verified with and the one new tokens
+// are signed with.
+type KeySet struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+}
+
+var (
+	keysMu     sync.RWMutex
+	activeKeys *KeySet
+)
+
+// UseKeySet makes the key set the one tokens are signed and verified with. It
+// is set at startup, after the environment is loaded.
+func UseKeySet(keys *KeySet) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	activeKeys = keys
+}
+
+func currentKeys() (*KeySet, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if activeKeys == nil {
+		return nil, ErrNoSigningKey
+	}
+	return activeKeys, nil
+}
+
+// NewKeySet builds a key set from keys. New tokens are signed with the key
+// named signingID, or with the private key with the greatest ID when it is
+// empty; key IDs are timestamps, so that is the newest key.
+func NewKeySet(signingID string, keys ...*SigningKey) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if existing, ok := set.keys[key.ID]; ok && existing.Private != nil {
+			continue
+		}
+		set.keys[key.ID] = key
+	}
+
+	for _, id := range set.IDs() {
+		key := set.keys[id]
+		if key.Private == nil {
+			continue
+		}
+		if signingID == "" || signingID == id {
+			set.signing = key
+		}
+	}
+
+	if set.signing == nil {
+		if signingID != "" {
+			return nil, fmt.Errorf("signing key %q not found or has no private key", signingID)
+		}
+		return nil, ErrNoSigningKey
+	}
+	return set, nil
+}
+
+// LoadKeySet reads the key directory. <kid>.pem files hold PKCS#8 private
+// keys and <kid>.pub.pem files hold PKIX public keys of retired keys that
+// still verify tokens.
+func LoadKeySet(dir, signingID string) (*KeySet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	var keys []*SigningKey
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, privateKeySuffix) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		var key *SigningKey
+		if strings.HasSuffix(name, publicKeySuffix) {
+			key, err = ParsePublicKey(strings.TrimSuffix(name, publicKeySuffix), data)
+		} else {
+			key, err = ParsePrivateKey(strings.TrimSuffix(name, privateKeySuffix), data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(signingID, keys...)
+}
+
+// GenerateSigningKey creates a new RS256 or EdDSA key.
+func GenerateSigningKey(id, alg string) (*SigningKey, error) {
+	switch alg {
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	case AlgEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: private, Public: public}, nil
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use %s or %s", alg, AlgRS256, AlgEdDSA)
+	}
+}
+
+func ParsePrivateKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: private, Public: private.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
+
+func ParsePublicKey(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch public := parsed.(type) {
+	case *rsa.PublicKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Public: public}, nil
+	case ed25519.PublicKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Public: public}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", parsed)
+	}
+}
+
+// MarshalPrivateKey encodes the key the way LoadKeySet reads it.
+func (k *SigningKey) MarshalPrivateKey() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (k *SigningKey) MarshalPublicKey() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.Public)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func (s *KeySet) IDs() []string {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+func (s *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signing.Private)
+}
+
+// verify checks the signature with the key named by the kid header. The
+// algorithm must be the one the key was made for.
+func (s *KeySet) verify(t string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(t, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("invalid signing method")
+		}
+		return key.Public, nil
+	}, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, fmt.Errorf("invalid token")
+}
+
+// JWKS publishes the public half of every key, retired ones included, so
+// other services can verify tokens without sharing a secret.
+func (s *KeySet) JWKS() model.JWKS {
+	jwks := model.JWKS{Keys: []model.JWK{}}
+	for _, id := range s.IDs() {
+		key := s.keys[id]
+		jwk := model.JWK{KeyID: id, Use: "sig", Algorithm: key.Method.Alg()}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// CurrentJWKS is the JWKS of the key set in use.
+func CurrentJWKS() (model.JWKS, error) {
+	keys, err := currentKeys()
+	if err != nil {
+		return model.JWKS{}, err
+	}
+	return keys.JWKS(), nil
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/google/uuid"
 )
 
-func SendTopupNotification(email, userName string, amount, newBalance float64, paymentID string) error {
+func SendTopupNotification(email, userName, amount, newBalance, paymentID string) error {
 	apiKey := os.Getenv("MAILERSEND_API_KEY")
 	fromEmail := os.Getenv("FROM_EMAIL")
 	fromName := os.Getenv("FROM_NAME")
@@ -31,8 +31,8 @@ func SendTopupNotification(email, userName string, amount, newBalance float64, p
 		<body>
 			<h1>Topup Successful!</h1>
 			<p>Dear %s,</p>
-			<p>Your account has been successfully charged with <strong>$%.2f</strong>.</p>
-			<p>Your new balance is: <strong>$%.2f</strong>.</p>
+			<p>Your account has been successfully charged with <strong>%s</strong>.</p>
+			<p>Your new balance is: <strong>%s</strong>.</p>
 			<p>Payment ID: <strong>%s</strong></p>
 			<p>Thank you for using our service!</p>
 			<p>Regards,<br>Video Game Rental Team</p>
@@ -41,7 +41,7 @@ func SendTopupNotification(email, userName string, amount, newBalance float64, p
 	`, userName, amount, newBalance, paymentID)
 
 	textContent := fmt.Sprintf(
-		"Topup Successful!\n\nDear %s,\n\nYour account has been successfully charged with $%.2f.\nYour new balance is: $%.2f.\nPayment ID: %s\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
+		"Topup Successful!\n\nDear %s,\n\nYour account has been successfully charged with %s.\nYour new balance is: %s.\nPayment ID: %s\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
 		userName, amount, newBalance, paymentID)
 
 	type EmailAddress struct {
@@ -110,7 +110,7 @@ func SendTopupNotification(email, userName string, amount, newBalance float64, p
 	return nil
 }
 
-func SendBookingNotification(email, userName string, status string, booking_id int, total_pay float64) error {
+func SendBookingNotification(email, userName string, status string, booking_id int, total_pay string) error {
 	apiKey := os.Getenv("MAILERSEND_API_KEY")
 	fromEmail := os.Getenv("FROM_EMAIL")
 	fromName := os.Getenv("FROM_NAME")
@@ -132,7 +132,7 @@ func SendBookingNotification(email, userName string, status string, booking_id i
 			<p>Dear %s,</p>
 			<p>Your game rental booking has been <strong>%s</strong>.</p>
 			<p>Booking ID: <strong>%d</strong></p>
-			<p>Total Payment: <strong>$%.2f</strong></p>
+			<p>Total Payment: <strong>%s</strong></p>
 			<p>Thank you for using our service!</p>
 			<p>Regards,<br>Video Game Rental Team</p>
 		</body>
@@ -140,7 +140,7 @@ func SendBookingNotification(email, userName string, status string, booking_id i
 	`, status, userName, status, booking_id, total_pay)
 
 	textContent := fmt.Sprintf(
-		"Booking %s\n\nDear %s,\n\nYour game rental booking has been %s.\nAmount: $%.2f\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
+		"Booking %s\n\nDear %s,\n\nYour game rental booking has been %s.\nAmount: %s\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
 		status, userName, status, total_pay)
 
 	type EmailAddress struct {
@@ -209,7 +209,7 @@ func SendBookingNotification(email, userName string, status string, booking_id i
 	return nil
 }
 
-func SendTransactionNotification(email, userName string, transferID int, amount float64, receiverID uuid.UUID, balance float64) error {
+func SendTransactionNotification(email, userName string, transferID int, amount string, receiverID uuid.UUID, balance string) error {
 	apiKey := os.Getenv("MAILERSEND_API_KEY")
 	fromEmail := os.Getenv("FROM_EMAIL")
 	fromName := os.Getenv("FROM_NAME")
@@ -231,9 +231,9 @@ func SendTransactionNotification(email, userName string, transferID int, amount
 			<p>Dear %s,</p>
 			<p>Your wallet has been transfered.</p>
 			<p>Transfer ID: <strong>%d</strong></p>
-			<p>Amount Transferred: <strong>$%.2f</strong></p>
+			<p>Amount Transferred: <strong>%s</strong></p>
 			<p>To: <strong>%s</strong></p>
-			<p>Your Balance: <strong>%.2f</strong></p>
+			<p>Your Balance: <strong>%s</strong></p>
 			<p>Thank you for using our service!</p>
 			<p>Regards,<br>Video Game Rental Team</p>
 		</body>
@@ -241,7 +241,7 @@ func SendTransactionNotification(email, userName string, transferID int, amount
 	`, userName, transferID, amount, receiverID, balance)
 
 	textContent := fmt.Sprintf(
-		"Fund Transfer\n\nDear %s,\n\nYour wallet has been transfered.\nTransfer ID: %d\nAmount Transferred: $%.2f\nto: %s\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
+		"Fund Transfer\n\nDear %s,\n\nYour wallet has been transfered.\nTransfer ID: %d\nAmount Transferred: %s\nto: %s\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
 		userName, transferID, amount, receiverID)
 
 	type EmailAddress struct {
@@ -309,3 +309,143 @@ func SendTransactionNotification(email, userName string, transferID int, amount
 
 	return nil
 }
+
+func SendBookingStatusNotification(email, userName string, bookingID int, productName, status, reason string) error {
+	htmlContent := fmt.Sprintf(`
+		<html>
+		<body>
+			<h1>Booking %s</h1>
+			<p>Dear %s,</p>
+			<p>The rental booking for <strong>%s</strong> is now <strong>%s</strong>.</p>
+			<p>Booking ID: <strong>%d</strong></p>
+			<p>Reason: %s</p>
+			<p>Thank you for using our service!</p>
+			<p>Regards,<br>Video Game Rental Team</p>
+		</body>
+		</html>
+	`, status, userName, productName, status, bookingID, reason)
+
+	textContent := fmt.Sprintf(
+		"Booking %s\n\nDear %s,\n\nThe rental booking for %s is now %s.\nBooking ID: %d\nReason: %s\n\nThank you for using our service!\n\nRegards,\nVideo Game Rental Team",
+		status, userName, productName, status, bookingID, reason)
+
+	return sendEmail(email, userName, fmt.Sprintf("Game Rental Booking %s", status), htmlContent, textContent, []string{"booking", "notification"})
+}
+
+func SendEmailVerification(email, userName, link string) error {
+	htmlContent := fmt.Sprintf(`
+		<html>
+		<body>
+			<h1>Verify your email</h1>
+			<p>Dear %s,</p>
+			<p>Please confirm your email address to start renting and topping up your balance.</p>
+			<p><a href="%s">Verify my email</a></p>
+			<p>If you did not create an account, you can ignore this email.</p>
+			<p>Regards,<br>Video Game Rental Team</p>
+		</body>
+		</html>
+	`, userName, link)
+
+	textContent := fmt.Sprintf(
+		"Verify your email\n\nDear %s,\n\nPlease confirm your email address to start renting and topping up your balance:\n%s\n\nIf you did not create an account, you can ignore this email.\n\nRegards,\nVideo Game Rental Team",
+		userName, link)
+
+	return sendEmail(email, userName, "Verify your Game Rental email", htmlContent, textContent, []string{"account", "verification"})
+}
+
+func SendPasswordReset(email, userName, token, validFor string) error {
+	htmlContent := fmt.Sprintf(`
+		<html>
+		<body>
+			<h1>Reset your password</h1>
+			<p>Dear %s,</p>
+			<p>Use the token below to choose a new password. It works once and expires in %s.</p>
+			<p><strong>%s</strong></p>
+			<p>If you did not ask for a password reset, you can ignore this email.</p>
+			<p>Regards,<br>Video Game Rental Team</p>
+		</body>
+		</html>
+	`, userName, validFor, token)
+
+	textContent := fmt.Sprintf(
+		"Reset your password\n\nDear %s,\n\nUse the token below to choose a new password. It works once and expires in %s.\n\n%s\n\nIf you did not ask for a password reset, you can ignore this email.\n\nRegards,\nVideo Game Rental Team",
+		userName, validFor, token)
+
+	return sendEmail(email, userName, "Reset your Game Rental password", htmlContent, textContent, []string{"account", "password-reset"})
+}
+
+// sendEmail posts a single message to the MailerSend API.
+func sendEmail(email, userName, subject, htmlContent, textContent string, tags []string) error {
+	apiKey := os.Getenv("MAILERSEND_API_KEY")
+	fromEmail := os.Getenv("FROM_EMAIL")
+	fromName := os.Getenv("FROM_NAME")
+
+	if fromEmail == "" {
+		fromEmail = "noreply@trial-yzkq340vnnkld796.mlsender.net"
+	}
+	if fromName == "" {
+		fromName = "Game Rental Service"
+	}
+	if apiKey == "" {
+		return fmt.Errorf("MAILERSEND_API_KEY is not set")
+	}
+
+	type EmailAddress struct {
+		Email string `json:"email"`
+		Name  string `json:"name,omitempty"`
+	}
+
+	type EmailRequest struct {
+		From    EmailAddress   `json:"from"`
+		To      []EmailAddress `json:"to"`
+		Subject string         `json:"subject"`
+		HTML    string         `json:"html"`
+		Text    string         `json:"text"`
+		Tags    []string       `json:"tags,omitempty"`
+	}
+
+	payload := EmailRequest{
+		From: EmailAddress{
+			Email: fromEmail,
+			Name:  fromName,
+		},
+		To: []EmailAddress{
+			{
+				Email: email,
+				Name:  userName,
+			},
+		},
+		Subject: subject,
+		HTML:    htmlContent,
+		Text:    textContent,
+		Tags:    tags,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", "https://api.mailersend.com/v1/email", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("MailerSend API returned error: %d - %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
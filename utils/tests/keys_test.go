@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"rent-video-game/model"
+	"rent-video-game/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir, id, alg string, retired bool) {
+	key, err := utils.GenerateSigningKey(id, alg)
+	require.NoError(t, err)
+
+	name, marshal := id+".pem", key.MarshalPrivateKey
+	if retired {
+		name, marshal = id+".pub.pem", key.MarshalPublicKey
+	}
+
+	data, err := marshal()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+}
+
+func TestLoadKeySetSignsWithNewestKey(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "20260101T000000Z", utils.AlgRS256, false)
+	writeKey(t, dir, "20260201T000000Z", utils.AlgEdDSA, false)
+	writeKey(t, dir, "20251201T000000Z", utils.AlgEdDSA, true)
+
+	keys, err := utils.LoadKeySet(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, "20260201T000000Z", keys.SigningKeyID())
+
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 3)
+	assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+	assert.Equal(t, "RSA", jwks.Keys[1].KeyType)
+	assert.Equal(t, "RS256", jwks.Keys[1].Algorithm)
+
+	pinned, err := utils.LoadKeySet(dir, "20260101T000000Z")
+	require.NoError(t, err)
+	assert.Equal(t, "20260101T000000Z", pinned.SigningKeyID())
+
+	_, err = utils.LoadKeySet(dir, "20251201T000000Z")
+	assert.Error(t, err)
+}
+
+func TestTokensSurviveRotation(t *testing.T) {
+	old, err := utils.GenerateSigningKey("1", utils.AlgRS256)
+	require.NoError(t, err)
+	next, err := utils.GenerateSigningKey("2", utils.AlgEdDSA)
+	require.NoError(t, err)
+
+	keys, err := utils.NewKeySet("", old)
+	require.NoError(t, err)
+	utils.UseKeySet(keys)
+
+	token, err := utils.GenerateUserToken(uuid.New(), &model.Access{}, time.Minute)
+	require.NoError(t, err)
+
+	rotated, err := utils.NewKeySet("", old, next)
+	require.NoError(t, err)
+	utils.UseKeySet(rotated)
+
+	_, err = utils.VerifyUserToken(token)
+	assert.NoError(t, err)
+
+	retired, err := utils.NewKeySet("", next)
+	require.NoError(t, err)
+	utils.UseKeySet(retired)
+
+	_, err = utils.VerifyUserToken(token)
+	assert.Error(t, err)
+}
+
+func TestAccountTokenIsNotAnAccessToken(t *testing.T) {
+	key, err := utils.GenerateSigningKey("1", utils.AlgEdDSA)
+	require.NoError(t, err)
+	keys, err := utils.NewKeySet("", key)
+	require.NoError(t, err)
+	utils.UseKeySet(keys)
+
+	token, err := utils.GenerateAccountToken(uuid.New(), model.PurposeResetPassword, "jti", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	_, err = utils.VerifyUserToken(token)
+	assert.Error(t, err)
+
+	_, _, err = utils.VerifyAccountToken(token, model.PurposeVerifyEmail)
+	assert.Error(t, err)
+
+	_, jti, err := utils.VerifyAccountToken(token, model.PurposeResetPassword)
+	assert.NoError(t, err)
+	assert.Equal(t, "jti", jti)
+}
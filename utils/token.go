@@ -2,40 +2,100 @@ package utils
 
 import (
 	"fmt"
-	"os"
+	"rent-video-game/model"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-var jwtKey = os.Getenv("JWT_SECRET")
+// tokenUseAccess marks access tokens. Account link tokens carry their purpose
+// instead, so one kind is never accepted as the other.
+const tokenUseAccess = "access"
+
+// GenerateUserToken signs an access token carrying the user's roles and
+// permissions, which the route middleware checks without a database lookup.
+// The random jti lets a single token be revoked on logout.
+func GenerateUserToken(userId uuid.UUID, access *model.Access, ttl time.Duration) (string, error) {
+	keys, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 
-func GenerateUserToken(userId uuid.UUID) (string, error) {
 	claims := jwt.MapClaims{}
+	claims["jti"] = uuid.NewString()
 	claims["user_id"] = userId
-	claims["exp"] = time.Now().Add(time.Hour * 1).Unix()
+	claims["token_use"] = tokenUseAccess
+	claims["roles"] = access.Roles
+	claims["permissions"] = access.Permissions
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(jwtKey))
+	return keys.sign(claims)
 }
 
 func VerifyUserToken(t string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(t, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("invalid signing method")
-		}
-
-		return []byte(jwtKey), nil
-	})
+	keys, err := currentKeys()
+	if err != nil {
+		return nil, err
+	}
 
+	claims, err := keys.verify(t)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	if claims["token_use"] != tokenUseAccess {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+// GenerateAccountToken signs the token mailed in a verification or password
+// reset link.
+func GenerateAccountToken(userId uuid.UUID, purpose model.AccountTokenPurpose, jti string, expiresAt time.Time) (string, error) {
+	keys, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{}
+	claims["jti"] = jti
+	claims["sub"] = userId.String()
+	claims["token_use"] = string(purpose)
+	claims["exp"] = expiresAt.Unix()
+
+	return keys.sign(claims)
+}
+
+// VerifyAccountToken checks the signature and expiry of a link token and
+// returns the user and JTI it was issued for.
+func VerifyAccountToken(t string, purpose model.AccountTokenPurpose) (uuid.UUID, string, error) {
+	keys, err := currentKeys()
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	claims, err := keys.verify(t)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if claims["token_use"] != string(purpose) {
+		return uuid.Nil, "", fmt.Errorf("invalid token")
+	}
+
+	subject, _ := claims["sub"].(string)
+	userId, err := uuid.Parse(subject)
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("invalid token")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return uuid.Nil, "", fmt.Errorf("invalid token")
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return userId, jti, nil
 }